@@ -8,6 +8,10 @@ import (
 	"net/http"
 	"os"
 	"time"
+
+	"github.com/awa/go-iap/internal/telemetry"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -15,6 +19,9 @@ const (
 	SandboxURL string = "https://appstore-sdk.amazon.com/sandbox"
 	// ProductionURL is the endpoint for production environment.
 	ProductionURL string = "https://appstore-sdk.amazon.com"
+
+	// verifyPathTemplate is the path of Verify without the secret and the IDs, recorded by the telemetry.
+	verifyPathTemplate = "/version/1.0/verifyReceiptId/developer/{developerSecret}/user/{userId}/receiptId/{receiptId}"
 )
 
 func getSandboxURL() string {
@@ -82,9 +89,10 @@ type IAPClient interface {
 
 // Client implements IAPClient
 type Client struct {
-	URL     string
-	Secret  string
-	httpCli *http.Client
+	URL       string
+	Secret    string
+	httpCli   *http.Client
+	telemetry *telemetry.Recorder
 }
 
 // New creates a client object
@@ -117,8 +125,17 @@ func NewWithClient(secret string, cli *http.Client) *Client {
 	return client
 }
 
+// SetTelemetry makes the client record an OpenTelemetry span and the duration of each call to the RVS.
+// Nil providers turn the telemetry off.
+func (c *Client) SetTelemetry(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) {
+	c.telemetry = telemetry.New("amazon", tracerProvider, meterProvider)
+}
+
 // Verify sends receipts and gets validation result
-func (c *Client) Verify(ctx context.Context, userID string, receiptID string) (IAPResponse, error) {
+func (c *Client) Verify(ctx context.Context, userID string, receiptID string) (_ IAPResponse, err error) {
+	ctx, call := c.telemetry.Start(ctx, "Verify", http.MethodGet, verifyPathTemplate)
+	defer func() { call.End(err) }()
+
 	result := IAPResponse{}
 	url := fmt.Sprintf("%v/version/1.0/verifyReceiptId/developer/%v/user/%v/receiptId/%v", c.URL, c.Secret, userID, receiptID)
 	req, err := http.NewRequest("GET", url, nil)
@@ -132,6 +149,7 @@ func (c *Client) Verify(ctx context.Context, userID string, receiptID string) (I
 		return result, err
 	}
 	defer resp.Body.Close()
+	call.SetStatus(resp.StatusCode)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		responseError := IAPResponseError{}
@@ -8,8 +8,12 @@ import (
 	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestHandle497Error(t *testing.T) {
@@ -187,6 +191,40 @@ func TestVerifyTimeout(t *testing.T) {
 	}
 }
 
+func TestVerifyTelemetryOmitsSecret(t *testing.T) {
+	t.Parallel()
+	server, client := testTools(http.StatusOK, "{}")
+	server.Close() // fail the transport, with an error holding the URL of the request
+	client.Secret = "2:smXBjZkWCxDMSBvQ8HBGsUS1PK3jvVc8tuTjLNfPHfYAga6WaDzXJPoWpfemXaHg"
+
+	spans := tracetest.NewSpanRecorder()
+	client.SetTelemetry(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)), nil)
+	_, err := client.Verify(context.Background(), "user", "receipt")
+	if err == nil || !strings.Contains(err.Error(), client.Secret) {
+		t.Fatalf("expected a transport error with the request URL, got %v", err)
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("got %d spans, want 1", len(ended))
+	}
+	recorded := []string{ended[0].Name(), ended[0].Status().Description}
+	for _, attr := range ended[0].Attributes() {
+		recorded = append(recorded, attr.Value.Emit())
+	}
+	for _, event := range ended[0].Events() {
+		recorded = append(recorded, event.Name)
+		for _, attr := range event.Attributes {
+			recorded = append(recorded, attr.Value.Emit())
+		}
+	}
+	for _, v := range recorded {
+		if strings.Contains(v, client.Secret) {
+			t.Errorf("span records the secret: %q", v)
+		}
+	}
+}
+
 func testTools(code int, body string) (*httptest.Server, *Client) {
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,147 @@
+package apitest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// The extensions Apple marks the certificates signing App Store data with, which api.SignedDataVerifier checks.
+var (
+	oidAppleLeafCert         = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+	oidAppleIntermediateCert = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+)
+
+// CAOptions configures the certificates of a CA.
+type CAOptions struct {
+	NotBefore              time.Time // Start of the validity of the certificates. Default is an hour ago
+	NotAfter               time.Time // End of the validity of the certificates. Default is a year from now
+	WithoutLeafOID         bool      // Whether the leaf certificate misses the extension Apple marks it with
+	WithoutIntermediateOID bool      // Whether the intermediate certificate misses the extension Apple marks it with
+}
+
+// CA is a three level certificate chain shaped like the one Apple signs App Store data with, to sign data trusted by
+// a client configured with its root certificate.
+type CA struct {
+	Root         *x509.Certificate
+	Intermediate *x509.Certificate
+	Leaf         *x509.Certificate
+
+	leafKey *ecdsa.PrivateKey
+}
+
+// NewCA issues the certificates of a CA per opts, which may be nil. It panics if they cannot be issued.
+func NewCA(opts *CAOptions) *CA {
+	var o CAOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.NotBefore.IsZero() {
+		o.NotBefore = time.Now().Add(-time.Hour)
+	}
+	if o.NotAfter.IsZero() {
+		o.NotAfter = time.Now().AddDate(1, 0, 0)
+	}
+	ca, err := newCA(o)
+	if err != nil {
+		panic(err)
+	}
+	return ca
+}
+
+func newCA(opts CAOptions) (*CA, error) {
+	issue := func(template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		cert, err := x509.ParseCertificate(der)
+		return cert, key, err
+	}
+	marker := func(oid asn1.ObjectIdentifier, without bool) []pkix.Extension {
+		if without {
+			return nil
+		}
+		return []pkix.Extension{{Id: oid, Value: asn1.NullBytes}}
+	}
+
+	root, rootKey, err := issue(&x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "apitest Root CA"},
+		NotBefore:             opts.NotBefore,
+		NotAfter:              opts.NotAfter,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("apitest: issue root certificate: %w", err)
+	}
+	intermediate, intermediateKey, err := issue(&x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "apitest Intermediate CA"},
+		NotBefore:             opts.NotBefore,
+		NotAfter:              opts.NotAfter,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		ExtraExtensions:       marker(oidAppleIntermediateCert, opts.WithoutIntermediateOID),
+	}, root, rootKey)
+	if err != nil {
+		return nil, fmt.Errorf("apitest: issue intermediate certificate: %w", err)
+	}
+	leaf, leafKey, err := issue(&x509.Certificate{
+		SerialNumber:    big.NewInt(3),
+		Subject:         pkix.Name{CommonName: "apitest Leaf"},
+		NotBefore:       opts.NotBefore,
+		NotAfter:        opts.NotAfter,
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: marker(oidAppleLeafCert, opts.WithoutLeafOID),
+	}, intermediate, intermediateKey)
+	if err != nil {
+		return nil, fmt.Errorf("apitest: issue leaf certificate: %w", err)
+	}
+
+	return &CA{Root: root, Intermediate: intermediate, Leaf: leaf, leafKey: leafKey}, nil
+}
+
+// RootCertificates returns the DER encoded root certificate of the CA, such as for StoreConfig.RootCertificates.
+func (ca *CA) RootCertificates() [][]byte {
+	return [][]byte{ca.Root.Raw}
+}
+
+// Sign returns claims as a JWS signed by the leaf certificate with the chain in the x5c header.
+// It panics if the claims cannot be signed.
+func (ca *CA) Sign(claims jwt.Claims) string {
+	signed, err := ca.sign(claims)
+	if err != nil {
+		panic(fmt.Sprintf("apitest: sign: %v", err))
+	}
+	return signed
+}
+
+func (ca *CA) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["x5c"] = []string{
+		base64.StdEncoding.EncodeToString(ca.Leaf.Raw),
+		base64.StdEncoding.EncodeToString(ca.Intermediate.Raw),
+		base64.StdEncoding.EncodeToString(ca.Root.Raw),
+	}
+	return token.SignedString(ca.leafKey)
+}
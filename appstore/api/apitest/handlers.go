@@ -0,0 +1,618 @@
+package apitest
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/google/uuid"
+)
+
+// pageSize is the number of items of a history page.
+const pageSize = 20
+
+// maxExtensions is the number of renewal date extensions Apple grants a subscription per year.
+const maxExtensions = 2
+
+const familyShared = "FAMILY_SHARED"
+
+func (s *Server) lookUpOrderID(w http.ResponseWriter, r *http.Request) error {
+	var transactions []*api.JWSTransaction
+	for _, id := range s.orders[r.PathValue("orderId")] {
+		if tran, ok := s.transactions[id]; ok {
+			transactions = append(transactions, tran)
+		}
+	}
+	if len(transactions) == 0 {
+		writeJSON(w, http.StatusOK, api.OrderLookupResponse{Status: 1})
+		return nil
+	}
+
+	signed, err := s.signTransactions(transactions)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, api.OrderLookupResponse{SignedTransactions: signed})
+	return nil
+}
+
+func (s *Server) transactionHistory(w http.ResponseWriter, r *http.Request) error {
+	tran, ok := s.transactions[r.PathValue("transactionId")]
+	if !ok {
+		return api.TransactionIdNotFoundError
+	}
+
+	query := r.URL.Query()
+	transactions := s.history(tran)
+	if products := query["productId"]; len(products) > 0 {
+		transactions = slices.DeleteFunc(transactions, func(t *api.JWSTransaction) bool {
+			return !slices.Contains(products, t.ProductID)
+		})
+	}
+	if v := query.Get("revoked"); v != "" {
+		revoked, err := strconv.ParseBool(v)
+		if err != nil {
+			return api.InvalidRevokedError
+		}
+		transactions = slices.DeleteFunc(transactions, func(t *api.JWSTransaction) bool {
+			return (t.RevocationDate != 0) != revoked
+		})
+	}
+	switch query.Get("sort") {
+	case "", "ASCENDING":
+	case "DESCENDING":
+		slices.Reverse(transactions)
+	default:
+		return api.InvalidSortError
+	}
+
+	items, revision, hasMore, ok := page(transactions, query.Get("revision"))
+	if !ok {
+		return api.InvalidRequestRevisionError
+	}
+	signed, err := s.signTransactions(items)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, api.HistoryResponse{
+		AppAppleId:         s.config.AppAppleID,
+		BundleId:           s.config.BundleID,
+		Environment:        s.config.Environment,
+		HasMore:            hasMore,
+		Revision:           revision,
+		SignedTransactions: signed,
+	})
+	return nil
+}
+
+func (s *Server) transactionInfo(w http.ResponseWriter, r *http.Request) error {
+	tran, ok := s.transactions[r.PathValue("transactionId")]
+	if !ok {
+		return api.TransactionIdNotFoundError
+	}
+
+	signed, err := s.signTransaction(tran)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, api.TransactionInfoResponse{SignedTransactionInfo: signed})
+	return nil
+}
+
+// appTransactionInfo serves the app transaction of SetAppTransaction, a transaction ID is not found without one.
+func (s *Server) appTransactionInfo(w http.ResponseWriter, r *http.Request) error {
+	if _, ok := s.transactions[r.PathValue("transactionId")]; !ok || s.appTransaction == nil {
+		return api.TransactionIdNotFoundError
+	}
+
+	signed, err := s.ca.sign(*s.appTransaction)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, api.AppTransactionInfoResponse{SignedAppTransactionInfo: signed})
+	return nil
+}
+
+func (s *Server) refundHistory(w http.ResponseWriter, r *http.Request) error {
+	tran, ok := s.transactions[r.PathValue("originalTransactionId")]
+	if !ok {
+		return api.TransactionIdNotFoundError
+	}
+
+	refunded := slices.DeleteFunc(s.history(tran), func(t *api.JWSTransaction) bool {
+		return t.RevocationDate == 0
+	})
+	slices.SortStableFunc(refunded, func(a, b *api.JWSTransaction) int {
+		return cmp.Compare(a.RevocationDate, b.RevocationDate)
+	})
+	items, revision, hasMore, ok := page(refunded, r.URL.Query().Get("revision"))
+	if !ok {
+		return api.InvalidRequestRevisionError
+	}
+	signed, err := s.signTransactions(items)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, api.RefundLookupResponse{HasMore: hasMore, Revision: revision, SignedTransactions: signed})
+	return nil
+}
+
+// subscriptionStatuses serves the status of the last transaction of each subscription of the customer,
+// the transactions with a subscription group identifier, grouped by subscription group.
+func (s *Server) subscriptionStatuses(w http.ResponseWriter, r *http.Request) error {
+	tran, ok := s.transactions[r.PathValue("originalTransactionId")]
+	if !ok {
+		return api.TransactionIdNotFoundError
+	}
+	var statuses []api.AutoRenewSubscriptionStatus
+	for _, v := range r.URL.Query()["status"] {
+		status, err := strconv.Atoi(v)
+		if err != nil || status < int(api.SubscriptionActive) || status > int(api.SubscriptionRevoked) {
+			return api.InvalidStatusError
+		}
+		statuses = append(statuses, api.AutoRenewSubscriptionStatus(status))
+	}
+
+	rsp := api.StatusResponse{
+		Environment: s.config.Environment,
+		AppAppleId:  s.config.AppAppleID,
+		BundleId:    s.config.BundleID,
+		Data:        []api.SubscriptionGroupIdentifierItem{},
+	}
+	groups := make(map[string]int)
+	for _, last := range s.subscriptions(tran) {
+		status := s.status(last)
+		if len(statuses) > 0 && !slices.Contains(statuses, status) {
+			continue
+		}
+		signedTransaction, err := s.signTransaction(last)
+		if err != nil {
+			return err
+		}
+		renewalInfo := s.renewalInfo(last)
+		renewalInfo.SignedDate = s.now()
+		signedRenewalInfo, err := s.ca.sign(renewalInfo)
+		if err != nil {
+			return err
+		}
+
+		i, ok := groups[last.SubscriptionGroupIdentifier]
+		if !ok {
+			i = len(rsp.Data)
+			groups[last.SubscriptionGroupIdentifier] = i
+			rsp.Data = append(rsp.Data, api.SubscriptionGroupIdentifierItem{SubscriptionGroupIdentifier: last.SubscriptionGroupIdentifier})
+		}
+		rsp.Data[i].LastTransactions = append(rsp.Data[i].LastTransactions, api.LastTransactionsItem{
+			OriginalTransactionId: last.OriginalTransactionId,
+			Status:                status,
+			SignedRenewalInfo:     signedRenewalInfo,
+			SignedTransactionInfo: signedTransaction,
+		})
+	}
+	writeJSON(w, http.StatusOK, rsp)
+	return nil
+}
+
+func (s *Server) consumptionInfoV2(w http.ResponseWriter, r *http.Request) error {
+	transactionID := r.PathValue("transactionId")
+	tran, ok := s.transactions[transactionID]
+	if !ok {
+		return api.TransactionIdNotFoundError
+	}
+	if tran.InAppOwnershipType == familyShared {
+		return api.FamilyTransactionNotSupportedError
+	}
+
+	var body api.ConsumptionRequest
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	if err := body.Validate(); err != nil {
+		var apiErr *api.Error
+		if errors.As(err, &apiErr) {
+			return apiErr
+		}
+		return api.GeneralBadRequestError
+	}
+	s.consumptions[transactionID] = body
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+func (s *Server) consumptionInfo(w http.ResponseWriter, r *http.Request) error {
+	originalTransactionID := r.PathValue("originalTransactionId")
+	if s.lastTransaction(originalTransactionID) == nil {
+		return api.OriginalTransactionIdNotFoundError
+	}
+
+	var body api.ConsumptionRequestBody
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	if !body.CustomerConsented {
+		return api.InvalidCustomerConsentedError
+	}
+	s.consumptionsV1[originalTransactionID] = body
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+// extendRenewalDate extends the expiration of the last transaction of an active subscription, at most twice.
+// A request identifier already used answers the same response.
+func (s *Server) extendRenewalDate(w http.ResponseWriter, r *http.Request) error {
+	originalTransactionID := r.PathValue("originalTransactionId")
+	var body api.ExtendRenewalDateRequest
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	if err := validateExtension(body.ExtendByDays, int32(body.ExtendReasonCode), body.RequestIdentifier); err != nil {
+		return err
+	}
+
+	key := originalTransactionID + "/" + body.RequestIdentifier
+	if rsp, ok := s.extensions[key]; ok {
+		writeJSON(w, http.StatusOK, rsp)
+		return nil
+	}
+	last := s.lastTransaction(originalTransactionID)
+	switch {
+	case last == nil:
+		return api.OriginalTransactionIdNotFoundError
+	case last.InAppOwnershipType == familyShared:
+		return api.FamilySharedSubscriptionExtensionIneligibleError
+	case s.status(last) != api.SubscriptionActive:
+		return api.SubscriptionExtensionIneligibleError
+	case s.extensionCounts[originalTransactionID] >= maxExtensions:
+		return api.SubscriptionMaxExtensionError
+	}
+
+	s.extend(last, body.ExtendByDays)
+	s.extensionCounts[originalTransactionID]++
+	rsp := &api.ExtendRenewalDateResponse{
+		OriginalTransactionId: originalTransactionID,
+		WebOrderLineItemId:    last.WebOrderLineItemId,
+		Success:               true,
+		EffectiveDate:         last.ExpiresDate,
+	}
+	s.extensions[key] = rsp
+	writeJSON(w, http.StatusOK, rsp)
+	return nil
+}
+
+// massExtension extends the active subscriptions of the product at once, the family shared ones fail.
+// The request is complete when answered.
+func (s *Server) massExtension(w http.ResponseWriter, r *http.Request) error {
+	var body api.MassExtendRenewalDateRequest
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	if err := validateExtension(body.ExtendByDays, body.ExtendReasonCode, body.RequestIdentifier); err != nil {
+		return err
+	}
+	if body.ProductId == "" {
+		return api.InvalidProductIdError
+	}
+
+	key := body.ProductId + "/" + body.RequestIdentifier
+	if _, ok := s.massExtensions[key]; !ok {
+		status := &api.MassExtendRenewalDateStatusResponse{RequestIdentifier: body.RequestIdentifier, Complete: true, CompleteDate: s.now()}
+		seen := make(map[string]bool)
+		for _, id := range s.transactionOrder {
+			originalTransactionID := s.transactions[id].OriginalTransactionId
+			if seen[originalTransactionID] {
+				continue
+			}
+			seen[originalTransactionID] = true
+			last := s.lastTransaction(originalTransactionID)
+			if last.ProductID != body.ProductId || s.status(last) != api.SubscriptionActive {
+				continue
+			}
+			if len(body.StorefrontCountryCodes) > 0 && !slices.Contains(body.StorefrontCountryCodes, last.Storefront) {
+				continue
+			}
+			if last.InAppOwnershipType == familyShared {
+				status.FailedCount++
+				continue
+			}
+			s.extend(last, body.ExtendByDays)
+			status.SucceededCount++
+		}
+		s.massExtensions[key] = status
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"requestIdentifier": body.RequestIdentifier})
+	return nil
+}
+
+func (s *Server) massExtensionStatus(w http.ResponseWriter, r *http.Request) error {
+	status, ok := s.massExtensions[r.PathValue("productId")+"/"+r.PathValue("requestIdentifier")]
+	if !ok {
+		return api.StatusRequestNotFoundError
+	}
+	writeJSON(w, http.StatusOK, status)
+	return nil
+}
+
+// notificationHistory serves the notifications signed in the window of the request, in the order they were added.
+// A notification failed when none of its attempts succeeded.
+func (s *Server) notificationHistory(w http.ResponseWriter, r *http.Request) error {
+	var body api.NotificationHistoryRequest
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	switch {
+	case body.StartDate == 0:
+		return api.InvalidStartDateError
+	case body.EndDate == 0:
+		return api.InvalidEndDateError
+	case body.StartDate > body.EndDate:
+		return api.StartDateAfterEndDateError
+	}
+	originalTransactionID := body.TransactionId
+	if tran, ok := s.transactions[body.TransactionId]; ok {
+		originalTransactionID = tran.OriginalTransactionId
+	}
+
+	var history []api.NotificationHistoryResponseItem
+	for _, n := range s.notifications {
+		switch {
+		case n.signedDate < body.StartDate || n.signedDate > body.EndDate:
+		case body.NotificationType != "" && n.notificationType != body.NotificationType:
+		case body.NotificationSubtype != "" && n.subtype != body.NotificationSubtype:
+		case originalTransactionID != "" && n.originalTransactionID != originalTransactionID:
+		case body.OnlyFailures && delivered(n.item.SendAttempts):
+		default:
+			history = append(history, n.item)
+		}
+	}
+	items, paginationToken, hasMore, ok := page(history, r.URL.Query().Get("paginationToken"))
+	if !ok {
+		return api.InvalidPaginationTokenError
+	}
+	rsp := api.NotificationHistoryResponses{HasMore: hasMore, NotificationHistory: items}
+	if hasMore {
+		rsp.PaginationToken = paginationToken
+	}
+	writeJSON(w, http.StatusOK, rsp)
+	return nil
+}
+
+// requestTestNotification sends a TEST notification with the result of Config.TestNotificationResult,
+// the notification is added to the notification history.
+func (s *Server) requestTestNotification(w http.ResponseWriter, _ *http.Request) error {
+	now := s.now()
+	notification := &appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationType:    appstore.NotificationTypeV2Test,
+		NotificationUUID:    uuid.NewString(),
+		NotificationVersion: "2.0",
+		SignedDate:          now,
+		Data: appstore.SubscriptionNotificationV2Data{
+			AppAppleID:  int(s.config.AppAppleID),
+			BundleID:    s.config.BundleID,
+			Environment: string(s.config.Environment),
+		},
+	}
+	signed, err := s.ca.sign(notification)
+	if err != nil {
+		return err
+	}
+	attempts := []api.SendAttemptItem{{AttemptDate: now, SendAttemptResult: s.config.TestNotificationResult}}
+
+	token := uuid.NewString() + "_" + strconv.FormatInt(now, 10)
+	s.testNotifications[token] = &api.CheckTestNotificationResponse{SignedPayload: signed, SendAttempts: attempts}
+	s.notifications = append(s.notifications, &notificationRecord{
+		item: api.NotificationHistoryResponseItem{
+			SignedPayload:          signed,
+			FirstSendAttemptResult: s.config.TestNotificationResult,
+			SendAttempts:           attempts,
+		},
+		notificationType: appstore.NotificationTypeV2Test,
+		signedDate:       now,
+	})
+	writeJSON(w, http.StatusOK, api.SendTestNotificationResponse{TestNotificationToken: token})
+	return nil
+}
+
+func (s *Server) testNotificationStatus(w http.ResponseWriter, r *http.Request) error {
+	status, ok := s.testNotifications[r.PathValue("testNotificationToken")]
+	if !ok {
+		return api.TestNotificationNotFoundError
+	}
+	writeJSON(w, http.StatusOK, status)
+	return nil
+}
+
+func (s *Server) setAppAccountToken(w http.ResponseWriter, r *http.Request) error {
+	originalTransactionID := r.PathValue("originalTransactionId")
+	var body api.UpdateAppAccountTokenRequest
+	if err := decodeBody(r, &body); err != nil {
+		return err
+	}
+	if _, err := uuid.Parse(body.AppAccountToken); err != nil {
+		return api.InvalidAppAccountTokenUUIDError
+	}
+
+	if s.lastTransaction(originalTransactionID) == nil {
+		if _, ok := s.transactions[originalTransactionID]; ok {
+			return api.TransactionIdIsNotOriginalTransactionIdError
+		}
+		return api.OriginalTransactionIdNotFoundError
+	}
+	for _, tran := range s.transactions {
+		if tran.OriginalTransactionId == originalTransactionID {
+			tran.AppAccountToken = body.AppAccountToken
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) finishTransaction(w http.ResponseWriter, r *http.Request) error {
+	transactionID := r.PathValue("transactionId")
+	if _, ok := s.transactions[transactionID]; !ok {
+		return api.TransactionIdNotFoundError
+	}
+	s.finished[transactionID] = true
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) now() int64 {
+	return s.config.Now().UnixMilli()
+}
+
+func (s *Server) signTransaction(tran *api.JWSTransaction) (string, error) {
+	signed := *tran
+	signed.SignedDate = s.now()
+	return s.ca.sign(signed)
+}
+
+func (s *Server) signTransactions(transactions []*api.JWSTransaction) ([]string, error) {
+	signed := make([]string, 0, len(transactions))
+	for _, tran := range transactions {
+		v, err := s.signTransaction(tran)
+		if err != nil {
+			return nil, err
+		}
+		signed = append(signed, v)
+	}
+	return signed, nil
+}
+
+// customerOf returns the key of the customer of a transaction: its app transaction ID, or its original transaction ID.
+func customerOf(tran *api.JWSTransaction) string {
+	if tran.AppTransactionId != "" {
+		return "app/" + tran.AppTransactionId
+	}
+	return "original/" + tran.OriginalTransactionId
+}
+
+// history returns the transactions of the customer of tran by purchase date.
+func (s *Server) history(tran *api.JWSTransaction) []*api.JWSTransaction {
+	customer := customerOf(tran)
+	var transactions []*api.JWSTransaction
+	for _, id := range s.transactionOrder {
+		if t := s.transactions[id]; customerOf(t) == customer {
+			transactions = append(transactions, t)
+		}
+	}
+	slices.SortStableFunc(transactions, func(a, b *api.JWSTransaction) int {
+		return cmp.Compare(a.PurchaseDate, b.PurchaseDate)
+	})
+	return transactions
+}
+
+// lastTransaction returns the last purchased transaction of an original transaction ID, nil if there is none.
+func (s *Server) lastTransaction(originalTransactionID string) *api.JWSTransaction {
+	var last *api.JWSTransaction
+	for _, id := range s.transactionOrder {
+		tran := s.transactions[id]
+		if tran.OriginalTransactionId == originalTransactionID && (last == nil || tran.PurchaseDate >= last.PurchaseDate) {
+			last = tran
+		}
+	}
+	return last
+}
+
+// subscriptions returns the last transaction of each subscription of the customer of tran.
+func (s *Server) subscriptions(tran *api.JWSTransaction) []*api.JWSTransaction {
+	var subscriptions []*api.JWSTransaction
+	seen := make(map[string]bool)
+	for _, t := range s.history(tran) {
+		if t.SubscriptionGroupIdentifier != "" && !seen[t.OriginalTransactionId] {
+			seen[t.OriginalTransactionId] = true
+			subscriptions = append(subscriptions, s.lastTransaction(t.OriginalTransactionId))
+		}
+	}
+	return subscriptions
+}
+
+// renewalInfo returns a copy of the renewal info of the subscription of last, the seeded one or one derived from last.
+func (s *Server) renewalInfo(last *api.JWSTransaction) api.JWSRenewalInfoDecodedPayload {
+	if info, ok := s.renewalInfos[last.OriginalTransactionId]; ok {
+		return *info
+	}
+	info := api.JWSRenewalInfoDecodedPayload{
+		AppAccountToken:       last.AppAccountToken,
+		AppTransactionId:      last.AppTransactionId,
+		AutoRenewProductId:    last.ProductID,
+		Environment:           last.Environment,
+		OriginalTransactionId: last.OriginalTransactionId,
+		ProductId:             last.ProductID,
+		RenewalDate:           last.ExpiresDate,
+	}
+	if last.RevocationDate == 0 && last.ExpiresDate > s.now() {
+		info.AutoRenewStatus = api.AutoRenewStatusOn
+	}
+	return info
+}
+
+func (s *Server) status(last *api.JWSTransaction) api.AutoRenewSubscriptionStatus {
+	now := s.now()
+	info := s.renewalInfo(last)
+	switch {
+	case last.RevocationDate != 0:
+		return api.SubscriptionRevoked
+	case last.ExpiresDate > now:
+		return api.SubscriptionActive
+	case info.GracePeriodExpiresDate > now:
+		return api.SubscriptionGracePeriod
+	case info.IsInBillingRetryPeriod != nil && *info.IsInBillingRetryPeriod:
+		return api.SubscriptionRetryPeriod
+	default:
+		return api.SubscriptionExpired
+	}
+}
+
+// extend moves the expiration of last, and the renewal date of its seeded renewal info, by days.
+func (s *Server) extend(last *api.JWSTransaction, days int32) {
+	last.ExpiresDate += (time.Duration(days) * 24 * time.Hour).Milliseconds()
+	if info, ok := s.renewalInfos[last.OriginalTransactionId]; ok {
+		info.RenewalDate = last.ExpiresDate
+	}
+}
+
+func validateExtension(extendByDays, extendReasonCode int32, requestIdentifier string) error {
+	switch {
+	case extendByDays < 1 || extendByDays > 90:
+		return api.InvalidExtendByDaysError
+	case extendReasonCode < int32(api.UndeclaredExtendReasonCode) || extendReasonCode > int32(api.ServiceIssueOrOutage):
+		return api.InvalidExtendReasonCodeError
+	case requestIdentifier == "":
+		return api.InvalidRequestIdentifierError
+	}
+	return nil
+}
+
+// delivered reports whether one of the attempts to send a notification succeeded.
+func delivered(attempts []api.SendAttemptItem) bool {
+	return slices.ContainsFunc(attempts, func(a api.SendAttemptItem) bool {
+		return a.SendAttemptResult == api.FirstSendAttemptResultSuccess
+	})
+}
+
+// page returns the items of the page at cursor, an offset in items, with the cursor of the following page.
+func page[T any](items []T, cursor string) (_ []T, next string, hasMore bool, ok bool) {
+	offset := 0
+	if cursor != "" {
+		n, err := strconv.Atoi(cursor)
+		if err != nil || n < 0 || n > len(items) {
+			return nil, "", false, false
+		}
+		offset = n
+	}
+	end := min(offset+pageSize, len(items))
+	return items[offset:end], strconv.Itoa(end), end < len(items), true
+}
+
+func decodeBody(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return api.GeneralBadRequestError
+	}
+	return nil
+}
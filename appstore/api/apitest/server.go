@@ -0,0 +1,466 @@
+// Package apitest provides an in-process fake of the App Store Server API for the integration tests of the code built
+// on api.StoreClient.
+//
+// A Server checks the ES256 bearer token of the requests like Apple does, serves the transactions, renewal infos and
+// notifications seeded by the test, signs them with a test certificate authority, and answers the Apple errors injected
+// with Fail. Its StoreConfig points a client at it and makes the client trust its certificate authority:
+//
+//	srv := apitest.NewServer(nil)
+//	defer srv.Close()
+//	srv.AddTransaction(api.JWSTransaction{TransactionID: "2000000000000001", ProductID: "com.example.monthly"})
+//	client := api.NewStoreClient(srv.StoreConfig())
+package apitest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// tokenAudience is the audience of the App Store Server API bearer tokens.
+const tokenAudience = "appstoreconnect-v1"
+
+// maxTokenLifetime is the longest lifetime Apple accepts for a bearer token.
+const maxTokenLifetime = time.Hour
+
+// Config configures the app a Server serves.
+type Config struct {
+	BundleID    string           // The bundle ID of the app. Default is com.example.app.
+	AppAppleID  int64            // The Apple ID of the app. Default is 1234567890.
+	KeyID       string           // The ID of the API key the bearer tokens must be signed with. Default is APITEST001.
+	Issuer      string           // The issuer ID the bearer tokens must carry. Default is a random UUID.
+	Environment api.Environment  // The environment of the signed data. Default is Sandbox.
+	Now         func() time.Time // The current time of the subscription statuses and the signed data. Default is time.Now.
+	// TestNotificationResult is the result of sending the test notifications to the server. Default is SUCCESS.
+	TestNotificationResult api.FirstSendAttemptResult
+}
+
+// Failure is an error response a Server answers instead of serving a request.
+type Failure struct {
+	Err        *api.Error    // The Apple error of the body, its status code is derived from its code, such as 404 for 4040010
+	StatusCode int           // The status code of a failure without an Apple error, such as 401 or 503
+	RetryAfter time.Duration // When not zero, the Retry-After header is set to the UNIX time in milliseconds after the delay
+}
+
+// Server is a fake App Store Server API listening on a local address.
+type Server struct {
+	URL string // The base URL of the server, such as http://127.0.0.1:50000, to set as StoreConfig.HostDebug
+
+	config Config
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+	ca     *CA
+
+	mu                sync.Mutex
+	transactions      map[string]*api.JWSTransaction
+	transactionOrder  []string
+	renewalInfos      map[string]*api.JWSRenewalInfoDecodedPayload
+	orders            map[string][]string
+	appTransaction    *api.JWSAppTransactionDecodedPayload
+	notifications     []*notificationRecord
+	testNotifications map[string]*api.CheckTestNotificationResponse
+	extensions        map[string]*api.ExtendRenewalDateResponse
+	extensionCounts   map[string]int
+	massExtensions    map[string]*api.MassExtendRenewalDateStatusResponse
+	consumptions      map[string]api.ConsumptionRequest
+	consumptionsV1    map[string]api.ConsumptionRequestBody
+	finished          map[string]bool
+	failures          map[string][]Failure
+}
+
+// notificationRecord is a notification of the history with the fields it is filtered on.
+type notificationRecord struct {
+	item                  api.NotificationHistoryResponseItem
+	notificationType      appstore.NotificationTypeV2
+	subtype               appstore.SubtypeV2
+	signedDate            int64
+	originalTransactionID string
+}
+
+// NewServer starts a Server of the app of config, nil for the defaults. Close it at the end of the test.
+// It panics if the keys and certificates of the server cannot be generated.
+func NewServer(config *Config) *Server {
+	s := &Server{
+		transactions:      make(map[string]*api.JWSTransaction),
+		renewalInfos:      make(map[string]*api.JWSRenewalInfoDecodedPayload),
+		orders:            make(map[string][]string),
+		testNotifications: make(map[string]*api.CheckTestNotificationResponse),
+		extensions:        make(map[string]*api.ExtendRenewalDateResponse),
+		extensionCounts:   make(map[string]int),
+		massExtensions:    make(map[string]*api.MassExtendRenewalDateStatusResponse),
+		consumptions:      make(map[string]api.ConsumptionRequest),
+		consumptionsV1:    make(map[string]api.ConsumptionRequestBody),
+		finished:          make(map[string]bool),
+		failures:          make(map[string][]Failure),
+	}
+	if config != nil {
+		s.config = *config
+	}
+	if s.config.BundleID == "" {
+		s.config.BundleID = "com.example.app"
+	}
+	if s.config.AppAppleID == 0 {
+		s.config.AppAppleID = 1234567890
+	}
+	if s.config.KeyID == "" {
+		s.config.KeyID = "APITEST001"
+	}
+	if s.config.Issuer == "" {
+		s.config.Issuer = uuid.NewString()
+	}
+	if s.config.Environment == "" {
+		s.config.Environment = api.Sandbox
+	}
+	if s.config.Now == nil {
+		s.config.Now = time.Now
+	}
+	if s.config.TestNotificationResult == "" {
+		s.config.TestNotificationResult = api.FirstSendAttemptResultSuccess
+	}
+
+	var err error
+	if s.key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		panic(fmt.Sprintf("apitest: generate API key: %v", err))
+	}
+	s.ca = NewCA(nil)
+
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// StoreConfig returns a configuration of a client of the server, with its API key and its root certificate.
+func (s *Server) StoreConfig() *api.StoreConfig {
+	der, err := x509.MarshalPKCS8PrivateKey(s.key)
+	if err != nil {
+		panic(fmt.Sprintf("apitest: marshal API key: %v", err))
+	}
+	return &api.StoreConfig{
+		KeyContent:       pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}),
+		KeyID:            s.config.KeyID,
+		BundleID:         s.config.BundleID,
+		Issuer:           s.config.Issuer,
+		Sandbox:          s.config.Environment == api.Sandbox,
+		RootCertificates: [][]byte{s.RootCertificate()},
+		HostDebug:        s.URL,
+	}
+}
+
+// RootCertificate returns the DER encoded root certificate of the signed data, such as for
+// api.SignedDataVerifierConfig.RootCertificates.
+func (s *Server) RootCertificate() []byte {
+	return s.ca.Root.Raw
+}
+
+// Sign returns claims as a JWS signed like the App Store signs its data, such as the signedTransactionInfo of a
+// notification. It panics if the claims cannot be signed.
+func (s *Server) Sign(claims jwt.Claims) string {
+	return s.ca.Sign(claims)
+}
+
+// AddTransaction seeds transactions, or replaces the ones with the same transaction ID.
+// The bundle ID and the environment default to the ones of the server, and the original transaction ID to the
+// transaction ID. The history of a transaction is made of the transactions with the same app transaction ID, or with
+// the same original transaction ID when it has none.
+func (s *Server) AddTransaction(transactions ...api.JWSTransaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tran := range transactions {
+		if tran.BundleID == "" {
+			tran.BundleID = s.config.BundleID
+		}
+		if tran.Environment == "" {
+			tran.Environment = s.config.Environment
+		}
+		if tran.OriginalTransactionId == "" {
+			tran.OriginalTransactionId = tran.TransactionID
+		}
+		if _, ok := s.transactions[tran.TransactionID]; !ok {
+			s.transactionOrder = append(s.transactionOrder, tran.TransactionID)
+		}
+		s.transactions[tran.TransactionID] = &tran
+	}
+}
+
+// SetRenewalInfo seeds the renewal info of the subscription of its original transaction ID.
+// The subscriptions without a renewal info have one derived from their last transaction.
+func (s *Server) SetRenewalInfo(renewalInfo api.JWSRenewalInfoDecodedPayload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if renewalInfo.Environment == "" {
+		renewalInfo.Environment = s.config.Environment
+	}
+	s.renewalInfos[renewalInfo.OriginalTransactionId] = &renewalInfo
+}
+
+// AddOrder seeds the transactions of an order ID, served by the order ID lookup.
+func (s *Server) AddOrder(orderID string, transactionIDs ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[orderID] = append(s.orders[orderID], transactionIDs...)
+}
+
+// SetAppTransaction seeds the app transaction served for every known transaction ID.
+func (s *Server) SetAppTransaction(appTransaction api.JWSAppTransactionDecodedPayload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if appTransaction.BundleId == "" {
+		appTransaction.BundleId = s.config.BundleID
+	}
+	if appTransaction.AppAppleId == 0 {
+		appTransaction.AppAppleId = s.config.AppAppleID
+	}
+	if appTransaction.ReceiptType == "" {
+		appTransaction.ReceiptType = s.config.Environment
+	}
+	s.appTransaction = &appTransaction
+}
+
+// AddNotification seeds the notification history with a notification and the attempts to send it,
+// a single successful attempt at its signed date when none is given. Its signed transaction info, if any,
+// must be signed with Sign.
+func (s *Server) AddNotification(notification *appstore.SubscriptionNotificationV2DecodedPayload, sendAttempts ...api.SendAttemptItem) {
+	if len(sendAttempts) == 0 {
+		sendAttempts = []api.SendAttemptItem{{AttemptDate: notification.SignedDate, SendAttemptResult: api.FirstSendAttemptResultSuccess}}
+	}
+	record := &notificationRecord{
+		item: api.NotificationHistoryResponseItem{
+			SignedPayload:          s.Sign(notification),
+			FirstSendAttemptResult: sendAttempts[0].SendAttemptResult,
+			SendAttempts:           sendAttempts,
+		},
+		notificationType: notification.NotificationType,
+		subtype:          notification.Subtype,
+		signedDate:       notification.SignedDate,
+	}
+	if signed := string(notification.Data.SignedTransactionInfo); signed != "" {
+		tran := &api.JWSTransaction{}
+		if _, _, err := jwt.NewParser().ParseUnverified(signed, tran); err == nil {
+			record.originalTransactionID = tran.OriginalTransactionId
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifications = append(s.notifications, record)
+}
+
+// Fail makes the server answer the next requests of the path, such as api.PathTransactionInfo, with the failures,
+// one failure per request in order. The requests are served again once the failures are used up.
+func (s *Server) Fail(path string, failures ...Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path] = append(s.failures[path], failures...)
+}
+
+// Transaction returns the current state of a seeded transaction, such as after a renewal date extension.
+func (s *Server) Transaction(transactionID string) (api.JWSTransaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tran, ok := s.transactions[transactionID]
+	if !ok {
+		return api.JWSTransaction{}, false
+	}
+	return *tran, true
+}
+
+// ConsumptionInfo returns the consumption information last sent for the transaction ID with SendConsumptionInfoV2.
+func (s *Server) ConsumptionInfo(transactionID string) (api.ConsumptionRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.consumptions[transactionID]
+	return body, ok
+}
+
+// ConsumptionInfoV1 returns the consumption information last sent for the original transaction ID with SendConsumptionInfo.
+func (s *Server) ConsumptionInfoV1(originalTransactionID string) (api.ConsumptionRequestBody, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.consumptionsV1[originalTransactionID]
+	return body, ok
+}
+
+// Finished reports whether the transaction was finished with FinishTransaction.
+func (s *Server) Finished(transactionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.finished[transactionID]
+}
+
+// route is an endpoint of the App Store Server API, its path is a template of store.go such as api.PathTransactionInfo.
+type route struct {
+	method string
+	path   string
+	serve  func(s *Server, w http.ResponseWriter, r *http.Request) error // An api.Error is answered with its status code
+}
+
+// routes are matched in order, the literal paths come before the templates they would match.
+var routes = []route{
+	{http.MethodGet, api.PathLookUp, (*Server).lookUpOrderID},
+	{http.MethodGet, api.PathTransactionHistory, (*Server).transactionHistory},
+	{http.MethodGet, api.PathGetAppTransactionInfo, (*Server).appTransactionInfo},
+	{http.MethodGet, api.PathTransactionInfo, (*Server).transactionInfo},
+	{http.MethodGet, api.PathRefundHistory, (*Server).refundHistory},
+	{http.MethodGet, api.PathGetStatusOfSubscriptionRenewalDate, (*Server).massExtensionStatus},
+	{http.MethodGet, api.PathGetALLSubscriptionStatus, (*Server).subscriptionStatuses},
+	{http.MethodPut, api.PathConsumptionInfoV2, (*Server).consumptionInfoV2},
+	{http.MethodPut, api.PathConsumptionInfo, (*Server).consumptionInfo},
+	{http.MethodPost, api.PathExtendSubscriptionRenewalDateForAll, (*Server).massExtension},
+	{http.MethodPut, api.PathExtendSubscriptionRenewalDate, (*Server).extendRenewalDate},
+	{http.MethodPost, api.PathGetNotificationHistory, (*Server).notificationHistory},
+	{http.MethodPost, api.PathRequestTestNotification, (*Server).requestTestNotification},
+	{http.MethodGet, api.PathGetTestNotificationStatus, (*Server).testNotificationStatus},
+	{http.MethodPut, api.PathSetAppAccountToken, (*Server).setAppAccountToken},
+	{http.MethodPost, api.PathFinishTransaction, (*Server).finishTransaction},
+}
+
+// ServeHTTP authenticates the request, then answers the next injected failure of its path, or serves it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.authorize(r); err != nil {
+		http.Error(w, "Unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	rt, ok := match(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if failures := s.failures[rt.path]; len(failures) > 0 {
+		s.failures[rt.path] = failures[1:]
+		s.writeFailure(w, failures[0])
+		return
+	}
+	if err := rt.serve(s, w, r); err != nil {
+		var apiErr *api.Error
+		if errors.As(err, &apiErr) {
+			writeError(w, apiErr)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// authorize checks the bearer token like Apple does: signed with the API key, for the issuer and the bundle ID,
+// and valid for at most an hour.
+func (s *Server) authorize(r *http.Request) error {
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return errors.New("no bearer token")
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(bearer, claims, func(*jwt.Token) (interface{}, error) {
+		return &s.key.PublicKey, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}),
+		jwt.WithAudience(tokenAudience),
+		jwt.WithIssuer(s.config.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return err
+	}
+	if kid, _ := token.Header["kid"].(string); kid != s.config.KeyID {
+		return fmt.Errorf("unknown key id %q", kid)
+	}
+	if bid, _ := claims["bid"].(string); bid != s.config.BundleID {
+		return fmt.Errorf("unknown bundle id %q", bid)
+	}
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return errors.New("no issued at")
+	}
+	expiresAt, _ := claims.GetExpirationTime()
+	if expiresAt.Sub(issuedAt.Time) > maxTokenLifetime {
+		return errors.New("token lifetime exceeds an hour")
+	}
+	return nil
+}
+
+// match returns the route of the request and sets its path values.
+func match(r *http.Request) (route, bool) {
+	segments := strings.Split(r.URL.Path, "/")
+	for _, rt := range routes {
+		if rt.method != r.Method {
+			continue
+		}
+		templateSegments := strings.Split(rt.path, "/")
+		if len(templateSegments) != len(segments) {
+			continue
+		}
+		values := make(map[string]string)
+		for i, t := range templateSegments {
+			if name, ok := strings.CutPrefix(t, "{"); ok && strings.HasSuffix(name, "}") && segments[i] != "" {
+				values[strings.TrimSuffix(name, "}")] = segments[i]
+				continue
+			}
+			if t != segments[i] {
+				values = nil
+				break
+			}
+		}
+		if values == nil {
+			continue
+		}
+		for name, value := range values {
+			r.SetPathValue(name, value)
+		}
+		return rt, true
+	}
+	return route{}, false
+}
+
+func (s *Server) writeFailure(w http.ResponseWriter, f Failure) {
+	if f.RetryAfter != 0 {
+		w.Header().Set("Retry-After", strconv.FormatInt(s.config.Now().Add(f.RetryAfter).UnixMilli(), 10))
+	}
+	if f.Err == nil {
+		status := f.StatusCode
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	writeError(w, f.Err)
+}
+
+// writeError answers an Apple error with the status code of its error code, such as 404 for 4040010.
+func writeError(w http.ResponseWriter, apiErr *api.Error) {
+	writeJSON(w, apiErr.ErrorCode()/10000, map[string]any{
+		"errorCode":    apiErr.ErrorCode(),
+		"errorMessage": apiErr.ErrorMessage(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,355 @@
+package apitest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, config *Config) (*Server, *api.StoreClient) {
+	t.Helper()
+	srv := NewServer(config)
+	t.Cleanup(srv.Close)
+	return srv, api.NewStoreClient(srv.StoreConfig())
+}
+
+func TestServer_Authorize(t *testing.T) {
+	t.Parallel()
+
+	srv, client := newTestServer(t, nil)
+	srv.AddTransaction(api.JWSTransaction{TransactionID: "1000"})
+
+	_, err := client.GetTransactionInfo(context.Background(), "1000")
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	tests := []struct {
+		Name      string
+		Configure func(config *api.StoreConfig)
+	}{
+		{Name: "other key", Configure: func(config *api.StoreConfig) {
+			config.KeyContent = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+		}},
+		{Name: "other key id", Configure: func(config *api.StoreConfig) { config.KeyID = "OTHER00001" }},
+		{Name: "other issuer", Configure: func(config *api.StoreConfig) { config.Issuer = uuid.NewString() }},
+		{Name: "other bundle id", Configure: func(config *api.StoreConfig) { config.BundleID = "com.example.other" }},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+			config := srv.StoreConfig()
+			test.Configure(config)
+
+			_, err := api.NewStoreClient(config).GetTransactionInfo(context.Background(), "1000")
+			assert.ErrorIs(t, err, api.ErrInvalidCredentials)
+		})
+	}
+}
+
+func TestServer_Transactions(t *testing.T) {
+	t.Parallel()
+
+	srv, client := newTestServer(t, nil)
+	start := time.Now().Add(-30 * 24 * time.Hour)
+	for i := range 25 {
+		srv.AddTransaction(api.JWSTransaction{
+			TransactionID:         fmt.Sprintf("%d", 1000+i),
+			OriginalTransactionId: "1000",
+			ProductID:             "com.example.monthly",
+			PurchaseDate:          start.Add(time.Duration(i) * time.Hour).UnixMilli(),
+			Type:                  api.AutoRenewable,
+		})
+	}
+	srv.AddTransaction(api.JWSTransaction{TransactionID: "2000", ProductID: "com.example.coins", Type: api.Consumable})
+	srv.AddOrder("MQ1", "1024")
+
+	t.Run("transaction info", func(t *testing.T) {
+		t.Parallel()
+		rsp, err := client.GetTransactionInfo(context.Background(), "1003")
+		require.NoError(t, err)
+		tran, err := client.ParseSignedTransaction(rsp.SignedTransactionInfo)
+		require.NoError(t, err)
+		assert.Equal(t, "1003", tran.TransactionID)
+		assert.Equal(t, "1000", tran.OriginalTransactionId)
+		assert.Equal(t, "com.example.app", tran.BundleID)
+		assert.Equal(t, api.Sandbox, tran.Environment)
+		assert.NotZero(t, tran.SignedDate)
+
+		_, err = client.GetTransactionInfo(context.Background(), "9999")
+		assert.ErrorIs(t, err, api.TransactionIdNotFoundError)
+	})
+
+	t.Run("untrusted root", func(t *testing.T) {
+		t.Parallel()
+		rsp, err := client.GetTransactionInfo(context.Background(), "1003")
+		require.NoError(t, err)
+
+		config := srv.StoreConfig()
+		config.RootCertificates = nil
+		_, err = api.NewStoreClient(config).ParseSignedTransaction(rsp.SignedTransactionInfo)
+		assert.Error(t, err)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		t.Parallel()
+		var ids []string
+		pages := 0
+		opts := &api.PageOptions{Delay: -1, OnPage: func(string, bool) { pages++ }}
+		for tran, err := range client.TransactionHistoryIter(context.Background(), "1010", nil, opts) {
+			require.NoError(t, err)
+			ids = append(ids, tran.TransactionID)
+		}
+		require.Len(t, ids, 25)
+		assert.Equal(t, "1000", ids[0])
+		assert.Equal(t, "1024", ids[24])
+		assert.Equal(t, 2, pages)
+	})
+
+	t.Run("order lookup", func(t *testing.T) {
+		t.Parallel()
+		rsp, err := client.LookupOrderID(context.Background(), "MQ1")
+		require.NoError(t, err)
+		assert.Equal(t, 0, rsp.Status)
+		require.Len(t, rsp.SignedTransactions, 1)
+
+		rsp, err = client.LookupOrderID(context.Background(), "MQ2")
+		require.NoError(t, err)
+		assert.Equal(t, 1, rsp.Status)
+	})
+
+	t.Run("finish transaction", func(t *testing.T) {
+		t.Parallel()
+		_, err := client.FinishTransaction(context.Background(), "2000")
+		require.NoError(t, err)
+		assert.True(t, srv.Finished("2000"))
+	})
+}
+
+func TestServer_Fail(t *testing.T) {
+	t.Parallel()
+
+	t.Run("apple error", func(t *testing.T) {
+		t.Parallel()
+		srv, client := newTestServer(t, nil)
+		srv.AddTransaction(api.JWSTransaction{TransactionID: "1000"})
+		srv.Fail(api.PathTransactionInfo, Failure{Err: api.RateLimitExceededError, RetryAfter: time.Minute})
+
+		_, err := client.GetTransactionInfo(context.Background(), "1000")
+		require.ErrorIs(t, err, api.RateLimitExceededError)
+		var apiErr *api.Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.InDelta(t, time.Now().Add(time.Minute).UnixMilli(), apiErr.RetryAfter(), float64(5*time.Second/time.Millisecond))
+
+		_, err = client.GetTransactionInfo(context.Background(), "1000")
+		assert.NoError(t, err)
+	})
+
+	t.Run("retried", func(t *testing.T) {
+		t.Parallel()
+		srv := NewServer(nil)
+		t.Cleanup(srv.Close)
+		config := srv.StoreConfig()
+		config.RetryPolicy = &api.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+		client := api.NewStoreClient(config)
+		srv.AddTransaction(api.JWSTransaction{TransactionID: "1000"})
+		srv.Fail(api.PathTransactionInfo,
+			Failure{Err: api.RateLimitExceededError, RetryAfter: 10 * time.Millisecond},
+			Failure{StatusCode: http.StatusServiceUnavailable},
+		)
+
+		_, err := client.GetTransactionInfo(context.Background(), "1000")
+		assert.NoError(t, err)
+	})
+
+	t.Run("status code", func(t *testing.T) {
+		t.Parallel()
+		srv, client := newTestServer(t, nil)
+		srv.Fail(api.PathLookUp, Failure{StatusCode: http.StatusUnauthorized})
+
+		_, err := client.LookupOrderID(context.Background(), "MQ1")
+		assert.ErrorIs(t, err, api.ErrInvalidCredentials)
+	})
+}
+
+func TestServer_Subscriptions(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	srv, client := newTestServer(t, &Config{Now: func() time.Time { return now }})
+	subscription := func(id string, expires time.Time, ownership string) api.JWSTransaction {
+		return api.JWSTransaction{
+			TransactionID:               id,
+			AppTransactionId:            "700",
+			ProductID:                   "com.example.monthly",
+			SubscriptionGroupIdentifier: "21000000",
+			Type:                        api.AutoRenewable,
+			PurchaseDate:                expires.AddDate(0, -1, 0).UnixMilli(),
+			ExpiresDate:                 expires.UnixMilli(),
+			InAppOwnershipType:          ownership,
+		}
+	}
+	srv.AddTransaction(
+		subscription("1000", now.AddDate(0, 0, 10), "PURCHASED"),
+		subscription("2000", now.AddDate(0, 0, -10), "PURCHASED"),
+		subscription("3000", now.AddDate(0, 0, 10), familyShared),
+	)
+
+	rsp, err := client.GetALLSubscriptionStatuses(context.Background(), "1000", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1234567890), rsp.AppAppleId)
+	require.Len(t, rsp.Data, 1)
+	statuses := make(map[string]api.AutoRenewSubscriptionStatus)
+	for _, item := range rsp.Data[0].LastTransactions {
+		statuses[item.OriginalTransactionId] = item.Status
+		_, err := client.ParseSignedRenewalInfo(item.SignedRenewalInfo)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, map[string]api.AutoRenewSubscriptionStatus{
+		"1000": api.SubscriptionActive,
+		"2000": api.SubscriptionExpired,
+		"3000": api.SubscriptionActive,
+	}, statuses)
+
+	extend := func(originalTransactionID, requestIdentifier string) (*api.ExtendRenewalDateResponse, error) {
+		_, rsp, err := client.ExtendSubscriptionRenewalDateWithResponse(context.Background(), originalTransactionID, api.ExtendRenewalDateRequest{
+			ExtendByDays:      5,
+			ExtendReasonCode:  api.ServiceIssueOrOutage,
+			RequestIdentifier: requestIdentifier,
+		})
+		return rsp, err
+	}
+
+	extended, err := extend("1000", "a")
+	require.NoError(t, err)
+	assert.True(t, extended.Success)
+	assert.Equal(t, now.AddDate(0, 0, 15).UnixMilli(), extended.EffectiveDate)
+	tran, ok := srv.Transaction("1000")
+	require.True(t, ok)
+	assert.Equal(t, now.AddDate(0, 0, 15).UnixMilli(), tran.ExpiresDate)
+
+	again, err := extend("1000", "a")
+	require.NoError(t, err)
+	assert.Equal(t, extended, again)
+
+	_, err = extend("1000", "b")
+	require.NoError(t, err)
+	_, err = extend("1000", "c")
+	assert.ErrorIs(t, err, api.SubscriptionMaxExtensionError)
+	_, err = extend("2000", "a")
+	assert.ErrorIs(t, err, api.SubscriptionExtensionIneligibleError)
+	_, err = extend("3000", "a")
+	assert.ErrorIs(t, err, api.FamilySharedSubscriptionExtensionIneligibleError)
+	_, err = extend("9000", "a")
+	assert.ErrorIs(t, err, api.OriginalTransactionIdNotFoundError)
+
+	token := uuid.NewString()
+	_, err = client.SetAppAccountToken(context.Background(), "2000", api.UpdateAppAccountTokenRequest{AppAccountToken: token})
+	require.NoError(t, err)
+	tran, _ = srv.Transaction("2000")
+	assert.Equal(t, token, tran.AppAccountToken)
+}
+
+func TestServer_Consumption(t *testing.T) {
+	t.Parallel()
+
+	srv, client := newTestServer(t, nil)
+	srv.AddTransaction(
+		api.JWSTransaction{TransactionID: "1000", ProductID: "com.example.coins", Type: api.Consumable},
+		api.JWSTransaction{TransactionID: "2000", ProductID: "com.example.coins", Type: api.Consumable, InAppOwnershipType: familyShared},
+	)
+	body := api.ConsumptionRequest{CustomerConsented: true, DeliveryStatus: api.DELIVERED}
+
+	statusCode, err := client.SendConsumptionInfoV2(context.Background(), "1000", body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, statusCode)
+	sent, ok := srv.ConsumptionInfo("1000")
+	require.True(t, ok)
+	assert.Equal(t, body, sent)
+
+	_, err = client.SendConsumptionInfoV2(context.Background(), "2000", body)
+	assert.ErrorIs(t, err, api.FamilyTransactionNotSupportedError)
+	_, err = client.SendConsumptionInfoV2(context.Background(), "1000", api.ConsumptionRequest{CustomerConsented: true})
+	assert.ErrorIs(t, err, api.InvalidDeliveryStatusError)
+}
+
+func TestServer_Notifications(t *testing.T) {
+	t.Parallel()
+
+	srv, client := newTestServer(t, &Config{TestNotificationResult: api.FirstSendAttemptResultTimedOut})
+	srv.AddTransaction(api.JWSTransaction{TransactionID: "1000"})
+	signedTransaction, err := client.GetTransactionInfo(context.Background(), "1000")
+	require.NoError(t, err)
+
+	now := time.Now()
+	notify := func(uuid string, notificationType appstore.NotificationTypeV2, attempts ...api.SendAttemptItem) {
+		srv.AddNotification(&appstore.SubscriptionNotificationV2DecodedPayload{
+			NotificationType: notificationType,
+			NotificationUUID: uuid,
+			SignedDate:       now.Add(-time.Hour).UnixMilli(),
+			Data:             appstore.SubscriptionNotificationV2Data{SignedTransactionInfo: appstore.JWSTransaction(signedTransaction.SignedTransactionInfo)},
+		}, attempts...)
+	}
+	notify("delivered", appstore.NotificationTypeV2Subscribed)
+	notify("failed", appstore.NotificationTypeV2DidRenew,
+		api.SendAttemptItem{SendAttemptResult: api.FirstSendAttemptResultTimedOut},
+		api.SendAttemptItem{SendAttemptResult: api.FirstSendAttemptResultNoResponse},
+	)
+
+	result, err := api.CheckNotificationEndpoint(context.Background(), client, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, api.FirstSendAttemptResultTimedOut, result)
+
+	tests := []struct {
+		Name    string
+		Request api.NotificationHistoryRequest
+		Want    []string
+	}{
+		{Name: "all", Want: []string{"delivered", "failed", ""}},
+		{Name: "only failures", Request: api.NotificationHistoryRequest{OnlyFailures: true}, Want: []string{"failed", ""}},
+		{Name: "type", Request: api.NotificationHistoryRequest{NotificationType: appstore.NotificationTypeV2DidRenew}, Want: []string{"failed"}},
+		{Name: "transaction", Request: api.NotificationHistoryRequest{TransactionId: "1000"}, Want: []string{"delivered", "failed"}},
+	}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+			body := test.Request
+			body.StartDate = now.Add(-2 * time.Hour).UnixMilli()
+			body.EndDate = now.Add(time.Hour).UnixMilli()
+
+			var got []string
+			for item, err := range client.NotificationHistoryIter(context.Background(), body, &api.PageOptions{Delay: -1}) {
+				require.NoError(t, err)
+				notification, err := client.DecodeNotificationV2(item.SignedPayload)
+				require.NoError(t, err)
+				if notification.NotificationType == appstore.NotificationTypeV2Test {
+					got = append(got, "")
+					continue
+				}
+				got = append(got, notification.NotificationUUID)
+				require.NotNil(t, notification.Data.Transaction)
+				assert.Equal(t, "1000", notification.Data.Transaction.TransactionID)
+			}
+			assert.Equal(t, test.Want, got)
+		})
+	}
+
+	_, err = client.GetNotificationHistory(context.Background(), api.NotificationHistoryRequest{StartDate: now.UnixMilli()}, "")
+	assert.ErrorIs(t, err, api.InvalidEndDateError)
+}
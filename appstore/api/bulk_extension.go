@@ -0,0 +1,349 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultBulkExtensionConcurrency = 4
+
+// bulkExtensionNamespace is the UUID namespace of the request identifiers derived from the item keys.
+var bulkExtensionNamespace = uuid.MustParse("6f0c4a8e-3b5d-4e0a-9a8c-2f1d7b6e5c41")
+
+// ExtensionErrorClass buckets the outcome of a renewal date extension.
+type ExtensionErrorClass string
+
+const (
+	ExtensionErrorNone           ExtensionErrorClass = ""                // The renewal date was extended
+	ExtensionErrorMaxExtensions  ExtensionErrorClass = "max_extensions"  // The subscription reached the maximum number of extensions
+	ExtensionErrorIneligible     ExtensionErrorClass = "ineligible"      // The subscription state is ineligible, such as expired
+	ExtensionErrorFamilyShared   ExtensionErrorClass = "family_shared"   // The subscription is obtained through Family Sharing
+	ExtensionErrorNotFound       ExtensionErrorClass = "not_found"       // The original transaction ID is unknown
+	ExtensionErrorInvalidRequest ExtensionErrorClass = "invalid_request" // Apple rejected the request as invalid
+	ExtensionErrorNotExtended    ExtensionErrorClass = "not_extended"    // Apple answered without extending the renewal date
+	ExtensionErrorRetryable      ExtensionErrorClass = "retryable"       // A transient failure, the item is tried again by the next run
+	ExtensionErrorOther          ExtensionErrorClass = "other"           // Any other failure
+)
+
+// classifyExtensionError returns the class of an ExtendSubscriptionRenewalDate error.
+func classifyExtensionError(err error) ExtensionErrorClass {
+	var apiErr *Error
+	switch {
+	case errors.Is(err, SubscriptionMaxExtensionError):
+		return ExtensionErrorMaxExtensions
+	case errors.Is(err, SubscriptionExtensionIneligibleError):
+		return ExtensionErrorIneligible
+	case errors.Is(err, FamilySharedSubscriptionExtensionIneligibleError):
+		return ExtensionErrorFamilyShared
+	case errors.Is(err, OriginalTransactionIdNotFoundError):
+		return ExtensionErrorNotFound
+	case transient(err):
+		return ExtensionErrorRetryable
+	case errors.As(err, &apiErr) && apiErr.ErrorCode()/1000 == 4000:
+		return ExtensionErrorInvalidRequest
+	}
+	return ExtensionErrorOther
+}
+
+// BulkExtensionClient is the part of the App Store Server API a BulkExtender calls, such as a StoreClient or an APIClient.
+type BulkExtensionClient interface {
+	ExtendSubscriptionRenewalDateWithResponse(ctx context.Context, originalTransactionId string, body ExtendRenewalDateRequest) (statusCode int, rsp *ExtendRenewalDateResponse, err error)
+}
+
+// BulkExtensionItem is a subscription to extend.
+type BulkExtensionItem struct {
+	Key                   string // Idempotency key of the item, such as a support ticket ID. Default is OriginalTransactionID.
+	OriginalTransactionID string // The original transaction ID of the subscription
+}
+
+// BulkExtensionOutcome is the outcome of an item, a row of the report.
+type BulkExtensionOutcome struct {
+	Key                   string              `json:"key"`
+	OriginalTransactionID string              `json:"originalTransactionId"`
+	RequestIdentifier     string              `json:"requestIdentifier"`
+	EffectiveDate         int64               `json:"effectiveDate,omitempty"` // The new renewal date, UNIX time in milliseconds
+	WebOrderLineItemID    string              `json:"webOrderLineItemId,omitempty"`
+	ErrorClass            ExtensionErrorClass `json:"errorClass,omitempty"`
+	ErrorCode             int                 `json:"errorCode,omitempty"` // The App Store Server API error code, if any
+	Error                 string              `json:"error,omitempty"`
+}
+
+// Succeeded reports whether the renewal date was extended.
+func (o *BulkExtensionOutcome) Succeeded() bool {
+	return o.ErrorClass == ExtensionErrorNone
+}
+
+// BulkExtensionCheckpoint records the finished items of a bulk extension, so that a new run skips them.
+// An item that failed with a retryable error isn't finished. The BulkExtender serializes its calls.
+type BulkExtensionCheckpoint interface {
+	Get(key string) (outcome BulkExtensionOutcome, ok bool, err error)
+	Put(outcome BulkExtensionOutcome) error
+}
+
+// BulkExtender extends the renewal date of many subscriptions one by one with ExtendSubscriptionRenewalDate,
+// running a limited number of requests at once. The request identifier of an item is derived from Campaign and
+// the item key, so a request repeated after a crash is recognized by Apple instead of extending the subscription twice.
+type BulkExtender struct {
+	Client           BulkExtensionClient
+	Campaign         string           // Name of the compensation, the same key in another campaign is another request
+	ExtendByDays     int32            // Number of days to extend, from 1 to 90
+	ExtendReasonCode ExtendReasonCode // The reason of the extension
+	Concurrency      int              // Maximum number of requests at once. Default is 4.
+	// Checkpoint, if not nil, is asked for the outcome of each item before extending it, and given the finished outcomes.
+	Checkpoint BulkExtensionCheckpoint
+}
+
+// BulkExtensionReport is the outcome of a bulk extension.
+type BulkExtensionReport struct {
+	Outcomes []BulkExtensionOutcome      // The outcome of every processed item, in input order, including the checkpointed ones
+	Classes  map[ExtensionErrorClass]int // Number of outcomes per class, ExtensionErrorNone counts the extended subscriptions
+}
+
+// RequestIdentifier returns the request identifier of the item key in the campaign.
+func (b *BulkExtender) RequestIdentifier(key string) string {
+	return uuid.NewSHA1(bulkExtensionNamespace, []byte(b.Campaign+"\x00"+key)).String()
+}
+
+// Run extends the items, skipping those the checkpoint has finished and the repeated keys.
+// It returns the report of the processed items, with the error of ctx or the checkpoint if the run stopped early.
+// Failed extensions are reported in the outcomes, they aren't errors of Run.
+func (b *BulkExtender) Run(ctx context.Context, items []BulkExtensionItem) (*BulkExtensionReport, error) {
+	if b.ExtendByDays < 1 || b.ExtendByDays > maxExtendByDays {
+		return nil, InvalidExtendByDaysError
+	}
+	if b.ExtendReasonCode < UndeclaredExtendReasonCode || b.ExtendReasonCode > ServiceIssueOrOutage {
+		return nil, InvalidExtendReasonCodeError
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		mu       sync.Mutex // serializes the checkpoint calls
+		outcomes = make([]*BulkExtensionOutcome, len(items))
+		seen     = make(map[string]bool, len(items))
+		sem      = make(chan struct{}, b.concurrency())
+		wg       sync.WaitGroup
+	)
+	for i, item := range items {
+		if item.Key == "" {
+			item.Key = item.OriginalTransactionID
+		}
+		if seen[item.Key] {
+			continue
+		}
+		seen[item.Key] = true
+
+		mu.Lock()
+		checkpointed, ok, err := b.checkpointed(item.Key)
+		mu.Unlock()
+		if err != nil {
+			cancel(err)
+			break
+		}
+		if ok {
+			outcomes[i] = &checkpointed
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			outcome := b.extend(ctx, item)
+			if ctx.Err() != nil && outcome.ErrorClass == ExtensionErrorRetryable {
+				// Stopped by the run, not by Apple.
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			outcomes[i] = &outcome
+			if b.Checkpoint != nil && outcome.ErrorClass != ExtensionErrorRetryable {
+				if err := b.Checkpoint.Put(outcome); err != nil {
+					cancel(fmt.Errorf("appstore bulk extension checkpoint: %w", err))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := &BulkExtensionReport{Classes: make(map[ExtensionErrorClass]int)}
+	for _, outcome := range outcomes {
+		if outcome != nil {
+			report.Outcomes = append(report.Outcomes, *outcome)
+			report.Classes[outcome.ErrorClass]++
+		}
+	}
+	return report, context.Cause(ctx)
+}
+
+func (b *BulkExtender) checkpointed(key string) (BulkExtensionOutcome, bool, error) {
+	if b.Checkpoint == nil {
+		return BulkExtensionOutcome{}, false, nil
+	}
+	outcome, ok, err := b.Checkpoint.Get(key)
+	if err != nil {
+		return BulkExtensionOutcome{}, false, fmt.Errorf("appstore bulk extension checkpoint: %w", err)
+	}
+	return outcome, ok, nil
+}
+
+// extend extends the subscription of item and returns its outcome.
+func (b *BulkExtender) extend(ctx context.Context, item BulkExtensionItem) BulkExtensionOutcome {
+	outcome := BulkExtensionOutcome{
+		Key:                   item.Key,
+		OriginalTransactionID: item.OriginalTransactionID,
+		RequestIdentifier:     b.RequestIdentifier(item.Key),
+	}
+	_, rsp, err := b.Client.ExtendSubscriptionRenewalDateWithResponse(ctx, item.OriginalTransactionID, ExtendRenewalDateRequest{
+		ExtendByDays:      b.ExtendByDays,
+		ExtendReasonCode:  b.ExtendReasonCode,
+		RequestIdentifier: outcome.RequestIdentifier,
+	})
+	switch {
+	case err != nil:
+		outcome.ErrorClass = classifyExtensionError(err)
+		if ctx.Err() != nil {
+			outcome.ErrorClass = ExtensionErrorRetryable
+		}
+		outcome.Error = err.Error()
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			outcome.ErrorCode = apiErr.ErrorCode()
+		}
+	case rsp == nil || !rsp.Success:
+		outcome.ErrorClass = ExtensionErrorNotExtended
+	default:
+		outcome.EffectiveDate = rsp.EffectiveDate
+		outcome.WebOrderLineItemID = rsp.WebOrderLineItemId
+	}
+	return outcome
+}
+
+func (b *BulkExtender) concurrency() int {
+	if b.Concurrency <= 0 {
+		return defaultBulkExtensionConcurrency
+	}
+	return b.Concurrency
+}
+
+// WriteJSONL writes the outcomes as JSON lines.
+func (r *BulkExtensionReport) WriteJSONL(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, outcome := range r.Outcomes {
+		if err := enc.Encode(outcome); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes the outcomes as CSV with a header row, the effective date is formatted in RFC 3339.
+func (r *BulkExtensionReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"key", "original_transaction_id", "request_identifier", "effective_date", "error_class", "error_code", "error"})
+	for _, o := range r.Outcomes {
+		var effectiveDate, errorCode string
+		if o.EffectiveDate != 0 {
+			effectiveDate = time.UnixMilli(o.EffectiveDate).UTC().Format(time.RFC3339)
+		}
+		if o.ErrorCode != 0 {
+			errorCode = strconv.Itoa(o.ErrorCode)
+		}
+		_ = cw.Write([]string{o.Key, o.OriginalTransactionID, o.RequestIdentifier, effectiveDate, string(o.ErrorClass), errorCode, o.Error})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// FileCheckpoint is a BulkExtensionCheckpoint appending the finished outcomes to a JSON lines file,
+// which doubles as the JSONL report of the finished items.
+type FileCheckpoint struct {
+	f        *os.File
+	outcomes map[string]BulkExtensionOutcome
+}
+
+// OpenFileCheckpoint opens the checkpoint file at path, creating it if needed, and loads its outcomes.
+// A last line cut by a crash is dropped.
+func OpenFileCheckpoint(path string) (*FileCheckpoint, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	complete := data[:bytes.LastIndexByte(data, '\n')+1]
+	if len(complete) != len(data) {
+		if err := f.Truncate(int64(len(complete))); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	if _, err := f.Seek(int64(len(complete)), io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	c := &FileCheckpoint{f: f, outcomes: make(map[string]BulkExtensionOutcome)}
+	scanner := bufio.NewScanner(bytes.NewReader(complete))
+	for line := 1; scanner.Scan(); line++ {
+		var outcome BulkExtensionOutcome
+		if err := json.Unmarshal(scanner.Bytes(), &outcome); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		c.outcomes[outcome.Key] = outcome
+	}
+	if err := scanner.Err(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *FileCheckpoint) Get(key string) (BulkExtensionOutcome, bool, error) {
+	outcome, ok := c.outcomes[key]
+	return outcome, ok, nil
+}
+
+func (c *FileCheckpoint) Put(outcome BulkExtensionOutcome) error {
+	line, err := json.Marshal(outcome)
+	if err != nil {
+		return err
+	}
+	if _, err := c.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	c.outcomes[outcome.Key] = outcome
+	return nil
+}
+
+// Close closes the checkpoint file.
+func (c *FileCheckpoint) Close() error {
+	return c.f.Close()
+}
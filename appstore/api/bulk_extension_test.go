@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBulkExtensionClient answers each original transaction ID with its error, or extends it.
+type fakeBulkExtensionClient struct {
+	mu       sync.Mutex
+	errs     map[string]error
+	bodies   map[string]ExtendRenewalDateRequest
+	inflight atomic.Int32
+	peak     int32 // Most requests at once
+}
+
+func (f *fakeBulkExtensionClient) ExtendSubscriptionRenewalDateWithResponse(_ context.Context, originalTransactionId string, body ExtendRenewalDateRequest) (int, *ExtendRenewalDateResponse, error) {
+	n := f.inflight.Add(1)
+	defer f.inflight.Add(-1)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.peak = max(f.peak, n)
+	if f.bodies == nil {
+		f.bodies = make(map[string]ExtendRenewalDateRequest)
+	}
+	f.bodies[originalTransactionId] = body
+	if err := f.errs[originalTransactionId]; err != nil {
+		return http.StatusForbidden, nil, err
+	}
+	return http.StatusOK, &ExtendRenewalDateResponse{
+		OriginalTransactionId: originalTransactionId,
+		WebOrderLineItemId:    "wol-" + originalTransactionId,
+		Success:               true,
+		EffectiveDate:         1698148900000,
+	}, nil
+}
+
+func TestClassifyExtensionError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Err   error
+		Class ExtensionErrorClass
+	}{
+		{Err: SubscriptionMaxExtensionError, Class: ExtensionErrorMaxExtensions},
+		{Err: SubscriptionExtensionIneligibleError, Class: ExtensionErrorIneligible},
+		{Err: FamilySharedSubscriptionExtensionIneligibleError, Class: ExtensionErrorFamilyShared},
+		{Err: OriginalTransactionIdNotFoundError, Class: ExtensionErrorNotFound},
+		{Err: GeneralInternalRetryableError, Class: ExtensionErrorRetryable},
+		{Err: &HTTPError{StatusCode: http.StatusBadGateway}, Class: ExtensionErrorRetryable},
+		{Err: InvalidExtendByDaysError, Class: ExtensionErrorInvalidRequest},
+		{Err: errors.New("connection reset"), Class: ExtensionErrorOther},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.Class, classifyExtensionError(fmt.Errorf("extend: %w", tt.Err)), tt.Err.Error())
+	}
+}
+
+func TestBulkExtender_Run(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeBulkExtensionClient{errs: map[string]error{
+		"1002": SubscriptionMaxExtensionError,
+		"1003": FamilySharedSubscriptionExtensionIneligibleError,
+		"1004": GeneralInternalRetryableError,
+	}}
+	b := &BulkExtender{
+		Client:           client,
+		Campaign:         "outage-2023-10",
+		ExtendByDays:     3,
+		ExtendReasonCode: ServiceIssueOrOutage,
+		Concurrency:      2,
+	}
+	items := []BulkExtensionItem{
+		{Key: "ticket-1", OriginalTransactionID: "1001"},
+		{OriginalTransactionID: "1002"},
+		{OriginalTransactionID: "1003"},
+		{OriginalTransactionID: "1004"},
+		{Key: "ticket-1", OriginalTransactionID: "1001"},
+	}
+	for i := range 20 {
+		items = append(items, BulkExtensionItem{OriginalTransactionID: fmt.Sprint(2000 + i)})
+	}
+
+	report, err := b.Run(context.Background(), items)
+	require.NoError(t, err)
+	require.Len(t, report.Outcomes, 24)
+	assert.LessOrEqual(t, client.peak, int32(2))
+	assert.Equal(t, map[ExtensionErrorClass]int{
+		ExtensionErrorNone:          21,
+		ExtensionErrorMaxExtensions: 1,
+		ExtensionErrorFamilyShared:  1,
+		ExtensionErrorRetryable:     1,
+	}, report.Classes)
+
+	first := report.Outcomes[0]
+	assert.True(t, first.Succeeded())
+	assert.Equal(t, "ticket-1", first.Key)
+	assert.Equal(t, int64(1698148900000), first.EffectiveDate)
+	assert.Equal(t, b.RequestIdentifier("ticket-1"), first.RequestIdentifier)
+	assert.Equal(t, ExtendRenewalDateRequest{ExtendByDays: 3, ExtendReasonCode: ServiceIssueOrOutage, RequestIdentifier: first.RequestIdentifier}, client.bodies["1001"])
+
+	maxed := report.Outcomes[1]
+	assert.Equal(t, "1002", maxed.Key)
+	assert.Equal(t, ExtensionErrorMaxExtensions, maxed.ErrorClass)
+	assert.Equal(t, SubscriptionMaxExtensionError.ErrorCode(), maxed.ErrorCode)
+}
+
+func TestBulkExtender_RequestIdentifier(t *testing.T) {
+	t.Parallel()
+
+	b := &BulkExtender{Campaign: "outage-2023-10"}
+	assert.Equal(t, b.RequestIdentifier("ticket-1"), b.RequestIdentifier("ticket-1"))
+	assert.NotEqual(t, b.RequestIdentifier("ticket-1"), b.RequestIdentifier("ticket-2"))
+	assert.NotEqual(t, b.RequestIdentifier("ticket-1"), (&BulkExtender{Campaign: "other"}).RequestIdentifier("ticket-1"))
+}
+
+func TestBulkExtender_Checkpoint(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+	items := []BulkExtensionItem{
+		{OriginalTransactionID: "1001"},
+		{OriginalTransactionID: "1002"},
+		{OriginalTransactionID: "1003"},
+	}
+
+	checkpoint, err := OpenFileCheckpoint(path)
+	require.NoError(t, err)
+	client := &fakeBulkExtensionClient{errs: map[string]error{
+		"1002": SubscriptionExtensionIneligibleError,
+		"1003": GeneralInternalRetryableError,
+	}}
+	b := &BulkExtender{Client: client, ExtendByDays: 3, Checkpoint: checkpoint}
+	_, err = b.Run(context.Background(), items)
+	require.NoError(t, err)
+	require.NoError(t, checkpoint.Close())
+
+	// A crash while writing leaves a cut line behind.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"key":"10`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	checkpoint, err = OpenFileCheckpoint(path)
+	require.NoError(t, err)
+	defer checkpoint.Close()
+	client = &fakeBulkExtensionClient{}
+	b.Client = client
+	b.Checkpoint = checkpoint
+	report, err := b.Run(context.Background(), items)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"1003"}, slices.Collect(maps.Keys(client.bodies)), "only the retryable item is sent again")
+	assert.Equal(t, map[ExtensionErrorClass]int{ExtensionErrorNone: 2, ExtensionErrorIneligible: 1}, report.Classes)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(data), "\n"))
+	assert.True(t, strings.HasSuffix(string(data), "\n"))
+}
+
+func TestBulkExtender_ContextDone(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b := &BulkExtender{Client: &fakeBulkExtensionClient{}, ExtendByDays: 3}
+	report, err := b.Run(ctx, []BulkExtensionItem{{OriginalTransactionID: "1001"}})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, report.Outcomes)
+
+	_, err = b.Run(context.Background(), nil)
+	assert.NoError(t, err)
+	b.ExtendByDays = 0
+	_, err = b.Run(context.Background(), nil)
+	assert.ErrorIs(t, err, InvalidExtendByDaysError)
+}
+
+func TestBulkExtensionReport_Write(t *testing.T) {
+	t.Parallel()
+
+	report := &BulkExtensionReport{Outcomes: []BulkExtensionOutcome{
+		{Key: "k1", OriginalTransactionID: "1001", RequestIdentifier: "r1", EffectiveDate: 1698148900000},
+		{Key: "k2", OriginalTransactionID: "1002", RequestIdentifier: "r2", ErrorClass: ExtensionErrorMaxExtensions, ErrorCode: 4030005, Error: "maxed, out"},
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteCSV(&buf))
+	assert.Equal(t, "key,original_transaction_id,request_identifier,effective_date,error_class,error_code,error\n"+
+		"k1,1001,r1,2023-10-24T12:01:40Z,,,\n"+
+		"k2,1002,r2,,max_extensions,4030005,\"maxed, out\"\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, report.WriteJSONL(&buf))
+	assert.Equal(t, `{"key":"k1","originalTransactionId":"1001","requestIdentifier":"r1","effectiveDate":1698148900000}`+"\n"+
+		`{"key":"k2","originalTransactionId":"1002","requestIdentifier":"r2","errorClass":"max_extensions","errorCode":4030005,"error":"maxed, out"}`+"\n", buf.String())
+}
+
+func TestStoreClient_ExtendSubscriptionRenewalDateWithResponse(t *testing.T) {
+	t.Parallel()
+
+	client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/inApps/v1/subscriptions/extend/1000", r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"extendByDays":3,"extendReasonCode":3,"requestIdentifier":"id"}`, string(body))
+		_, _ = io.WriteString(w, `{"originalTransactionId":"1000","webOrderLineItemId":"2000","success":true,"effectiveDate":1698148900000}`)
+	}), nil)
+
+	statusCode, rsp, err := client.ExtendSubscriptionRenewalDateWithResponse(context.Background(), "1000", ExtendRenewalDateRequest{
+		ExtendByDays:      3,
+		ExtendReasonCode:  ServiceIssueOrOutage,
+		RequestIdentifier: "id",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, &ExtendRenewalDateResponse{OriginalTransactionId: "1000", WebOrderLineItemId: "2000", Success: true, EffectiveDate: 1698148900000}, rsp)
+}
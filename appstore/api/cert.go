@@ -1,11 +1,19 @@
 package api
 
 import (
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
 )
 
 // rootPEM is generated through `openssl x509 -inform der -in AppleRootCA-G3.cer -out apple_root.pem`
@@ -27,43 +35,165 @@ at+qIxUCMG1mihDK1A3UT82NQz60imOlM27jbdoXt2QfyFMm+YhidDkLF1vLUagM
 -----END CERTIFICATE-----
 `
 
+// Apple marks the certificates used for signing App Store data with these extensions.
+var (
+	oidAppleLeafCert         = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+	oidAppleIntermediateCert = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+)
+
 type Cert struct {
+	// roots are the trusted root certificates, Apple Root CA - G3 is used when nil.
+	roots *x509.CertPool
+	// rootsErr is the error parsing the configured root certificates, no chain is trusted when set.
+	rootsErr error
+	// ocsp optionally checks the revocation status of the leaf and intermediate certificates.
+	ocsp *appstore.OCSPChecker
+	// verificationTime selects the time the chain is verified at.
+	verificationTime appstore.VerificationTime
+	// now is the time source for the current time, time.Now is used when nil.
+	now func() time.Time
+	// chains caches the verified chains by leaf fingerprint, nothing is cached when nil.
+	chains *chainCache
+}
+
+// newCert creates a Cert trusting the given DER or PEM encoded root certificates.
+func newCert(rootCertificates [][]byte) (*Cert, error) {
+	if len(rootCertificates) == 0 {
+		return &Cert{chains: newChainCache(defaultChainCacheSize)}, nil
+	}
+
+	roots := x509.NewCertPool()
+	for _, b := range rootCertificates {
+		if block, _ := pem.Decode(b); block != nil {
+			b = block.Bytes
+		}
+		root, err := x509.ParseCertificate(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse root certificate: %w", err)
+		}
+		roots.AddCert(root)
+	}
+	return &Cert{roots: roots, chains: newChainCache(defaultChainCacheSize)}, nil
+}
+
+// verificationTimeOf returns the time to verify the chain of the JWS token at.
+func (c *Cert) verificationTimeOf(tokenStr string) time.Time {
+	now := time.Now()
+	if c.now != nil {
+		now = c.now()
+	}
+	return c.verificationTime.Time(tokenStr, now)
 }
 
-func (c *Cert) extractCertByIndex(tokenStr string, index int) ([]byte, error) {
-	if index > 2 {
-		return nil, errors.New("invalid index")
+// appleRootPool parses the embedded Apple Root CA - G3 once.
+var appleRootPool = sync.OnceValues(func() (*x509.CertPool, error) {
+	roots := x509.NewCertPool()
+	if ok := roots.AppendCertsFromPEM([]byte(rootPEM)); !ok {
+		return nil, errors.New("failed to parse root certificate")
+	}
+	return roots, nil
+})
+
+func (c *Cert) rootPool() (*x509.CertPool, error) {
+	if c.rootsErr != nil {
+		return nil, c.rootsErr
 	}
+	if c.roots != nil {
+		return c.roots, nil
+	}
+	return appleRootPool()
+}
 
-	tokenArr := strings.Split(tokenStr, ".")
-	headerByte, err := base64.RawStdEncoding.DecodeString(tokenArr[0])
+// parseJWSHeader decodes the protected header of the JWS token.
+func parseJWSHeader(tokenStr string) (*JWSDecodedHeader, error) {
+	encoded, _, ok := strings.Cut(tokenStr, ".")
+	if !ok {
+		return nil, errors.New("token is not a JWS compact serialization")
+	}
+	headerByte, err := base64.RawURLEncoding.DecodeString(encoded)
 	if err != nil {
 		return nil, err
 	}
 
-	type Header struct {
-		Alg string   `json:"alg"`
-		X5c []string `json:"x5c"`
+	var header JWSDecodedHeader
+	if err = json.Unmarshal(headerByte, &header); err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+// parseX5C parses the leaf, intermediate and root certificates of the x5c header.
+func parseX5C(x5c []string) (leaf, intermediate, root *x509.Certificate, err error) {
+	if len(x5c) != 3 {
+		return nil, nil, nil, fmt.Errorf("x5c header must contain 3 certificates, got %d", len(x5c))
 	}
-	var header Header
-	err = json.Unmarshal(headerByte, &header)
+
+	certs := make([]*x509.Certificate, len(x5c))
+	for i, v := range x5c {
+		der, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if certs[i], err = x509.ParseCertificate(der); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return certs[0], certs[1], certs[2], nil
+}
+
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyX5C verifies the x5c chain at the given time and returns its leaf certificate.
+// check, if not nil, is run on the parsed certificates before the chain is verified.
+// Verified chains are cached by leaf fingerprint, so that tokens signed by the same certificates,
+// like the items of a history page, only verify the chain once while it is valid.
+func (c *Cert) verifyX5C(x5c []string, at time.Time, check func(leaf, intermediate, root *x509.Certificate) error) (*x509.Certificate, error) {
+	if len(x5c) != 3 {
+		return nil, fmt.Errorf("x5c header must contain 3 certificates, got %d", len(x5c))
+	}
+	leafDER, err := base64.StdEncoding.DecodeString(x5c[0])
 	if err != nil {
 		return nil, err
 	}
+	key := sha256.Sum256(leafDER)
+
+	if c.chains != nil {
+		if v, ok := c.chains.get(key, at); ok {
+			return v.chain[0], c.checkRevocation(v.chain)
+		}
+	}
 
-	certByte, err := base64.StdEncoding.DecodeString(header.X5c[index])
+	leaf, intermediate, root, err := parseX5C(x5c)
 	if err != nil {
 		return nil, err
 	}
-
-	return certByte, nil
+	if check != nil {
+		if err = check(leaf, intermediate, root); err != nil {
+			return nil, err
+		}
+	}
+	chain, err := c.verifyCert(root, intermediate, leaf, at)
+	if err != nil {
+		return nil, err
+	}
+	if c.chains != nil {
+		c.chains.add(newVerifiedChain(key, chain))
+	}
+	return leaf, c.checkRevocation(chain)
 }
 
-func (c *Cert) verifyCert(rootCert, intermediaCert, leafCert *x509.Certificate) error {
-	roots := x509.NewCertPool()
-	ok := roots.AppendCertsFromPEM([]byte(rootPEM))
-	if !ok {
-		return errors.New("failed to parse root certificate")
+// verifyCert verifies the chain at the given time and returns the verified chain, leaf first.
+func (c *Cert) verifyCert(rootCert, intermediaCert, leafCert *x509.Certificate, at time.Time) ([]*x509.Certificate, error) {
+	roots, err := c.rootPool()
+	if err != nil {
+		return nil, err
 	}
 
 	intermedia := x509.NewCertPool()
@@ -72,16 +202,25 @@ func (c *Cert) verifyCert(rootCert, intermediaCert, leafCert *x509.Certificate)
 	opts := x509.VerifyOptions{
 		Roots:         roots,
 		Intermediates: intermedia,
+		CurrentTime:   at,
 	}
-	_, err := rootCert.Verify(opts)
+	_, err = rootCert.Verify(opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = leafCert.Verify(opts)
+	chains, err := leafCert.Verify(opts)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return chains[0], nil
+}
 
-	return nil
+// checkRevocation checks the revocation status of a verified chain when OCSP is enabled.
+// The OCSPChecker caches good responses itself, so cached chains are still checked.
+func (c *Cert) checkRevocation(chain []*x509.Certificate) error {
+	if c.ocsp == nil {
+		return nil
+	}
+	return c.ocsp.CheckChain(chain[0], chain[1], chain[len(chain)-1])
 }
@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"crypto/x509"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/api/apitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCert_VerifyX5C_Cache(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(&apitest.CAOptions{NotAfter: time.Now().Add(24 * time.Hour)})
+	signed := ca.Sign(api.JWSTransaction{TransactionID: "1"})
+	client := newTestParserClient(ca)
+
+	calls := 0
+	check := func(leaf, intermediate, root *x509.Certificate) error {
+		calls++
+		return nil
+	}
+	for range 3 {
+		leaf, err := client.VerifyX5C(signed, time.Now(), check)
+		require.NoError(t, err)
+		assert.True(t, leaf.Equal(ca.Leaf))
+	}
+	assert.Equal(t, 1, calls, "chain must be verified once")
+	assert.Equal(t, 1, client.CachedChains())
+
+	_, err := client.VerifyX5C(signed, time.Now().Add(48*time.Hour), check)
+	assert.Error(t, err, "cached chain must not be used once expired")
+	assert.Equal(t, 2, calls)
+
+	_, err = client.VerifyX5C(apitest.NewCA(nil).Sign(api.JWSTransaction{TransactionID: "1"}), time.Now(), nil)
+	assert.Error(t, err, "untrusted chain must be rejected")
+}
+
+// BenchmarkStoreClient_ParseSignedTransactions parses a history page of 100 transactions signed by the same chain.
+func BenchmarkStoreClient_ParseSignedTransactions(b *testing.B) {
+	ca := apitest.NewCA(nil)
+	page := make([]string, 100)
+	for i := range page {
+		page[i] = ca.Sign(api.JWSTransaction{TransactionID: fmt.Sprint(i)})
+	}
+
+	for _, bm := range []struct {
+		Name  string
+		Cache bool
+	}{
+		{Name: "uncached", Cache: false},
+		{Name: "cached", Cache: true},
+	} {
+		b.Run(bm.Name, func(b *testing.B) {
+			client := newTestParserClient(ca)
+			if !bm.Cache {
+				client.DisableChainCache()
+			}
+
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := client.ParseSignedTransactions(page); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
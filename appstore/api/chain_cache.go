@@ -0,0 +1,100 @@
+package api
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"crypto/x509"
+	"sync"
+	"time"
+)
+
+// defaultChainCacheSize bounds the number of verified chains kept by a Cert.
+// Apple rotates its signing certificates rarely, so a handful of entries covers the chains in use.
+const defaultChainCacheSize = 16
+
+// verifiedChain is a certificate chain that has been verified against the trusted roots.
+// It is valid at any time within [notBefore, notAfter], the intersection of the validity periods of its certificates.
+type verifiedChain struct {
+	key       [sha256.Size]byte
+	chain     []*x509.Certificate // leaf first, root last
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+func newVerifiedChain(key [sha256.Size]byte, chain []*x509.Certificate) *verifiedChain {
+	v := &verifiedChain{key: key, chain: chain}
+	for i, cert := range chain {
+		if i == 0 || cert.NotBefore.After(v.notBefore) {
+			v.notBefore = cert.NotBefore
+		}
+		if i == 0 || cert.NotAfter.Before(v.notAfter) {
+			v.notAfter = cert.NotAfter
+		}
+	}
+	return v
+}
+
+func (v *verifiedChain) validAt(t time.Time) bool {
+	return !t.Before(v.notBefore) && !t.After(v.notAfter)
+}
+
+// chainCache is a bounded LRU cache of verified chains keyed by the SHA-256 fingerprint of their leaf certificate.
+// A chainCache is safe for concurrent use.
+type chainCache struct {
+	size int
+
+	mu      sync.Mutex
+	lru     *list.List // of *verifiedChain, most recently used first
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+func newChainCache(size int) *chainCache {
+	return &chainCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// get returns the verified chain of the leaf fingerprint if it is valid at t.
+// Chains that are no longer valid at t are evicted.
+func (c *chainCache) get(key [sha256.Size]byte, t time.Time) (*verifiedChain, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	v := e.Value.(*verifiedChain)
+	if !v.validAt(t) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return v, true
+}
+
+func (c *chainCache) add(v *verifiedChain) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[v.key]; ok {
+		e.Value = v
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[v.key] = c.lru.PushFront(v)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*verifiedChain).key)
+	}
+}
+
+func (c *chainCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
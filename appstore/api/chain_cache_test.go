@@ -0,0 +1,37 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainCache(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	chain := newVerifiedChain(sha256.Sum256([]byte("leaf")), []*x509.Certificate{
+		{NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)},
+		{NotBefore: now.Add(-2 * time.Hour), NotAfter: now.Add(2 * time.Hour)},
+	})
+
+	cache := newChainCache(2)
+	cache.add(chain)
+
+	got, ok := cache.get(chain.key, now)
+	assert.True(t, ok)
+	assert.Same(t, chain, got)
+
+	_, ok = cache.get(chain.key, now.Add(90*time.Minute))
+	assert.False(t, ok, "expired chain must not be returned")
+	assert.Equal(t, 0, cache.len(), "expired chain must be evicted")
+
+	for i := range 3 {
+		cache.add(&verifiedChain{key: sha256.Sum256([]byte{byte(i)}), notBefore: now, notAfter: now})
+	}
+	assert.Equal(t, 2, cache.len())
+	_, ok = cache.get(sha256.Sum256([]byte{0}), now)
+	assert.False(t, ok, "least recently used chain must be evicted")
+}
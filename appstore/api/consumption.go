@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+)
+
+// ConsumptionDeadline is how long Apple waits for the consumption information after sending a CONSUMPTION_REQUEST
+// notification, it decides the refund without it afterwards.
+const ConsumptionDeadline = 12 * time.Hour
+
+const defaultConsumptionMaxAttempts = 5
+
+var (
+	// ErrNotConsumptionRequest is returned by ConsumptionResponder.Respond for any other notification type.
+	ErrNotConsumptionRequest = errors.New("appstore api: not a CONSUMPTION_REQUEST notification")
+	// ErrConsumptionDeadlineMissed reports a consumption request that wasn't answered within ConsumptionDeadline.
+	ErrConsumptionDeadlineMissed = errors.New("appstore api: consumption request deadline missed")
+	// ErrNoConsumptionInfo is returned by a ConsumptionProvider that has nothing to send, such as when the customer
+	// didn't consent to share their data. The request is then left unanswered.
+	ErrNoConsumptionInfo = errors.New("appstore api: no consumption information")
+)
+
+// ConsumptionInfoRequest is a refund request a ConsumptionProvider is asked the consumption information of.
+type ConsumptionInfoRequest struct {
+	Notification *appstore.SubscriptionNotificationV2DecodedPayload // The CONSUMPTION_REQUEST notification
+	Transaction  *JWSTransaction                                    // The transaction the customer asks a refund of
+	Reason       appstore.ConsumptionRequestReason                  // The reason the customer gave
+	Deadline     time.Time                                          // When Apple stops waiting for the information
+}
+
+// ConsumptionProvider looks up the delivery status, the consumption percentage and the refund preference of a refund request.
+type ConsumptionProvider interface {
+	ConsumptionInfo(ctx context.Context, req *ConsumptionInfoRequest) (ConsumptionRequest, error)
+}
+
+// ConsumptionProviderFunc adapts a function to a ConsumptionProvider.
+type ConsumptionProviderFunc func(ctx context.Context, req *ConsumptionInfoRequest) (ConsumptionRequest, error)
+
+func (f ConsumptionProviderFunc) ConsumptionInfo(ctx context.Context, req *ConsumptionInfoRequest) (ConsumptionRequest, error) {
+	return f(ctx, req)
+}
+
+// ConsumptionClient is the part of the App Store Server API a ConsumptionResponder calls, such as a StoreClient or an APIClient.
+type ConsumptionClient interface {
+	ParseSignedTransaction(transaction string) (*JWSTransaction, error)
+	SendConsumptionInfoV2(ctx context.Context, transactionId string, body ConsumptionRequest) (statusCode int, err error)
+}
+
+// ConsumptionResult is the outcome of a CONSUMPTION_REQUEST notification.
+type ConsumptionResult struct {
+	NotificationUUID string              // The notification UUID
+	TransactionID    string              // The transaction the customer asks a refund of, empty if it couldn't be parsed
+	Deadline         time.Time           // When Apple stops waiting for the information
+	Request          *ConsumptionRequest // The information given by the provider, nil if there is none
+	Sent             bool                // Whether Apple accepted the information
+	Attempts         int                 // Number of SendConsumptionInfoV2 calls, without the retries of the client
+	Missed           bool                // Whether the deadline passed before the information was sent
+	Err              error               // Why the information wasn't sent
+}
+
+// ConsumptionResponder answers the CONSUMPTION_REQUEST notifications with SendConsumptionInfoV2 before ConsumptionDeadline,
+// with the information from Provider. Sending is retried on retryable errors until the deadline.
+//
+// Retry is the only retry layer when Client is built without a StoreConfig.RetryPolicy. A client which retries its own
+// requests multiplies the attempts of Retry, and ConsumptionResult.Attempts doesn't count its retries.
+type ConsumptionResponder struct {
+	Client   ConsumptionClient // A client without a RetryPolicy
+	Provider ConsumptionProvider
+	Retry    *RetryPolicy // Retries of SendConsumptionInfoV2, bounded by the deadline. Default is 5 attempts.
+	// OnMissed, if not nil, is called with each request that missed its deadline, to report it.
+	OnMissed func(*ConsumptionResult)
+}
+
+// Respond answers a decoded CONSUMPTION_REQUEST notification. The provider and the calls are bounded by the deadline.
+// It returns the result with its error, nil when the information was sent or the provider returned ErrNoConsumptionInfo.
+// A request that missed its deadline fails with ErrConsumptionDeadlineMissed, joined with the cause if any.
+func (r *ConsumptionResponder) Respond(ctx context.Context, notification *appstore.SubscriptionNotificationV2DecodedPayload) (*ConsumptionResult, error) {
+	if notification == nil || notification.NotificationType != appstore.NotificationTypeV2ConsumptionRequest {
+		return nil, ErrNotConsumptionRequest
+	}
+	result := &ConsumptionResult{
+		NotificationUUID: notification.NotificationUUID,
+		Deadline:         time.UnixMilli(notification.SignedDate).Add(ConsumptionDeadline),
+	}
+	result.Err = r.respond(ctx, notification, result)
+	if result.Err != nil && !result.Sent && !time.Now().Before(result.Deadline) {
+		result.Missed = true
+		if !errors.Is(result.Err, ErrConsumptionDeadlineMissed) {
+			result.Err = fmt.Errorf("%w: %w", ErrConsumptionDeadlineMissed, result.Err)
+		}
+		if r.OnMissed != nil {
+			r.OnMissed(result)
+		}
+	}
+	return result, result.Err
+}
+
+func (r *ConsumptionResponder) respond(ctx context.Context, notification *appstore.SubscriptionNotificationV2DecodedPayload, result *ConsumptionResult) error {
+	if !time.Now().Before(result.Deadline) {
+		return ErrConsumptionDeadlineMissed
+	}
+	ctx, cancel := context.WithDeadline(ctx, result.Deadline)
+	defer cancel()
+
+	transaction, err := r.Client.ParseSignedTransaction(string(notification.Data.SignedTransactionInfo))
+	if err != nil {
+		return err
+	}
+	result.TransactionID = transaction.TransactionID
+
+	body, err := r.Provider.ConsumptionInfo(ctx, &ConsumptionInfoRequest{
+		Notification: notification,
+		Transaction:  transaction,
+		Reason:       notification.Data.ConsumptionRequestReason,
+		Deadline:     result.Deadline,
+	})
+	if errors.Is(err, ErrNoConsumptionInfo) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	result.Request = &body
+	if err := body.Validate(); err != nil {
+		return err
+	}
+
+	policy := r.Retry
+	if policy == nil {
+		policy = &RetryPolicy{MaxAttempts: defaultConsumptionMaxAttempts}
+	}
+	for attempt := 1; ; attempt++ {
+		result.Attempts = attempt
+		_, err = r.Client.SendConsumptionInfoV2(ctx, transaction.TransactionID, body)
+		if err == nil {
+			result.Sent = true
+			return nil
+		}
+		if attempt >= policy.maxAttempts() {
+			return err
+		}
+		wait, ok := policy.delay(err, attempt, time.Now())
+		if !ok {
+			return err
+		}
+		if ctxErr := sleepContext(ctx, wait); ctxErr != nil {
+			return errors.Join(err, ctxErr)
+		}
+	}
+}
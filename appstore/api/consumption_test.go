@@ -0,0 +1,199 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsumptionClient fails the first sends with its errors, then accepts them.
+type fakeConsumptionClient struct {
+	mu     sync.Mutex
+	errs   []error
+	sent   []ConsumptionRequest
+	sentTo []string
+}
+
+func (f *fakeConsumptionClient) ParseSignedTransaction(transaction string) (*JWSTransaction, error) {
+	if transaction == "" {
+		return nil, errors.New("empty jws")
+	}
+	return &JWSTransaction{TransactionID: transaction}, nil
+}
+
+func (f *fakeConsumptionClient) SendConsumptionInfoV2(_ context.Context, transactionId string, body ConsumptionRequest) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sentTo = append(f.sentTo, transactionId)
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return 500, err
+	}
+	f.sent = append(f.sent, body)
+	return 202, nil
+}
+
+func newConsumptionNotification(signedDate time.Time) *appstore.SubscriptionNotificationV2DecodedPayload {
+	return &appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationType: appstore.NotificationTypeV2ConsumptionRequest,
+		NotificationUUID: "002e14d5-51f5-4503-b5a8-c3a1af68eb20",
+		SignedDate:       signedDate.UnixMilli(),
+		Data: appstore.SubscriptionNotificationV2Data{
+			SignedTransactionInfo:    "2000000000000001",
+			ConsumptionRequestReason: appstore.ConsumptionRequestReasonUnintendedPurchase,
+		},
+	}
+}
+
+func delivered(_ context.Context, req *ConsumptionInfoRequest) (ConsumptionRequest, error) {
+	percentage := int32(25000)
+	return ConsumptionRequest{
+		CustomerConsented:     true,
+		ConsumptionPercentage: &percentage,
+		DeliveryStatus:        DELIVERED,
+		RefundPreference:      DECLINE,
+	}, nil
+}
+
+func TestConsumptionResponder_Respond(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeConsumptionClient{errs: []error{GeneralInternalRetryableError, &HTTPError{StatusCode: 503}}}
+	var asked *ConsumptionInfoRequest
+	r := &ConsumptionResponder{
+		Client: client,
+		Provider: ConsumptionProviderFunc(func(ctx context.Context, req *ConsumptionInfoRequest) (ConsumptionRequest, error) {
+			asked = req
+			deadline, ok := ctx.Deadline()
+			assert.True(t, ok)
+			assert.Equal(t, req.Deadline, deadline)
+			return delivered(ctx, req)
+		}),
+		Retry: &RetryPolicy{InitialBackoff: time.Millisecond},
+		OnMissed: func(*ConsumptionResult) {
+			t.Error("the request isn't missed")
+		},
+	}
+	signedDate := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+
+	result, err := r.Respond(context.Background(), newConsumptionNotification(signedDate))
+	require.NoError(t, err)
+	assert.True(t, result.Sent)
+	assert.Equal(t, 3, result.Attempts)
+	assert.Equal(t, "2000000000000001", result.TransactionID)
+	assert.Equal(t, signedDate.Add(12*time.Hour), result.Deadline)
+
+	require.NotNil(t, asked)
+	assert.Equal(t, appstore.ConsumptionRequestReasonUnintendedPurchase, asked.Reason)
+	assert.Equal(t, "2000000000000001", asked.Transaction.TransactionID)
+	require.Len(t, client.sent, 1)
+	assert.Equal(t, DELIVERED, client.sent[0].DeliveryStatus)
+	assert.Equal(t, []string{"2000000000000001", "2000000000000001", "2000000000000001"}, client.sentTo)
+}
+
+func TestConsumptionResponder_NotSent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name     string
+		Provider ConsumptionProviderFunc
+		Errs     []error
+		Expected error
+		Attempts int
+	}{
+		{
+			Name: "no information",
+			Provider: func(context.Context, *ConsumptionInfoRequest) (ConsumptionRequest, error) {
+				return ConsumptionRequest{}, ErrNoConsumptionInfo
+			},
+		},
+		{
+			Name: "invalid information",
+			Provider: func(context.Context, *ConsumptionInfoRequest) (ConsumptionRequest, error) {
+				return ConsumptionRequest{DeliveryStatus: DELIVERED}, nil
+			},
+			Expected: InvalidCustomerConsentedError,
+		},
+		{
+			Name:     "rejected",
+			Provider: delivered,
+			Errs:     []error{InvalidTransactionNotConsumableError},
+			Expected: InvalidTransactionNotConsumableError,
+			Attempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			client := &fakeConsumptionClient{errs: tt.Errs}
+			r := &ConsumptionResponder{Client: client, Provider: tt.Provider}
+
+			result, err := r.Respond(context.Background(), newConsumptionNotification(time.Now()))
+			if tt.Expected == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.Expected)
+			}
+			assert.False(t, result.Sent)
+			assert.False(t, result.Missed)
+			assert.Equal(t, tt.Attempts, result.Attempts)
+			assert.Empty(t, client.sent)
+		})
+	}
+}
+
+func TestConsumptionResponder_Missed(t *testing.T) {
+	t.Parallel()
+
+	t.Run("notification past its deadline", func(t *testing.T) {
+		t.Parallel()
+		var missed []*ConsumptionResult
+		client := &fakeConsumptionClient{}
+		r := &ConsumptionResponder{
+			Client:   client,
+			Provider: ConsumptionProviderFunc(delivered),
+			OnMissed: func(result *ConsumptionResult) { missed = append(missed, result) },
+		}
+
+		result, err := r.Respond(context.Background(), newConsumptionNotification(time.Now().Add(-13*time.Hour)))
+		require.ErrorIs(t, err, ErrConsumptionDeadlineMissed)
+		assert.True(t, result.Missed)
+		assert.Equal(t, []*ConsumptionResult{result}, missed)
+		assert.Empty(t, client.sentTo)
+	})
+
+	t.Run("deadline passed while retrying", func(t *testing.T) {
+		t.Parallel()
+		var missed int
+		client := &fakeConsumptionClient{errs: []error{GeneralInternalRetryableError, GeneralInternalRetryableError}}
+		r := &ConsumptionResponder{
+			Client:   client,
+			Provider: ConsumptionProviderFunc(delivered),
+			Retry:    &RetryPolicy{InitialBackoff: time.Second},
+			OnMissed: func(*ConsumptionResult) { missed++ },
+		}
+
+		result, err := r.Respond(context.Background(), newConsumptionNotification(time.Now().Add(-ConsumptionDeadline+50*time.Millisecond)))
+		require.ErrorIs(t, err, ErrConsumptionDeadlineMissed)
+		assert.ErrorIs(t, err, GeneralInternalRetryableError)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, result.Missed)
+		assert.Equal(t, 1, result.Attempts)
+		assert.Equal(t, 1, missed)
+	})
+}
+
+func TestConsumptionResponder_NotConsumptionRequest(t *testing.T) {
+	t.Parallel()
+
+	r := &ConsumptionResponder{Client: &fakeConsumptionClient{}, Provider: ConsumptionProviderFunc(delivered)}
+	_, err := r.Respond(context.Background(), &appstore.SubscriptionNotificationV2DecodedPayload{NotificationType: appstore.NotificationTypeV2Refund})
+	assert.ErrorIs(t, err, ErrNotConsumptionRequest)
+}
@@ -2,9 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
 type Error struct {
@@ -174,3 +177,66 @@ var (
 	FamilyTransactionNotSupportedError           = newError(4000185, "Invalid request. Family Sharing transactions aren't supported by this endpoint")
 	TransactionIdIsNotOriginalTransactionIdError = newError(4000187, "Invalid request. The transaction ID provided is not an original transaction ID")
 )
+
+// ErrInvalidCredentials matches an HTTPError with a 401 or 403 status, when Apple rejects the token,
+// such as for a revoked key, a wrong key ID or issuer, or a token that expired.
+var ErrInvalidCredentials = errors.New("appstore api: invalid credentials")
+
+// maxHTTPErrorBody bounds the response body kept in an HTTPError.
+const maxHTTPErrorBody = 512
+
+// HTTPError is returned when the App Store Server API answers with a status other than 2xx
+// and a body that isn't an Error, such as a 401 for a bad token or a 502 or 503 from a proxy.
+type HTTPError struct {
+	StatusCode   int    // The HTTP status code
+	Method       string // The method of the request
+	PathTemplate string // The endpoint path template of the request, such as PathTransactionInfo, or its path outside of the known endpoints
+	Body         string // The beginning of the response body
+}
+
+func newHTTPError(method, rawURL string, statusCode int, body []byte) *HTTPError {
+	template, ok := matchPathTemplate(rawURL)
+	if !ok {
+		template = rawURL
+		if u, err := url.Parse(rawURL); err == nil {
+			template = u.Path
+		}
+	}
+	if len(body) > maxHTTPErrorBody {
+		body = body[:maxHTTPErrorBody]
+	}
+	return &HTTPError{
+		StatusCode:   statusCode,
+		Method:       method,
+		PathTemplate: template,
+		Body:         strings.ToValidUTF8(string(body), ""),
+	}
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("appstore api: %s %s returned status code %d", e.Method, e.PathTemplate, e.StatusCode)
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// Is reports whether target is ErrInvalidCredentials for a 401 or 403 status.
+func (e *HTTPError) Is(target error) bool {
+	return target == ErrInvalidCredentials && e.CredentialError()
+}
+
+// CredentialError reports whether Apple rejected the credentials of the request, with a 401 or 403 status.
+func (e *HTTPError) CredentialError() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// Retryable reports whether the status is a transient server or gateway failure.
+func (e *HTTPError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -69,3 +70,42 @@ func TestError_Is2(t *testing.T) {
 		assert.Equal(t, test.ExpectedIs, errors.Is(test.SrcError, test.TargetError))
 	}
 }
+
+func TestHTTPError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name        string
+		StatusCode  int
+		Credentials bool
+		Retryable   bool
+	}{
+		{Name: "unauthorized", StatusCode: 401, Credentials: true},
+		{Name: "forbidden", StatusCode: 403, Credentials: true},
+		{Name: "bad gateway", StatusCode: 502, Retryable: true},
+		{Name: "service unavailable", StatusCode: 503, Retryable: true},
+		{Name: "not found", StatusCode: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			err := fmt.Errorf("wrapping: %w", newHTTPError("GET", HostProduction+"/inApps/v1/transactions/2000000000000001", tt.StatusCode, nil))
+
+			var httpErr *HTTPError
+			assert.True(t, errors.As(err, &httpErr))
+			assert.Equal(t, tt.StatusCode, httpErr.StatusCode)
+			assert.Equal(t, PathTransactionInfo, httpErr.PathTemplate)
+			assert.Equal(t, tt.Credentials, httpErr.CredentialError())
+			assert.Equal(t, tt.Credentials, errors.Is(err, ErrInvalidCredentials))
+			assert.Equal(t, tt.Retryable, httpErr.Retryable())
+		})
+	}
+
+	t.Run("body snippet", func(t *testing.T) {
+		t.Parallel()
+		err := newHTTPError("POST", "https://proxy.example.com/custom/path?q=1", 502, []byte(strings.Repeat("x", 1000)))
+		assert.Equal(t, "/custom/path", err.PathTemplate)
+		assert.Len(t, err.Body, maxHTTPErrorBody)
+		assert.Contains(t, err.Error(), "appstore api: POST /custom/path returned status code 502: xxx")
+	})
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"crypto/x509"
+	"time"
+)
+
+// The internals used by the tests of package api_test, which sign their data with an apitest.CA.
+
+var (
+	NewTestStoreClient = newTestStoreClient
+	WriteAPIError      = writeAPIError
+)
+
+// NewTestAPIClient returns an APIClient of the given environment clients.
+func NewTestAPIClient(production, sandbox *StoreClient) *APIClient {
+	return &APIClient{
+		productionCli: production,
+		sandboxCli:    sandbox,
+		environments:  newEnvironmentMemory(defaultEnvironmentMemorySize),
+	}
+}
+
+// VerifyX5C verifies the x5c chain in the header of signed at the given time, check is run on the chains that aren't cached.
+func (a *StoreClient) VerifyX5C(signed string, at time.Time, check func(leaf, intermediate, root *x509.Certificate) error) (*x509.Certificate, error) {
+	header, err := parseJWSHeader(signed)
+	if err != nil {
+		return nil, err
+	}
+	return a.cert.verifyX5C(header.X5C, at, check)
+}
+
+// CachedChains returns the number of verified chains cached by the client.
+func (a *StoreClient) CachedChains() int {
+	return a.cert.chains.len()
+}
+
+// DisableChainCache makes the client verify every chain.
+func (a *StoreClient) DisableChainCache() {
+	a.cert.chains = nil
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"time"
+)
+
+// defaultPageDelay is the pause between two page requests of a history endpoint.
+const defaultPageDelay = 10 * time.Millisecond
+
+// PageOptions configures the history iterators.
+// The App Store Server API decides how many items a page holds, MaxItems bounds the items of an iteration instead.
+type PageOptions struct {
+	// Cursor resumes the iteration from a revision or paginationToken previously reported by OnPage.
+	Cursor string
+	// Delay is the pause between two page requests. Default is 10ms, a negative value disables it.
+	Delay time.Duration
+	// MaxItems ends the iteration with the page on which the yielded items reach MaxItems, so that it may yield
+	// up to a page more. Resume with the cursor reported by OnPage for the following items. Default is no limit.
+	MaxItems int
+	// OnPage is called after all items of a page are yielded, with the cursor of the following page.
+	// Persist the cursor to resume later with Cursor. hasMore is false on the last page.
+	OnPage func(cursor string, hasMore bool)
+}
+
+func (o *PageOptions) cursor() string {
+	if o == nil {
+		return ""
+	}
+	return o.Cursor
+}
+
+func (o *PageOptions) delay() time.Duration {
+	if o == nil || o.Delay == 0 {
+		return defaultPageDelay
+	}
+	return o.Delay
+}
+
+func (o *PageOptions) onPage(cursor string, hasMore bool) {
+	if o != nil && o.OnPage != nil {
+		o.OnPage(cursor, hasMore)
+	}
+}
+
+// reached reports whether the iteration yielded MaxItems items.
+func (o *PageOptions) reached(yielded int) bool {
+	return o != nil && o.MaxItems > 0 && yielded >= o.MaxItems
+}
+
+// TransactionHistoryIter iterates over the verified and decoded transactions of GetTransactionHistory page by page.
+// An item which fails verification is yielded as an error and the iteration goes on, any other error ends the iteration.
+// The cursor reported through PageOptions.OnPage is the revision of the following page.
+func (a *StoreClient) TransactionHistoryIter(ctx context.Context, transactionId string, query *url.Values, opts *PageOptions) iter.Seq2[*JWSTransaction, error] {
+	return func(yield func(*JWSTransaction, error) bool) {
+		q := url.Values{}
+		if query != nil {
+			for k, v := range *query {
+				q[k] = append([]string(nil), v...)
+			}
+		}
+		if cursor := opts.cursor(); cursor != "" {
+			q.Set("revision", cursor)
+		}
+
+		yielded := 0
+		for {
+			rsp, err := a.getTransactionHistoryPage(ctx, transactionId, q)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if !a.yieldSignedTransactions(rsp.SignedTransactions, yield) {
+				return
+			}
+			yielded += len(rsp.SignedTransactions)
+			opts.onPage(rsp.Revision, rsp.HasMore)
+			if !rsp.HasMore || opts.reached(yielded) {
+				return
+			}
+			q.Set("revision", rsp.Revision)
+
+			if err = sleepContext(ctx, opts.delay()); err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
+
+// RefundHistoryIter iterates over the verified and decoded refunded transactions of GetRefundHistory page by page.
+// An item which fails verification is yielded as an error and the iteration goes on, any other error ends the iteration.
+// The cursor reported through PageOptions.OnPage is the revision of the following page.
+func (a *StoreClient) RefundHistoryIter(ctx context.Context, originalTransactionId string, opts *PageOptions) iter.Seq2[*JWSTransaction, error] {
+	return func(yield func(*JWSTransaction, error) bool) {
+		revision := opts.cursor()
+		yielded := 0
+		for {
+			rsp, err := a.getRefundHistoryPage(ctx, originalTransactionId, revision)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			if !a.yieldSignedTransactions(rsp.SignedTransactions, yield) {
+				return
+			}
+			yielded += len(rsp.SignedTransactions)
+			opts.onPage(rsp.Revision, rsp.HasMore)
+			if !rsp.HasMore || opts.reached(yielded) {
+				return
+			}
+			revision = rsp.Revision
+
+			if err = sleepContext(ctx, opts.delay()); err != nil {
+				yield(nil, err)
+				return
+			}
+		}
+	}
+}
+
+// NotificationHistoryIter iterates over the items of GetNotificationHistory page by page, any error ends the iteration.
+// The cursor reported through PageOptions.OnPage is the paginationToken of the following page.
+func (a *StoreClient) NotificationHistoryIter(ctx context.Context, body NotificationHistoryRequest, opts *PageOptions) iter.Seq2[NotificationHistoryResponseItem, error] {
+	return func(yield func(NotificationHistoryResponseItem, error) bool) {
+		paginationToken := opts.cursor()
+		yielded := 0
+		for {
+			rsp, err := a.GetNotificationHistory(ctx, body, paginationToken)
+			if err != nil {
+				yield(NotificationHistoryResponseItem{}, err)
+				return
+			}
+
+			for _, item := range rsp.NotificationHistory {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			yielded += len(rsp.NotificationHistory)
+			opts.onPage(rsp.PaginationToken, rsp.HasMore)
+			if !rsp.HasMore || opts.reached(yielded) {
+				return
+			}
+			paginationToken = rsp.PaginationToken
+
+			if err = sleepContext(ctx, opts.delay()); err != nil {
+				yield(NotificationHistoryResponseItem{}, err)
+				return
+			}
+		}
+	}
+}
+
+func (a *StoreClient) yieldSignedTransactions(signedTransactions []string, yield func(*JWSTransaction, error) bool) bool {
+	for _, v := range signedTransactions {
+		if !yield(a.ParseSignedTransaction(v)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func notificationHistoryHandler(t *testing.T, calls *atomic.Int32) http.Handler {
+	pages := map[string]NotificationHistoryResponses{
+		"": {
+			HasMore:             true,
+			PaginationToken:     "page2",
+			NotificationHistory: []NotificationHistoryResponseItem{{SignedPayload: "a"}, {SignedPayload: "b"}},
+		},
+		"page2": {
+			HasMore:             false,
+			PaginationToken:     "page3",
+			NotificationHistory: []NotificationHistoryResponseItem{{SignedPayload: "c"}},
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		assert.Equal(t, PathGetNotificationHistory, r.URL.Path)
+		rsp, ok := pages[r.URL.Query().Get("paginationToken")]
+		if !ok {
+			writeAPIError(w, http.StatusBadRequest, InvalidPaginationTokenError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(rsp)
+	})
+}
+
+func TestStoreClient_NotificationHistoryIter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should yield every item and report cursors", func(t *testing.T) {
+		t.Parallel()
+		var calls atomic.Int32
+		client := newTestStoreClient(t, notificationHistoryHandler(t, &calls), nil)
+
+		var cursors []string
+		var payloads []string
+		for item, err := range client.NotificationHistoryIter(context.Background(), NotificationHistoryRequest{}, &PageOptions{
+			Delay:  -1,
+			OnPage: func(cursor string, hasMore bool) { cursors = append(cursors, cursor) },
+		}) {
+			assert.NoError(t, err)
+			payloads = append(payloads, item.SignedPayload)
+		}
+		assert.Equal(t, []string{"a", "b", "c"}, payloads)
+		assert.Equal(t, []string{"page2", "page3"}, cursors)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("should stop early and resume from the cursor", func(t *testing.T) {
+		t.Parallel()
+		var calls atomic.Int32
+		client := newTestStoreClient(t, notificationHistoryHandler(t, &calls), nil)
+
+		for item, err := range client.NotificationHistoryIter(context.Background(), NotificationHistoryRequest{}, nil) {
+			assert.NoError(t, err)
+			assert.Equal(t, "a", item.SignedPayload)
+			break
+		}
+		assert.Equal(t, int32(1), calls.Load())
+
+		var payloads []string
+		for item, err := range client.NotificationHistoryIter(context.Background(), NotificationHistoryRequest{}, &PageOptions{Cursor: "page2"}) {
+			assert.NoError(t, err)
+			payloads = append(payloads, item.SignedPayload)
+		}
+		assert.Equal(t, []string{"c"}, payloads)
+	})
+
+	t.Run("should end with the page reaching max items", func(t *testing.T) {
+		t.Parallel()
+		var calls atomic.Int32
+		client := newTestStoreClient(t, notificationHistoryHandler(t, &calls), nil)
+
+		var cursors []string
+		var payloads []string
+		for item, err := range client.NotificationHistoryIter(context.Background(), NotificationHistoryRequest{}, &PageOptions{
+			Delay:    -1,
+			MaxItems: 1,
+			OnPage:   func(cursor string, hasMore bool) { cursors = append(cursors, cursor) },
+		}) {
+			assert.NoError(t, err)
+			payloads = append(payloads, item.SignedPayload)
+		}
+		assert.Equal(t, []string{"a", "b"}, payloads)
+		assert.Equal(t, []string{"page2"}, cursors)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("should stop when the context is cancelled between pages", func(t *testing.T) {
+		t.Parallel()
+		var calls atomic.Int32
+		client := newTestStoreClient(t, notificationHistoryHandler(t, &calls), nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		var errs []error
+		for _, err := range client.NotificationHistoryIter(ctx, NotificationHistoryRequest{}, &PageOptions{
+			OnPage: func(string, bool) { cancel() },
+		}) {
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		assert.Equal(t, []error{context.Canceled}, errs)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func TestStoreClient_TransactionHistoryIter(t *testing.T) {
+	t.Parallel()
+
+	var revisions []string
+	client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "AUTO_RENEWABLE", r.URL.Query().Get("productType"))
+		revision := r.URL.Query().Get("revision")
+		revisions = append(revisions, revision)
+		switch revision {
+		case "start":
+			_ = json.NewEncoder(w).Encode(HistoryResponse{HasMore: true, Revision: "next", SignedTransactions: []string{"invalid"}})
+		default:
+			_ = json.NewEncoder(w).Encode(HistoryResponse{HasMore: false, Revision: "last"})
+		}
+	}), nil)
+
+	query := &url.Values{}
+	query.Set("productType", "AUTO_RENEWABLE")
+	var cursors []string
+	var errCount int
+	for tran, err := range client.TransactionHistoryIter(context.Background(), "1000", query, &PageOptions{
+		Cursor: "start",
+		Delay:  -1,
+		OnPage: func(cursor string, hasMore bool) { cursors = append(cursors, cursor) },
+	}) {
+		assert.Nil(t, tran)
+		assert.Error(t, err)
+		errCount++
+	}
+	assert.Equal(t, 1, errCount)
+	assert.Equal(t, []string{"start", "next"}, revisions)
+	assert.Equal(t, []string{"next", "last"}, cursors)
+	assert.Equal(t, "", query.Get("revision"), "the caller's query must not be modified")
+}
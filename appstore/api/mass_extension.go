@@ -0,0 +1,379 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultMassExtensionPollInterval    = time.Minute
+	defaultMassExtensionMaxPollInterval = 15 * time.Minute
+	maxExtendByDays                     = 90
+)
+
+// MassExtensionClient is the part of the App Store Server API a MassExtender calls, such as a StoreClient or an APIClient.
+type MassExtensionClient interface {
+	ExtendSubscriptionRenewalDateForAll(ctx context.Context, body MassExtendRenewalDateRequest) (statusCode int, err error)
+	GetSubscriptionRenewalDataStatus(ctx context.Context, productId, requestIdentifier string) (statusCode int, rsp *MassExtendRenewalDateStatusResponse, err error)
+}
+
+// MassExtensionRequest extends the renewal date of the active subscribers of several products.
+// One mass extension is requested per product and storefront batch.
+type MassExtensionRequest struct {
+	ProductIDs        []string         // The products to extend
+	StorefrontBatches [][]string       // Optional storefront country codes of each request, no batch extends every storefront at once
+	ExtendByDays      int32            // Number of days to extend, from 1 to 90
+	ExtendReasonCode  ExtendReasonCode // The reason of the extension
+}
+
+func (r *MassExtensionRequest) Validate() error {
+	if len(r.ProductIDs) == 0 || slices.Contains(r.ProductIDs, "") {
+		return InvalidProductIdError
+	}
+	for _, batch := range r.StorefrontBatches {
+		if len(batch) == 0 {
+			return InvalidEmptyStorefrontCountryCodeListError
+		}
+	}
+	if r.ExtendByDays < 1 || r.ExtendByDays > maxExtendByDays {
+		return InvalidExtendByDaysError
+	}
+	if r.ExtendReasonCode < UndeclaredExtendReasonCode || r.ExtendReasonCode > ServiceIssueOrOutage {
+		return InvalidExtendReasonCodeError
+	}
+	return nil
+}
+
+// MassExtension is the state of one mass extension request. Persist it to resume the extension after a restart,
+// the request identifier is what Apple reports the status of.
+type MassExtension struct {
+	ProductID              string   `json:"productId"`
+	StorefrontCountryCodes []string `json:"storefrontCountryCodes,omitempty"`
+	RequestIdentifier      string   `json:"requestIdentifier"`
+	Submitted              bool     `json:"submitted"`
+	Complete               bool     `json:"complete"`
+	CompleteDate           int64    `json:"completeDate,omitempty"`
+	SucceededCount         int64    `json:"succeededCount,omitempty"`
+	FailedCount            int64    `json:"failedCount,omitempty"`
+}
+
+// MassExtensionError reports a mass extension request that Apple rejected or whose status couldn't be polled.
+type MassExtensionError struct {
+	RequestIdentifier string // The request identifier of the mass extension
+	ProductID         string // The product of the mass extension
+	Err               error  // The submission or polling error
+}
+
+func (e *MassExtensionError) Error() string {
+	return fmt.Sprintf("appstore mass extension %s of %s: %v", e.RequestIdentifier, e.ProductID, e.Err)
+}
+
+func (e *MassExtensionError) Unwrap() error {
+	return e.Err
+}
+
+// MassExtensionReport is the outcome of a mass extension.
+type MassExtensionReport struct {
+	Extensions     []MassExtension       // The state of every request, in plan order
+	SucceededCount int64                 // Subscriptions extended over the complete requests
+	FailedCount    int64                 // Subscriptions Apple failed to extend over the complete requests
+	Failures       []*MassExtensionError // The requests that failed, in plan order
+}
+
+// Err joins the failures, it returns nil when every request completed.
+func (r *MassExtensionReport) Err() error {
+	errs := make([]error, len(r.Failures))
+	for i, f := range r.Failures {
+		errs[i] = f
+	}
+	return errors.Join(errs...)
+}
+
+// total sums the counts of the complete requests.
+func (r *MassExtensionReport) total() *MassExtensionReport {
+	r.SucceededCount, r.FailedCount = 0, 0
+	for _, ext := range r.Extensions {
+		if ext.Complete {
+			r.SucceededCount += ext.SucceededCount
+			r.FailedCount += ext.FailedCount
+		}
+	}
+	return r
+}
+
+// MassExtender extends the renewal date of subscribers in bulk with ExtendSubscriptionRenewalDateForAll, then polls
+// GetSubscriptionRenewalDataStatus with a doubling interval until every request is complete.
+// The RENEWAL_EXTENSION notifications with the SUMMARY subtype can be given to Notify to complete the requests
+// without waiting for the next poll. A MassExtender is safe for concurrent use.
+type MassExtender struct {
+	Client          MassExtensionClient
+	PollInterval    time.Duration // First wait between the status polls, doubled after each poll. Default is 1 minute.
+	MaxPollInterval time.Duration // Upper bound of the wait between the status polls. Default is 15 minutes.
+	// OnUpdate is called with a request each time it's planned, submitted or completed. Persist the requests there
+	// and pass them to Resume after a restart. An error stops the mass extension.
+	OnUpdate func(MassExtension) error
+
+	mu        sync.Mutex
+	resuming  map[string]int // Number of Resume calls in progress per request identifier
+	summaries map[string]massExtensionSummary
+	wake      chan struct{}
+}
+
+// massExtensionSummary is a RENEWAL_EXTENSION SUMMARY notification waiting for its request to be polled.
+type massExtensionSummary struct {
+	signedDate int64
+	summary    appstore.SubscriptionNotificationV2Summary
+}
+
+// Plan returns the requests of req, one per product and storefront batch, with a new request identifier each.
+func (m *MassExtender) Plan(req MassExtensionRequest) ([]MassExtension, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	batches := req.StorefrontBatches
+	if len(batches) == 0 {
+		batches = [][]string{nil}
+	}
+
+	extensions := make([]MassExtension, 0, len(req.ProductIDs)*len(batches))
+	for _, productID := range req.ProductIDs {
+		for _, batch := range batches {
+			extensions = append(extensions, MassExtension{
+				ProductID:              productID,
+				StorefrontCountryCodes: batch,
+				RequestIdentifier:      uuid.NewString(),
+			})
+		}
+	}
+	return extensions, nil
+}
+
+// Run plans req, hands every request to OnUpdate before submitting any, and then extends and polls them as Resume does.
+func (m *MassExtender) Run(ctx context.Context, req MassExtensionRequest) (*MassExtensionReport, error) {
+	extensions, err := m.Plan(req)
+	if err != nil {
+		return nil, err
+	}
+	if m.OnUpdate != nil {
+		for _, ext := range extensions {
+			if err := m.OnUpdate(ext); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return m.Resume(ctx, req, extensions)
+}
+
+// Resume submits the requests that aren't submitted yet and polls the status of the incomplete ones until every
+// request is complete or failed. A submission that failed with a retryable error is tried again at the next poll.
+// A submitted request is in progress at Apple, so it keeps being polled through retryable errors and network failures,
+// while a status poll that Apple answers with another error, such as ErrInvalidCredentials, fails the request.
+// It returns the report with the joined failures, or the report so far with the error of ctx or OnUpdate.
+func (m *MassExtender) Resume(ctx context.Context, req MassExtensionRequest, extensions []MassExtension) (*MassExtensionReport, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	report := &MassExtensionReport{Extensions: slices.Clone(extensions)}
+	failed := make([]bool, len(report.Extensions))
+	defer m.release(m.resume(report.Extensions))
+
+	interval := m.pollInterval()
+	for {
+		pending := false
+		for i := range report.Extensions {
+			ext := &report.Extensions[i]
+			if ext.Complete || failed[i] {
+				continue
+			}
+
+			submitted := ext.Submitted
+			changed, err := m.advance(ctx, req, ext)
+			if err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return report.total(), ctxErr
+				}
+				if transient(err) || submitted && !answered(err) {
+					pending = true
+					continue
+				}
+				failed[i] = true
+				report.Failures = append(report.Failures, &MassExtensionError{
+					RequestIdentifier: ext.RequestIdentifier,
+					ProductID:         ext.ProductID,
+					Err:               err,
+				})
+				continue
+			}
+			if changed && m.OnUpdate != nil {
+				if err := m.OnUpdate(*ext); err != nil {
+					return report.total(), err
+				}
+			}
+			pending = pending || !ext.Complete
+		}
+		if !pending {
+			return report.total(), report.Err()
+		}
+
+		if err := m.wait(ctx, interval); err != nil {
+			return report.total(), err
+		}
+		interval = min(interval*2, m.maxPollInterval())
+	}
+}
+
+// Notify completes the request of a RENEWAL_EXTENSION notification with the SUMMARY subtype, waking up the polling.
+// It reports whether the notification is the summary of a request being resumed, other notifications are ignored.
+func (m *MassExtender) Notify(payload *appstore.SubscriptionNotificationV2DecodedPayload) bool {
+	if payload == nil || payload.NotificationType != appstore.NotificationTypeV2RenewalExtension ||
+		payload.Subtype != appstore.SubTypeV2Summary || payload.Summary.RequestIdentifier == "" {
+		return false
+	}
+
+	m.mu.Lock()
+	if m.resuming[payload.Summary.RequestIdentifier] == 0 {
+		m.mu.Unlock()
+		return false
+	}
+	if m.summaries == nil {
+		m.summaries = make(map[string]massExtensionSummary)
+	}
+	m.summaries[payload.Summary.RequestIdentifier] = massExtensionSummary{signedDate: payload.SignedDate, summary: payload.Summary}
+	wake := m.wakeLocked()
+	m.mu.Unlock()
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// answered reports whether err is an answer of Apple, an Error or HTTPError, rather than a failure to reach it.
+func answered(err error) bool {
+	var apiErr *Error
+	var httpErr *HTTPError
+	return errors.As(err, &apiErr) || errors.As(err, &httpErr)
+}
+
+// advance submits ext or polls its status, it reports whether ext changed.
+func (m *MassExtender) advance(ctx context.Context, req MassExtensionRequest, ext *MassExtension) (bool, error) {
+	if !ext.Submitted {
+		_, err := m.Client.ExtendSubscriptionRenewalDateForAll(ctx, MassExtendRenewalDateRequest{
+			RequestIdentifier:      ext.RequestIdentifier,
+			ExtendByDays:           req.ExtendByDays,
+			ExtendReasonCode:       int32(req.ExtendReasonCode),
+			ProductId:              ext.ProductID,
+			StorefrontCountryCodes: ext.StorefrontCountryCodes,
+		})
+		if err != nil {
+			return false, err
+		}
+		ext.Submitted = true
+		return true, nil
+	}
+
+	if s, ok := m.takeSummary(ext.RequestIdentifier); ok {
+		ext.Complete = true
+		ext.CompleteDate = s.signedDate
+		ext.SucceededCount = s.summary.SucceededCount
+		ext.FailedCount = s.summary.FailedCount
+		return true, nil
+	}
+
+	_, rsp, err := m.Client.GetSubscriptionRenewalDataStatus(ctx, ext.ProductID, ext.RequestIdentifier)
+	if err != nil {
+		return false, err
+	}
+	if rsp == nil || !rsp.Complete {
+		return false, nil
+	}
+	ext.Complete = true
+	ext.CompleteDate = rsp.CompleteDate
+	ext.SucceededCount = rsp.SucceededCount
+	ext.FailedCount = rsp.FailedCount
+	return true, nil
+}
+
+// resume makes Notify keep the summaries of the incomplete extensions, it returns their request identifiers.
+func (m *MassExtender) resume(extensions []MassExtension) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.resuming == nil {
+		m.resuming = make(map[string]int)
+	}
+	var requestIdentifiers []string
+	for _, ext := range extensions {
+		if !ext.Complete {
+			m.resuming[ext.RequestIdentifier]++
+			requestIdentifiers = append(requestIdentifiers, ext.RequestIdentifier)
+		}
+	}
+	return requestIdentifiers
+}
+
+// release drops the summaries of the request identifiers once no Resume call polls them anymore.
+func (m *MassExtender) release(requestIdentifiers []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range requestIdentifiers {
+		if m.resuming[id]--; m.resuming[id] <= 0 {
+			delete(m.resuming, id)
+			delete(m.summaries, id)
+		}
+	}
+}
+
+func (m *MassExtender) takeSummary(requestIdentifier string) (massExtensionSummary, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.summaries[requestIdentifier]
+	delete(m.summaries, requestIdentifier)
+	return s, ok
+}
+
+// wait waits for d, a summary notification or until ctx is done, whichever comes first.
+func (m *MassExtender) wait(ctx context.Context, d time.Duration) error {
+	m.mu.Lock()
+	wake := m.wakeLocked()
+	m.mu.Unlock()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	case <-wake:
+	}
+	return nil
+}
+
+func (m *MassExtender) wakeLocked() chan struct{} {
+	if m.wake == nil {
+		m.wake = make(chan struct{}, 1)
+	}
+	return m.wake
+}
+
+func (m *MassExtender) pollInterval() time.Duration {
+	if m.PollInterval <= 0 {
+		return defaultMassExtensionPollInterval
+	}
+	return m.PollInterval
+}
+
+func (m *MassExtender) maxPollInterval() time.Duration {
+	if m.MaxPollInterval <= 0 {
+		return defaultMassExtensionMaxPollInterval
+	}
+	return m.MaxPollInterval
+}
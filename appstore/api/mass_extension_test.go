@@ -0,0 +1,327 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMassExtensionClient completes a mass extension after a number of status polls.
+type fakeMassExtensionClient struct {
+	mu            sync.Mutex
+	pollsToFinish int                                           // Status polls answering incomplete, negative never completes
+	submitErr     func(body MassExtendRenewalDateRequest) error // Optional error of a submission
+	pollErr       error                                         // Optional error of the status polls
+	pollErrs      int                                           // Status polls failing with pollErr, negative always fail
+	submitted     map[string]MassExtendRenewalDateRequest       // Submitted bodies by request identifier
+	submits       int                                           // Number of submissions
+	polls         map[string]int                                // Status polls by request identifier
+}
+
+func (f *fakeMassExtensionClient) ExtendSubscriptionRenewalDateForAll(_ context.Context, body MassExtendRenewalDateRequest) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.submits++
+	if f.submitErr != nil {
+		if err := f.submitErr(body); err != nil {
+			return 400, err
+		}
+	}
+	if f.submitted == nil {
+		f.submitted = make(map[string]MassExtendRenewalDateRequest)
+	}
+	f.submitted[body.RequestIdentifier] = body
+	return 200, nil
+}
+
+func (f *fakeMassExtensionClient) GetSubscriptionRenewalDataStatus(_ context.Context, productId, requestIdentifier string) (int, *MassExtendRenewalDateStatusResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.polls == nil {
+		f.polls = make(map[string]int)
+	}
+	f.polls[requestIdentifier]++
+	if f.pollErrs < 0 || f.polls[requestIdentifier] <= f.pollErrs {
+		return 0, nil, f.pollErr
+	}
+	if _, ok := f.submitted[requestIdentifier]; !ok {
+		return 404, nil, InvalidRequestIdentifierError
+	}
+	rsp := &MassExtendRenewalDateStatusResponse{RequestIdentifier: requestIdentifier}
+	if f.pollsToFinish >= 0 && f.polls[requestIdentifier] > f.pollsToFinish {
+		rsp.Complete = true
+		rsp.CompleteDate = 1698148900000
+		rsp.SucceededCount = 10
+		rsp.FailedCount = 1
+	}
+	return 200, rsp, nil
+}
+
+func TestMassExtensionRequest_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name string
+		Req  MassExtensionRequest
+		Err  error
+	}{
+		{
+			Name: "valid",
+			Req:  MassExtensionRequest{ProductIDs: []string{"com.example.monthly"}, ExtendByDays: 90, ExtendReasonCode: ServiceIssueOrOutage},
+		},
+		{
+			Name: "no product",
+			Req:  MassExtensionRequest{ExtendByDays: 1},
+			Err:  InvalidProductIdError,
+		},
+		{
+			Name: "empty storefront batch",
+			Req:  MassExtensionRequest{ProductIDs: []string{"com.example.monthly"}, StorefrontBatches: [][]string{{"USA"}, {}}, ExtendByDays: 1},
+			Err:  InvalidEmptyStorefrontCountryCodeListError,
+		},
+		{
+			Name: "too many days",
+			Req:  MassExtensionRequest{ProductIDs: []string{"com.example.monthly"}, ExtendByDays: 91},
+			Err:  InvalidExtendByDaysError,
+		},
+		{
+			Name: "unknown reason",
+			Req:  MassExtensionRequest{ProductIDs: []string{"com.example.monthly"}, ExtendByDays: 1, ExtendReasonCode: 4},
+			Err:  InvalidExtendReasonCodeError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.Req.Validate()
+			if tt.Err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.Err)
+			}
+		})
+	}
+}
+
+func TestMassExtender_Run(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeMassExtensionClient{pollsToFinish: 2}
+	var updates []MassExtension
+	m := &MassExtender{
+		Client:       client,
+		PollInterval: time.Millisecond,
+		OnUpdate: func(ext MassExtension) error {
+			updates = append(updates, ext)
+			return nil
+		},
+	}
+	req := MassExtensionRequest{
+		ProductIDs:        []string{"com.example.monthly", "com.example.yearly"},
+		StorefrontBatches: [][]string{{"USA", "CAN"}, {"FRA"}},
+		ExtendByDays:      7,
+		ExtendReasonCode:  ServiceIssueOrOutage,
+	}
+
+	report, err := m.Run(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, report.Extensions, 4)
+	assert.Empty(t, report.Failures)
+	assert.Equal(t, int64(40), report.SucceededCount)
+	assert.Equal(t, int64(4), report.FailedCount)
+
+	for _, ext := range report.Extensions {
+		assert.True(t, ext.Complete)
+		assert.Equal(t, int64(1698148900000), ext.CompleteDate)
+		body := client.submitted[ext.RequestIdentifier]
+		assert.Equal(t, ext.ProductID, body.ProductId)
+		assert.Equal(t, ext.StorefrontCountryCodes, body.StorefrontCountryCodes)
+		assert.Equal(t, int32(7), body.ExtendByDays)
+		assert.Equal(t, int32(ServiceIssueOrOutage), body.ExtendReasonCode)
+		assert.Equal(t, 3, client.polls[ext.RequestIdentifier])
+	}
+	assert.Equal(t, "com.example.yearly", report.Extensions[3].ProductID)
+	assert.Equal(t, []string{"FRA"}, report.Extensions[3].StorefrontCountryCodes)
+
+	// Every request is persisted before the first submission, then once submitted and once complete.
+	require.Len(t, updates, 12)
+	for _, ext := range updates[:4] {
+		assert.False(t, ext.Submitted)
+	}
+	assert.True(t, updates[4].Submitted)
+	assert.True(t, updates[len(updates)-1].Complete)
+}
+
+func TestMassExtender_Resume(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeMassExtensionClient{
+		submitted: map[string]MassExtendRenewalDateRequest{"submitted-id": {RequestIdentifier: "submitted-id"}},
+	}
+	m := &MassExtender{Client: client, PollInterval: time.Millisecond}
+	req := MassExtensionRequest{ProductIDs: []string{"com.example.monthly"}, ExtendByDays: 7}
+	saved := []MassExtension{
+		{ProductID: "com.example.monthly", RequestIdentifier: "planned-id"},
+		{ProductID: "com.example.monthly", RequestIdentifier: "submitted-id", Submitted: true},
+		{ProductID: "com.example.monthly", RequestIdentifier: "complete-id", Submitted: true, Complete: true, SucceededCount: 5},
+	}
+
+	report, err := m.Resume(context.Background(), req, saved)
+	require.NoError(t, err)
+	assert.Equal(t, 1, client.submits)
+	assert.Contains(t, client.submitted, "planned-id")
+	assert.Zero(t, client.polls["complete-id"])
+	assert.Equal(t, int64(25), report.SucceededCount)
+	assert.False(t, saved[0].Submitted, "the given requests are left untouched")
+}
+
+func TestMassExtender_Failures(t *testing.T) {
+	t.Parallel()
+
+	retried := false
+	client := &fakeMassExtensionClient{
+		submitErr: func(body MassExtendRenewalDateRequest) error {
+			switch {
+			case body.ProductId == "com.example.removed":
+				return InvalidProductIdError
+			case !retried:
+				retried = true
+				return GeneralInternalRetryableError
+			}
+			return nil
+		},
+	}
+	m := &MassExtender{Client: client, PollInterval: time.Millisecond}
+	req := MassExtensionRequest{ProductIDs: []string{"com.example.removed", "com.example.monthly"}, ExtendByDays: 7}
+
+	report, err := m.Run(context.Background(), req)
+	require.ErrorIs(t, err, InvalidProductIdError)
+	require.Len(t, report.Failures, 1)
+	var extErr *MassExtensionError
+	require.ErrorAs(t, err, &extErr)
+	assert.Equal(t, "com.example.removed", extErr.ProductID)
+	assert.Equal(t, report.Extensions[0].RequestIdentifier, extErr.RequestIdentifier)
+	assert.False(t, report.Extensions[0].Submitted)
+
+	assert.True(t, retried)
+	assert.True(t, report.Extensions[1].Complete)
+	assert.Equal(t, int64(10), report.SucceededCount)
+}
+
+func TestMassExtender_Notify(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeMassExtensionClient{pollsToFinish: -1}
+	m := &MassExtender{Client: client, PollInterval: time.Hour}
+	m.OnUpdate = func(ext MassExtension) error {
+		if ext.Submitted && !ext.Complete {
+			assert.True(t, m.Notify(&appstore.SubscriptionNotificationV2DecodedPayload{
+				NotificationType: appstore.NotificationTypeV2RenewalExtension,
+				Subtype:          appstore.SubTypeV2Summary,
+				SignedDate:       1698148950000,
+				Summary: appstore.SubscriptionNotificationV2Summary{
+					RequestIdentifier: ext.RequestIdentifier,
+					ProductID:         ext.ProductID,
+					SucceededCount:    3,
+				},
+			}))
+		}
+		return nil
+	}
+	assert.False(t, m.Notify(&appstore.SubscriptionNotificationV2DecodedPayload{NotificationType: appstore.NotificationTypeV2RenewalExtension}))
+	assert.False(t, m.Notify(&appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationType: appstore.NotificationTypeV2RenewalExtension,
+		Subtype:          appstore.SubTypeV2Summary,
+		Summary:          appstore.SubscriptionNotificationV2Summary{RequestIdentifier: "of another extender"},
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	report, err := m.Run(ctx, MassExtensionRequest{ProductIDs: []string{"com.example.monthly"}, ExtendByDays: 7})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), report.SucceededCount)
+	assert.Equal(t, int64(1698148950000), report.Extensions[0].CompleteDate)
+	assert.Empty(t, m.summaries)
+	assert.Empty(t, m.resuming)
+}
+
+func TestMassExtender_PollErrors(t *testing.T) {
+	t.Parallel()
+
+	req := MassExtensionRequest{ProductIDs: []string{"com.example.monthly"}, ExtendByDays: 7}
+	saved := []MassExtension{{ProductID: "com.example.monthly", RequestIdentifier: "submitted-id", Submitted: true}}
+	networkErr := &url.Error{Op: "Get", URL: "https://api.storekit.itunes.apple.com", Err: errors.New("connection reset by peer")}
+
+	t.Run("pending until complete", func(t *testing.T) {
+		t.Parallel()
+		client := &fakeMassExtensionClient{
+			pollErr:   networkErr,
+			pollErrs:  3,
+			submitted: map[string]MassExtendRenewalDateRequest{"submitted-id": {RequestIdentifier: "submitted-id"}},
+		}
+		m := &MassExtender{Client: client, PollInterval: time.Millisecond, MaxPollInterval: time.Millisecond}
+
+		report, err := m.Resume(context.Background(), req, saved)
+		require.NoError(t, err)
+		assert.True(t, report.Extensions[0].Complete)
+		assert.Equal(t, 4, client.polls["submitted-id"])
+	})
+
+	t.Run("pending until the context is done", func(t *testing.T) {
+		t.Parallel()
+		client := &fakeMassExtensionClient{pollErr: GeneralInternalRetryableError, pollErrs: -1}
+		m := &MassExtender{Client: client, PollInterval: time.Millisecond, MaxPollInterval: time.Millisecond}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		report, err := m.Resume(ctx, req, saved)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Empty(t, report.Failures)
+		assert.False(t, report.Extensions[0].Complete)
+	})
+
+	for name, pollErr := range map[string]error{
+		"invalid credentials":        &HTTPError{StatusCode: http.StatusUnauthorized},
+		"unknown request identifier": InvalidRequestIdentifierError,
+	} {
+		t.Run("failed on "+name, func(t *testing.T) {
+			t.Parallel()
+			client := &fakeMassExtensionClient{pollErr: pollErr, pollErrs: -1}
+			m := &MassExtender{Client: client, PollInterval: time.Millisecond, MaxPollInterval: time.Millisecond}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			report, err := m.Resume(ctx, req, saved)
+			require.ErrorIs(t, err, pollErr)
+			var extErr *MassExtensionError
+			require.ErrorAs(t, err, &extErr)
+			assert.Equal(t, "submitted-id", extErr.RequestIdentifier)
+			assert.Len(t, report.Failures, 1)
+			assert.False(t, report.Extensions[0].Complete)
+			assert.Equal(t, 1, client.polls["submitted-id"])
+		})
+	}
+}
+
+func TestMassExtender_ContextDone(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeMassExtensionClient{pollsToFinish: -1}
+	m := &MassExtender{Client: client, PollInterval: time.Millisecond, MaxPollInterval: 5 * time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	report, err := m.Run(ctx, MassExtensionRequest{ProductIDs: []string{"com.example.monthly"}, ExtendByDays: 7})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NotNil(t, report)
+	assert.True(t, report.Extensions[0].Submitted)
+	assert.False(t, report.Extensions[0].Complete)
+}
@@ -95,7 +95,7 @@ type ConsumptionRequest struct {
 	CustomerConsented     bool             `json:"customerConsented"`
 	ConsumptionPercentage *int32           `json:"consumptionPercentage,omitempty"`
 	DeliveryStatus        DeliveryStatus   `json:"deliveryStatus"`
-	RefundPreference      RefundPreference `json:"refundPreference"`
+	RefundPreference      RefundPreference `json:"refundPreference,omitempty"`
 	SampleContentProvided bool             `json:"sampleContentProvided"`
 }
 type ConsumptionRequestBody struct {
@@ -424,6 +424,14 @@ type ExtendRenewalDateRequest struct {
 	RequestIdentifier string           `json:"requestIdentifier"`
 }
 
+// ExtendRenewalDateResponse https://developer.apple.com/documentation/appstoreserverapi/extendrenewaldateresponse
+type ExtendRenewalDateResponse struct {
+	OriginalTransactionId string `json:"originalTransactionId"`
+	WebOrderLineItemId    string `json:"webOrderLineItemId"`
+	Success               bool   `json:"success"`
+	EffectiveDate         int64  `json:"effectiveDate"`
+}
+
 // MassExtendRenewalDateStatusResponse https://developer.apple.com/documentation/appstoreserverapi/massextendrenewaldatestatusresponse
 type MassExtendRenewalDateStatusResponse struct {
 	RequestIdentifier string `json:"requestIdentifier"`
@@ -488,6 +496,20 @@ type SendTestNotificationResponse struct {
 	TestNotificationToken string `json:"testNotificationToken"`
 }
 
+// CheckTestNotificationResponse https://developer.apple.com/documentation/appstoreserverapi/checktestnotificationresponse
+type CheckTestNotificationResponse struct {
+	SignedPayload string            `json:"signedPayload"`
+	SendAttempts  []SendAttemptItem `json:"sendAttempts"`
+}
+
+// FirstSendAttemptResult returns the result of the first attempt to send the test notification, false if Apple didn't try yet.
+func (r *CheckTestNotificationResponse) FirstSendAttemptResult() (FirstSendAttemptResult, bool) {
+	if r == nil || len(r.SendAttempts) == 0 {
+		return "", false
+	}
+	return r.SendAttempts[0].SendAttemptResult, true
+}
+
 type (
 	AutoRenewSubscriptionStatus int32
 	AutoRenewStatus             int32
@@ -512,6 +534,10 @@ type AppTransactionInfoResponse struct {
 	SignedAppTransactionInfo string `json:"signedAppTransactionInfo"`
 }
 
+// Verify that JWSAppTransactionDecodedPayload implements jwt.Claims
+var _ jwt.Claims = JWSAppTransactionDecodedPayload{}
+
+// JWSAppTransactionDecodedPayload https://developer.apple.com/documentation/appstoreserverapi/jwsapptransactiondecodedpayload
 type JWSAppTransactionDecodedPayload struct {
 	AppAppleId                 int64       `json:"appAppleId"`
 	AppTransactionId           string      `json:"appTransactionId"`
@@ -523,3 +549,33 @@ type JWSAppTransactionDecodedPayload struct {
 	ReceiptCreationDate        int64       `json:"receiptCreationDate"`
 	ReceiptType                Environment `json:"receiptType"`
 }
+
+// GetAudience implements jwt.Claims.
+func (J JWSAppTransactionDecodedPayload) GetAudience() (jwt.ClaimStrings, error) {
+	return nil, nil
+}
+
+// GetExpirationTime implements jwt.Claims.
+func (J JWSAppTransactionDecodedPayload) GetExpirationTime() (*jwt.NumericDate, error) {
+	return nil, nil
+}
+
+// GetIssuedAt implements jwt.Claims.
+func (J JWSAppTransactionDecodedPayload) GetIssuedAt() (*jwt.NumericDate, error) {
+	return nil, nil
+}
+
+// GetIssuer implements jwt.Claims.
+func (J JWSAppTransactionDecodedPayload) GetIssuer() (string, error) {
+	return "", nil
+}
+
+// GetNotBefore implements jwt.Claims.
+func (J JWSAppTransactionDecodedPayload) GetNotBefore() (*jwt.NumericDate, error) {
+	return nil, nil
+}
+
+// GetSubject implements jwt.Claims.
+func (J JWSAppTransactionDecodedPayload) GetSubject() (string, error) {
+	return "", nil
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// NotificationV2 is an App Store Server Notification V2 with its nested JWS verified and decoded.
+// Depending on its type, a notification carries data, a summary, an external purchase token or app data,
+// the sections it doesn't carry are nil.
+// Doc: https://developer.apple.com/documentation/appstoreservernotifications/responsebodyv2decodedpayload
+type NotificationV2 struct {
+	NotificationType      appstore.NotificationTypeV2                 `json:"notificationType"`
+	Subtype               appstore.SubtypeV2                          `json:"subtype,omitempty"`
+	NotificationUUID      string                                      `json:"notificationUUID"`
+	Version               string                                      `json:"version"`
+	SignedDate            int64                                       `json:"signedDate"`
+	Data                  *NotificationV2Data                         `json:"data,omitempty"`
+	Summary               *appstore.SubscriptionNotificationV2Summary `json:"summary,omitempty"`               // The result of a mass renewal date extension
+	ExternalPurchaseToken *appstore.ExternalPurchaseToken             `json:"externalPurchaseToken,omitempty"` // The token of an EXTERNAL_PURCHASE_TOKEN notification
+	AppData               *NotificationV2AppData                      `json:"appData,omitempty"`
+}
+
+// NotificationV2Data is the data of a notification about a transaction, with its signed transaction and renewal info decoded.
+// Doc: https://developer.apple.com/documentation/appstoreservernotifications/data
+type NotificationV2Data struct {
+	AppAppleID               int64                                    `json:"appAppleId"`
+	AppTransactionId         string                                   `json:"appTransactionId,omitempty"`
+	BundleID                 string                                   `json:"bundleId"`
+	BundleVersion            string                                   `json:"bundleVersion"`
+	Environment              Environment                              `json:"environment"`
+	Status                   appstore.AutoRenewableSubscriptionStatus `json:"status,omitempty"`
+	ConsumptionRequestReason appstore.ConsumptionRequestReason        `json:"consumptionRequestReason,omitempty"`
+	Transaction              *JWSTransaction                          `json:"transaction,omitempty"` // The decoded signedTransactionInfo, nil when absent
+	RenewalInfo              *JWSRenewalInfoDecodedPayload            `json:"renewalInfo,omitempty"` // The decoded signedRenewalInfo, nil when absent
+}
+
+// NotificationV2AppData is the app data of a notification, with its signed app transaction decoded.
+// Doc: https://developer.apple.com/documentation/appstoreservernotifications/appdata
+type NotificationV2AppData struct {
+	AppAppleID     int64                            `json:"appAppleId"`
+	BundleID       string                           `json:"bundleId"`
+	Environment    Environment                      `json:"environment"`
+	AppTransaction *JWSAppTransactionDecodedPayload `json:"appTransaction,omitempty"` // The decoded signedAppTransactionInfo, nil when absent
+}
+
+// notificationV2Claims is the signed payload of a notification, the sections it doesn't carry are nil.
+type notificationV2Claims struct {
+	NotificationType      appstore.NotificationTypeV2                 `json:"notificationType"`
+	Subtype               appstore.SubtypeV2                          `json:"subtype"`
+	NotificationUUID      string                                      `json:"notificationUUID"`
+	Version               string                                      `json:"version"`
+	SignedDate            int64                                       `json:"signedDate"`
+	Data                  *appstore.SubscriptionNotificationV2Data    `json:"data"`
+	Summary               *appstore.SubscriptionNotificationV2Summary `json:"summary"`
+	ExternalPurchaseToken *appstore.ExternalPurchaseToken             `json:"externalPurchaseToken"`
+	AppData               *appstore.SubscriptionNotificationV2AppData `json:"appData"`
+	jwt.RegisteredClaims
+}
+
+// DecodeNotificationV2 verifies the jws signed payload of an App Store Server Notification V2 and the JWS nested in it,
+// and decodes them. It handles the notifications with data, such as DID_RENEW, and with a summary, such as RENEWAL_EXTENSION.
+func (a *StoreClient) DecodeNotificationV2(signedPayload string) (*NotificationV2, error) {
+	claims := &notificationV2Claims{}
+	if err := a.parseJWS(signedPayload, claims); err != nil {
+		return nil, err
+	}
+
+	notification := &NotificationV2{
+		NotificationType:      claims.NotificationType,
+		Subtype:               claims.Subtype,
+		NotificationUUID:      claims.NotificationUUID,
+		Version:               claims.Version,
+		SignedDate:            claims.SignedDate,
+		Summary:               claims.Summary,
+		ExternalPurchaseToken: claims.ExternalPurchaseToken,
+	}
+	if d := claims.Data; d != nil {
+		data := &NotificationV2Data{
+			AppAppleID:               int64(d.AppAppleID),
+			AppTransactionId:         d.AppTransactionId,
+			BundleID:                 d.BundleID,
+			BundleVersion:            d.BundleVersion,
+			Environment:              Environment(d.Environment),
+			Status:                   d.Status,
+			ConsumptionRequestReason: d.ConsumptionRequestReason,
+		}
+		if d.SignedTransactionInfo != "" {
+			tran, err := a.ParseSignedTransaction(string(d.SignedTransactionInfo))
+			if err != nil {
+				return nil, fmt.Errorf("appstore notification signedTransactionInfo: %w", err)
+			}
+			data.Transaction = tran
+		}
+		if d.SignedRenewalInfo != "" {
+			renewalInfo, err := a.ParseSignedRenewalInfo(string(d.SignedRenewalInfo))
+			if err != nil {
+				return nil, fmt.Errorf("appstore notification signedRenewalInfo: %w", err)
+			}
+			data.RenewalInfo = renewalInfo
+		}
+		notification.Data = data
+	}
+	if d := claims.AppData; d != nil {
+		appData := &NotificationV2AppData{
+			AppAppleID:  d.AppAppleID,
+			BundleID:    d.BundleID,
+			Environment: Environment(d.Environment),
+		}
+		if d.SignedAppTransactionInfo != "" {
+			appTran, err := a.ParseSignedAppTransaction(d.SignedAppTransactionInfo)
+			if err != nil {
+				return nil, fmt.Errorf("appstore notification signedAppTransactionInfo: %w", err)
+			}
+			appData.AppTransaction = appTran
+		}
+		notification.AppData = appData
+	}
+	return notification, nil
+}
+
+// App returns the bundle ID, app Apple ID and environment of the app the notification is about, taken from the section
+// it carries: its data, summary, app data or external purchase token. ok is false when none of them is present.
+func (n *NotificationV2) App() (bundleID string, appAppleID int64, environment Environment, ok bool) {
+	switch {
+	case n.Data != nil && n.Data.BundleID != "":
+		return n.Data.BundleID, n.Data.AppAppleID, n.Data.Environment, true
+	case n.Summary != nil && n.Summary.BundleID != "":
+		return n.Summary.BundleID, n.Summary.AppAppleId, Environment(n.Summary.Environment), true
+	case n.AppData != nil && n.AppData.BundleID != "":
+		return n.AppData.BundleID, n.AppData.AppAppleID, n.AppData.Environment, true
+	case n.ExternalPurchaseToken != nil && n.ExternalPurchaseToken.BundleId != "":
+		return n.ExternalPurchaseToken.BundleId, n.ExternalPurchaseToken.AppAppleId, Environment(n.ExternalPurchaseToken.Environment()), true
+	}
+	return "", 0, "", false
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/awa/go-iap/appstore"
+)
+
+// maxNotificationBodySize bounds the request body of a notification, Apple sends a few kilobytes.
+const maxNotificationBodySize = 1 << 20
+
+// anySubtype routes the notifications of a type whatever their subtype.
+const anySubtype appstore.SubtypeV2 = "*"
+
+// NotificationDecoder verifies and decodes the signed payload of a notification and the JWS nested in it,
+// such as a StoreClient or an APIClient.
+type NotificationDecoder interface {
+	DecodeNotificationV2(signedPayload string) (*NotificationV2, error)
+}
+
+// NotificationFunc processes a decoded notification. An error makes Apple send the notification again later.
+type NotificationFunc func(ctx context.Context, notification *NotificationV2) error
+
+type notificationRoute struct {
+	notificationType appstore.NotificationTypeV2
+	subtype          appstore.SubtypeV2
+}
+
+// NotificationHandler is an http.Handler receiving the App Store Server Notifications V2. It decodes the notification
+// with DecodeNotificationV2, and calls the func registered for its type and subtype.
+//
+// It answers 200 once the notification is handled, and when it's for another app or environment as Apple would
+// send it again in vain. A func error answers 500, and an unavailable OCSP responder 503, for Apple to retry.
+// A request that isn't a notification signed by Apple is answered 400.
+type NotificationHandler struct {
+	Decoder     NotificationDecoder // Verifies and decodes the notifications, such as a StoreClient
+	BundleID    string              // Optional bundle ID of the app, notifications of other apps are ignored
+	Environment Environment         // Optional environment, notifications of the other environment are ignored
+	Fallback    NotificationFunc    // Optional func of the notifications without a registered func, they are acknowledged otherwise
+
+	routes map[notificationRoute]NotificationFunc
+}
+
+// NewNotificationHandler returns a NotificationHandler of the notifications of bundleID in environment.
+func NewNotificationHandler(decoder NotificationDecoder, bundleID string, environment Environment) *NotificationHandler {
+	return &NotificationHandler{
+		Decoder:     decoder,
+		BundleID:    bundleID,
+		Environment: environment,
+	}
+}
+
+// Handle registers fn for the notifications of notificationType whatever their subtype.
+// The funcs must be registered before serving.
+func (h *NotificationHandler) Handle(notificationType appstore.NotificationTypeV2, fn NotificationFunc) {
+	h.HandleSubtype(notificationType, anySubtype, fn)
+}
+
+// HandleSubtype registers fn for the notifications of notificationType and subtype, the notifications without
+// a subtype have the "" subtype. It takes precedence over the func registered with Handle for the type.
+func (h *NotificationHandler) HandleSubtype(notificationType appstore.NotificationTypeV2, subtype appstore.SubtypeV2, fn NotificationFunc) {
+	if h.routes == nil {
+		h.routes = make(map[notificationRoute]NotificationFunc)
+	}
+	h.routes[notificationRoute{notificationType: notificationType, subtype: subtype}] = fn
+}
+
+func (h *NotificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body appstore.SubscriptionNotificationV2SignedPayload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxNotificationBodySize)).Decode(&body); err != nil || body.SignedPayload == "" {
+		http.Error(w, "invalid notification body", http.StatusBadRequest)
+		return
+	}
+	notification, err := h.Decoder.DecodeNotificationV2(body.SignedPayload)
+	if errors.Is(err, appstore.ErrOCSPUnavailable) {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, "invalid notification signature", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Dispatch(r.Context(), notification); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Dispatch calls the func registered for a decoded notification, it ignores the notifications of another app or
+// environment. It is a NotificationFunc, such as for NotificationReconciler.Handle to replay the missed notifications
+// through the funcs of the handler.
+func (h *NotificationHandler) Dispatch(ctx context.Context, notification *NotificationV2) error {
+	if !h.accepts(notification) {
+		return nil
+	}
+	if fn := h.route(notification); fn != nil {
+		return fn(ctx, notification)
+	}
+	return nil
+}
+
+// accepts reports whether the notification is for the app and environment of the handler, taken from the section the
+// notification carries. A notification carrying none is rejected when the handler has a bundle ID or an environment.
+func (h *NotificationHandler) accepts(notification *NotificationV2) bool {
+	bundleID, _, environment, ok := notification.App()
+	if !ok {
+		return h.BundleID == "" && h.Environment == ""
+	}
+	if h.BundleID != "" && bundleID != h.BundleID {
+		return false
+	}
+	if h.Environment != "" && environment != h.Environment {
+		return false
+	}
+	return true
+}
+
+func (h *NotificationHandler) route(notification *NotificationV2) NotificationFunc {
+	if fn, ok := h.routes[notificationRoute{notificationType: notification.NotificationType, subtype: notification.Subtype}]; ok {
+		return fn
+	}
+	if fn, ok := h.routes[notificationRoute{notificationType: notification.NotificationType, subtype: anySubtype}]; ok {
+		return fn
+	}
+	return h.Fallback
+}
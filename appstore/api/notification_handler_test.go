@@ -0,0 +1,258 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/api/apitest"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingDecoder fails to decode every notification with err.
+type failingDecoder struct {
+	err error
+}
+
+func (d failingDecoder) DecodeNotificationV2(string) (*api.NotificationV2, error) {
+	return nil, d.err
+}
+
+// newTestNotificationBody returns the request body of a notification signed by ca, with its claims merged in the
+// claims of a sandbox DID_RENEW notification of com.example.app.
+func newTestNotificationBody(t *testing.T, ca *apitest.CA, claims jwt.MapClaims) string {
+	t.Helper()
+	payload := jwt.MapClaims{
+		"notificationType": appstore.NotificationTypeV2DidRenew,
+		"notificationUUID": "002e14d5-51f5-4503-b5a8-c3a1af68eb20",
+		"data": map[string]any{
+			"bundleId":              "com.example.app",
+			"environment":           "Sandbox",
+			"signedTransactionInfo": ca.Sign(api.JWSTransaction{TransactionID: "2000000000000002", OriginalTransactionId: "2000000000000001"}),
+			"signedRenewalInfo":     ca.Sign(api.JWSRenewalInfoDecodedPayload{AutoRenewProductId: "com.example.monthly", AutoRenewStatus: api.AutoRenewStatusOn}),
+		},
+	}
+	for k, v := range claims {
+		payload[k] = v
+	}
+	return fmt.Sprintf(`{"signedPayload":%q}`, ca.Sign(payload))
+}
+
+func serveNotification(h http.Handler, method, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, "/notifications", strings.NewReader(body)))
+	return rec
+}
+
+func TestNotificationHandler_Route(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+
+	var called string
+	h := api.NewNotificationHandler(newTestParserClient(ca), "com.example.app", api.Sandbox)
+	h.Handle(appstore.NotificationTypeV2DidRenew, func(_ context.Context, n *api.NotificationV2) error {
+		called = "renew"
+		assert.Equal(t, "2000000000000002", n.Data.Transaction.TransactionID)
+		assert.Equal(t, "com.example.monthly", n.Data.RenewalInfo.AutoRenewProductId)
+		assert.Equal(t, api.AutoRenewStatusOn, n.Data.RenewalInfo.AutoRenewStatus)
+		return nil
+	})
+	h.HandleSubtype(appstore.NotificationTypeV2DidRenew, appstore.SubTypeV2BillingRecovery, func(context.Context, *api.NotificationV2) error {
+		called = "billing recovery"
+		return nil
+	})
+	h.Fallback = func(_ context.Context, n *api.NotificationV2) error {
+		called = "fallback " + string(n.NotificationType)
+		return nil
+	}
+
+	tests := []struct {
+		Type    appstore.NotificationTypeV2
+		Subtype appstore.SubtypeV2
+		Called  string
+	}{
+		{Type: appstore.NotificationTypeV2DidRenew, Called: "renew"},
+		{Type: appstore.NotificationTypeV2DidRenew, Subtype: appstore.SubTypeV2BillingRecovery, Called: "billing recovery"},
+		{Type: appstore.NotificationTypeV2Refund, Called: "fallback REFUND"},
+	}
+	for _, tt := range tests {
+		called = ""
+		rec := serveNotification(h, http.MethodPost, newTestNotificationBody(t, ca, jwt.MapClaims{"notificationType": tt.Type, "subtype": tt.Subtype}))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, tt.Called, called)
+	}
+}
+
+func TestNotificationHandler_StatusCode(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+	client := newTestParserClient(ca)
+
+	valid := newTestNotificationBody(t, ca, nil)
+	forged := newTestNotificationBody(t, apitest.NewCA(nil), nil)
+	tests := []struct {
+		Name    string
+		Method  string
+		Body    string
+		Decoder api.NotificationDecoder
+		Handler api.NotificationFunc
+		Status  int
+		Called  bool
+	}{
+		{Name: "handled", Body: valid, Status: http.StatusOK, Called: true},
+		{
+			Name:   "other app",
+			Body:   newTestNotificationBody(t, ca, jwt.MapClaims{"data": map[string]any{"bundleId": "com.example.other", "environment": "Sandbox"}}),
+			Status: http.StatusOK,
+		},
+		{
+			Name:    "handler failure",
+			Body:    valid,
+			Handler: func(context.Context, *api.NotificationV2) error { return errors.New("database unavailable") },
+			Status:  http.StatusInternalServerError,
+			Called:  true,
+		},
+		{Name: "not a post", Method: http.MethodGet, Status: http.StatusMethodNotAllowed},
+		{Name: "malformed body", Body: `{"signedPayload":`, Status: http.StatusBadRequest},
+		{Name: "no payload", Body: `{}`, Status: http.StatusBadRequest},
+		{Name: "untrusted signature", Body: forged, Status: http.StatusBadRequest},
+		{
+			Name:    "revocation status unavailable",
+			Body:    valid,
+			Decoder: failingDecoder{err: fmt.Errorf("check leaf: %w", appstore.ErrOCSPUnavailable)},
+			Status:  http.StatusServiceUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			decoder := tt.Decoder
+			if decoder == nil {
+				decoder = client
+			}
+			called := false
+			h := api.NewNotificationHandler(decoder, "com.example.app", api.Sandbox)
+			h.Handle(appstore.NotificationTypeV2DidRenew, func(ctx context.Context, n *api.NotificationV2) error {
+				called = true
+				if tt.Handler != nil {
+					return tt.Handler(ctx, n)
+				}
+				return nil
+			})
+
+			method := tt.Method
+			if method == "" {
+				method = http.MethodPost
+			}
+			rec := serveNotification(h, method, tt.Body)
+			assert.Equal(t, tt.Status, rec.Code)
+			assert.Equal(t, tt.Called, called)
+			assert.NotContains(t, rec.Body.String(), "check leaf")
+		})
+	}
+}
+
+func TestNotificationHandler_Accepts(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+
+	tests := []struct {
+		Name   string
+		Claims jwt.MapClaims
+		Called bool
+	}{
+		{Name: "data", Called: true},
+		{
+			Name:   "other environment",
+			Claims: jwt.MapClaims{"data": map[string]any{"bundleId": "com.example.app", "environment": "Production"}},
+		},
+		{
+			Name:   "app data",
+			Claims: jwt.MapClaims{"data": nil, "appData": map[string]any{"bundleId": "com.example.app", "environment": "Sandbox"}},
+			Called: true,
+		},
+		{
+			Name:   "app data of another app",
+			Claims: jwt.MapClaims{"data": nil, "appData": map[string]any{"bundleId": "com.example.other", "environment": "Sandbox"}},
+		},
+		{
+			Name:   "sandbox external purchase token",
+			Claims: jwt.MapClaims{"data": nil, "externalPurchaseToken": map[string]any{"bundleId": "com.example.app", "externalPurchaseId": "SANDBOX_b2158121"}},
+			Called: true,
+		},
+		{
+			Name:   "external purchase token of another app",
+			Claims: jwt.MapClaims{"data": nil, "externalPurchaseToken": map[string]any{"bundleId": "com.example.other", "externalPurchaseId": "SANDBOX_b2158121"}},
+		},
+		{
+			Name:   "production external purchase token",
+			Claims: jwt.MapClaims{"data": nil, "externalPurchaseToken": map[string]any{"bundleId": "com.example.app", "externalPurchaseId": "b2158121"}},
+		},
+		{Name: "no app", Claims: jwt.MapClaims{"data": nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			called := false
+			h := api.NewNotificationHandler(newTestParserClient(ca), "com.example.app", api.Sandbox)
+			h.Fallback = func(context.Context, *api.NotificationV2) error {
+				called = true
+				return nil
+			}
+			rec := serveNotification(h, http.MethodPost, newTestNotificationBody(t, ca, tt.Claims))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.Called, called)
+		})
+	}
+}
+
+// processedUUIDs is the store of the notification UUIDs processed by the server.
+type processedUUIDs map[string]bool
+
+func (p processedUUIDs) IsProcessed(_ context.Context, notificationUUID string) (bool, error) {
+	return p[notificationUUID], nil
+}
+
+func TestNotificationReconciler_NotificationHandler(t *testing.T) {
+	t.Parallel()
+	srv := apitest.NewServer(&apitest.Config{Environment: api.Sandbox})
+	t.Cleanup(srv.Close)
+	for _, uuid := range []string{"processed", "missed"} {
+		srv.AddNotification(&appstore.SubscriptionNotificationV2DecodedPayload{
+			NotificationType: appstore.NotificationTypeV2DidRenew,
+			NotificationUUID: uuid,
+			SignedDate:       time.Now().Add(-time.Minute).UnixMilli(),
+			Data: appstore.SubscriptionNotificationV2Data{
+				BundleID:              "com.example.app",
+				Environment:           string(api.Sandbox),
+				SignedTransactionInfo: appstore.JWSTransaction(srv.Sign(api.JWSTransaction{TransactionID: "2000000000000002"})),
+			},
+		}, api.SendAttemptItem{SendAttemptResult: api.FirstSendAttemptResultTimedOut})
+	}
+
+	client := api.NewStoreClient(srv.StoreConfig())
+	processed := processedUUIDs{"processed": true}
+	h := api.NewNotificationHandler(client, "com.example.app", api.Sandbox)
+	h.Handle(appstore.NotificationTypeV2DidRenew, func(_ context.Context, n *api.NotificationV2) error {
+		assert.Equal(t, "2000000000000002", n.Data.Transaction.TransactionID)
+		processed[n.NotificationUUID] = true
+		return nil
+	})
+	r := &api.NotificationReconciler{Client: client, Processed: processed, Handle: h.Dispatch}
+
+	report, err := r.Reconcile(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Processed)
+	require.Len(t, report.Missed, 1)
+	assert.True(t, report.Missed[0].Replayed)
+	assert.True(t, processed["missed"])
+}
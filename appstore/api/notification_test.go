@@ -0,0 +1,167 @@
+package api_test
+
+import (
+	"testing"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/api/apitest"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreClient_DecodeNotificationV2(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+	client := newTestParserClient(ca)
+
+	t.Run("data", func(t *testing.T) {
+		t.Parallel()
+		notification, err := client.DecodeNotificationV2(ca.Sign(jwt.MapClaims{
+			"notificationType": appstore.NotificationTypeV2ConsumptionRequest,
+			"notificationUUID": "002e14d5-51f5-4503-b5a8-c3a1af68eb20",
+			"version":          "2.0",
+			"signedDate":       1698148900000,
+			"data": map[string]any{
+				"appAppleId":               1234,
+				"appTransactionId":         "704237924728937265",
+				"bundleId":                 "com.example.app",
+				"environment":              "Sandbox",
+				"status":                   1,
+				"consumptionRequestReason": "UNINTENDED_PURCHASE",
+				"signedTransactionInfo":    ca.Sign(api.JWSTransaction{TransactionID: "2000000000000002", AppTransactionId: "704237924728937265"}),
+				"signedRenewalInfo":        ca.Sign(api.JWSRenewalInfoDecodedPayload{OriginalTransactionId: "2000000000000001", AutoRenewStatus: api.AutoRenewStatusOn}),
+			},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, appstore.NotificationTypeV2ConsumptionRequest, notification.NotificationType)
+		assert.Equal(t, "002e14d5-51f5-4503-b5a8-c3a1af68eb20", notification.NotificationUUID)
+		assert.Equal(t, int64(1698148900000), notification.SignedDate)
+		assert.Nil(t, notification.Summary)
+		assert.Nil(t, notification.ExternalPurchaseToken)
+		assert.Nil(t, notification.AppData)
+
+		require.NotNil(t, notification.Data)
+		assert.Equal(t, int64(1234), notification.Data.AppAppleID)
+		assert.Equal(t, "704237924728937265", notification.Data.AppTransactionId)
+		assert.Equal(t, api.Sandbox, notification.Data.Environment)
+		assert.Equal(t, appstore.AutoRenewableSubscriptionStatus(appstore.AutoRenewableSubscriptionStatusActive), notification.Data.Status)
+		assert.Equal(t, appstore.ConsumptionRequestReasonUnintendedPurchase, notification.Data.ConsumptionRequestReason)
+		require.NotNil(t, notification.Data.Transaction)
+		assert.Equal(t, "2000000000000002", notification.Data.Transaction.TransactionID)
+		require.NotNil(t, notification.Data.RenewalInfo)
+		assert.Equal(t, api.AutoRenewStatusOn, notification.Data.RenewalInfo.AutoRenewStatus)
+	})
+
+	t.Run("summary", func(t *testing.T) {
+		t.Parallel()
+		notification, err := client.DecodeNotificationV2(ca.Sign(jwt.MapClaims{
+			"notificationType": appstore.NotificationTypeV2RenewalExtension,
+			"subtype":          appstore.SubTypeV2Summary,
+			"summary": map[string]any{
+				"requestIdentifier": "758883e8-151b-47b7-abd0-60c4d804c2f5",
+				"environment":       "Production",
+				"bundleId":          "com.example.app",
+				"productId":         "com.example.monthly",
+				"succeededCount":    5,
+				"failedCount":       2,
+			},
+		}))
+		require.NoError(t, err)
+		assert.Nil(t, notification.Data)
+		require.NotNil(t, notification.Summary)
+		assert.Equal(t, "758883e8-151b-47b7-abd0-60c4d804c2f5", notification.Summary.RequestIdentifier)
+		assert.Equal(t, int64(5), notification.Summary.SucceededCount)
+		assert.Equal(t, int64(2), notification.Summary.FailedCount)
+	})
+
+	t.Run("external purchase token", func(t *testing.T) {
+		t.Parallel()
+		notification, err := client.DecodeNotificationV2(ca.Sign(jwt.MapClaims{
+			"notificationType": appstore.NotificationTypeV2ExternalPurchaseToken,
+			"subtype":          appstore.SubTypeV2Unreported,
+			"externalPurchaseToken": map[string]any{
+				"externalPurchaseId": "b2158121-7af9-49d4-9561-1f588205523e",
+				"tokenCreationDate":  1698148900000,
+				"appAppleId":         1234,
+				"bundleId":           "com.example.app",
+			},
+		}))
+		require.NoError(t, err)
+		assert.Nil(t, notification.Data)
+		assert.Equal(t, &appstore.ExternalPurchaseToken{
+			ExternalPurchaseId: "b2158121-7af9-49d4-9561-1f588205523e",
+			TokenCreationDate:  1698148900000,
+			AppAppleId:         1234,
+			BundleId:           "com.example.app",
+		}, notification.ExternalPurchaseToken)
+	})
+
+	t.Run("app data", func(t *testing.T) {
+		t.Parallel()
+		notification, err := client.DecodeNotificationV2(ca.Sign(jwt.MapClaims{
+			"notificationType": appstore.NotificationTypeV2Test,
+			"appData": map[string]any{
+				"appAppleId":               1234,
+				"bundleId":                 "com.example.app",
+				"environment":              "Sandbox",
+				"signedAppTransactionInfo": ca.Sign(api.JWSAppTransactionDecodedPayload{AppTransactionId: "704237924728937265"}),
+			},
+		}))
+		require.NoError(t, err)
+		require.NotNil(t, notification.AppData)
+		assert.Equal(t, api.Sandbox, notification.AppData.Environment)
+		require.NotNil(t, notification.AppData.AppTransaction)
+		assert.Equal(t, "704237924728937265", notification.AppData.AppTransaction.AppTransactionId)
+	})
+
+	t.Run("nested jws of another issuer", func(t *testing.T) {
+		t.Parallel()
+		untrusted := apitest.NewCA(nil)
+		_, err := client.DecodeNotificationV2(ca.Sign(jwt.MapClaims{
+			"notificationType": appstore.NotificationTypeV2DidRenew,
+			"data": map[string]any{
+				"bundleId":              "com.example.app",
+				"signedTransactionInfo": untrusted.Sign(api.JWSTransaction{TransactionID: "2000000000000002"}),
+			},
+		}))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "signedTransactionInfo")
+	})
+}
+
+func TestRegistry_ClientForNotification(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+	registry, err := api.NewRegistry(&api.RegistryConfig{
+		StoreConfig: api.StoreConfig{RootCertificates: ca.RootCertificates()},
+		Apps:        []api.AppConfig{{BundleID: "com.example.one"}, {BundleID: "com.example.two"}},
+	})
+	require.NoError(t, err)
+
+	client, err := registry.ClientForNotification(&appstore.SubscriptionNotificationV2DecodedPayload{
+		Data: appstore.SubscriptionNotificationV2Data{BundleID: "com.example.two"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "com.example.two", client.Production().Token.BundleID)
+
+	client, notification, err := registry.ParseSignedNotification(ca.Sign(appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationType: appstore.NotificationTypeV2Test,
+		Summary:          appstore.SubscriptionNotificationV2Summary{BundleID: "com.example.one"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, appstore.NotificationTypeV2Test, notification.NotificationType)
+	assert.Equal(t, "com.example.one", client.Production().Token.BundleID)
+
+	client, err = registry.ClientForNotification(&appstore.SubscriptionNotificationV2DecodedPayload{
+		ExternalPurchaseToken: appstore.ExternalPurchaseToken{BundleId: "com.example.two"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "com.example.two", client.Production().Token.BundleID)
+
+	_, _, err = registry.ParseSignedNotification(ca.Sign(appstore.SubscriptionNotificationV2DecodedPayload{
+		Data: appstore.SubscriptionNotificationV2Data{BundleID: "com.example.unknown"},
+	}))
+	assert.ErrorIs(t, err, api.ErrUnknownBundleID)
+}
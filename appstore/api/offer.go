@@ -0,0 +1,136 @@
+package api
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Audiences of the JWS signatures created by PromotionalOfferSigner.
+const (
+	AudiencePromotionalOffer             = "promotional-offer"
+	AudienceIntroductoryOfferEligibility = "introductory-offer-eligibility"
+)
+
+// promotionalOfferSeparator separates the fields of the legacy promotional offer payload.
+const promotionalOfferSeparator = "\u2063" // INVISIBLE SEPARATOR
+
+// PromotionalOffer is the subscription offer a legacy promotional offer signature is generated for.
+// Doc: https://developer.apple.com/documentation/storekit/generating-a-signature-for-promotional-offers
+type PromotionalOffer struct {
+	ProductID           string    // The subscription product identifier
+	OfferID             string    // The promotional offer identifier from App Store Connect
+	ApplicationUsername string    // The app account token of the purchase, or an empty string. It is signed lowercased like StoreKit does.
+	Nonce               uuid.UUID // A one-time UUID, passed to StoreKit with the signature
+	Timestamp           int64     // The signature creation time, in UNIX time milliseconds
+}
+
+// PromotionalOfferSigner signs StoreKit promotional offers and introductory offer eligibility with an In-App Purchase key.
+// It reuses the .p8 key or the Signer, key ID, bundle ID and issuer ID of the StoreConfig.
+type PromotionalOfferSigner struct {
+	key          crypto.Signer
+	keyID        string
+	bundleID     string
+	issuer       string
+	issuedAtFunc func() int64
+}
+
+// NewPromotionalOfferSigner creates a PromotionalOfferSigner from the key of the config.
+func NewPromotionalOfferSigner(config *StoreConfig) (*PromotionalOfferSigner, error) {
+	var key crypto.Signer = config.Signer
+	if key == nil {
+		pk, err := parsePrivateKey(config.KeyContent)
+		if err != nil {
+			return nil, err
+		}
+		key = pk
+	}
+	if pub, ok := key.Public().(*ecdsa.PublicKey); !ok || pub.Curve != elliptic.P256() {
+		return nil, ErrSignerInvalidType
+	}
+	return &PromotionalOfferSigner{
+		key:          key,
+		keyID:        config.KeyID,
+		bundleID:     config.BundleID,
+		issuer:       config.Issuer,
+		issuedAtFunc: config.TokenIssuedAtFunc,
+	}, nil
+}
+
+// SignPromotionalOffer returns the base64 encoded signature of the legacy promotional offer format,
+// to be passed to StoreKit together with the key ID, nonce and timestamp of the offer.
+// Doc: https://developer.apple.com/documentation/storekit/generating-a-signature-for-promotional-offers
+func (s *PromotionalOfferSigner) SignPromotionalOffer(offer PromotionalOffer) (string, error) {
+	payload := strings.Join([]string{
+		s.bundleID,
+		s.keyID,
+		offer.ProductID,
+		offer.OfferID,
+		strings.ToLower(offer.ApplicationUsername),
+		offer.Nonce.String(),
+		strconv.FormatInt(offer.Timestamp, 10),
+	}, promotionalOfferSeparator)
+
+	digest := sha256.Sum256([]byte(payload))
+	signature, err := s.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// CreatePromotionalOfferJWS returns the JWS signature of a promotional offer, transactionID is optional.
+// Doc: https://developer.apple.com/documentation/storekit/generating-jws-to-sign-app-store-requests
+func (s *PromotionalOfferSigner) CreatePromotionalOfferJWS(productID, offerID, transactionID string) (string, error) {
+	claims := jwt.MapClaims{
+		"productId":       productID,
+		"offerIdentifier": offerID,
+	}
+	if transactionID != "" {
+		claims["transactionId"] = transactionID
+	}
+	return s.sign(AudiencePromotionalOffer, claims)
+}
+
+// CreateIntroductoryOfferEligibilityJWS returns the JWS signature that sets whether the customer is eligible
+// for the introductory offer of productID, transactionID is any transaction ID of the customer.
+// Doc: https://developer.apple.com/documentation/storekit/generating-jws-to-sign-app-store-requests
+func (s *PromotionalOfferSigner) CreateIntroductoryOfferEligibilityJWS(productID string, allowIntroductoryOffer bool, transactionID string) (string, error) {
+	return s.sign(AudienceIntroductoryOfferEligibility, jwt.MapClaims{
+		"productId":              productID,
+		"allowIntroductoryOffer": allowIntroductoryOffer,
+		"transactionId":          transactionID,
+	})
+}
+
+func (s *PromotionalOfferSigner) sign(audience string, claims jwt.MapClaims) (string, error) {
+	issuedAt := time.Now().Unix()
+	if s.issuedAtFunc != nil {
+		issuedAt = s.issuedAtFunc()
+	}
+	claims["iss"] = s.issuer
+	claims["iat"] = issuedAt
+	claims["aud"] = audience
+	claims["bid"] = s.bundleID
+	claims["nonce"] = uuid.New()
+
+	jwtToken := &jwt.Token{
+		Header: map[string]interface{}{
+			"alg": "ES256",
+			"kid": s.keyID,
+			"typ": "JWT",
+		},
+		Claims: claims,
+		Method: signingMethodES256Signer,
+	}
+	return jwtToken.SignedString(s.key)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPromotionalOfferSigner(t *testing.T) (*PromotionalOfferSigner, *ecdsa.PublicKey) {
+	t.Helper()
+	signer, err := NewPromotionalOfferSigner(&StoreConfig{
+		KeyContent:        newTestKeyContent(t),
+		KeyID:             "TESTKEYID",
+		BundleID:          "com.example.app",
+		Issuer:            "test-issuer",
+		TokenIssuedAtFunc: func() int64 { return 1700000000 },
+	})
+	require.NoError(t, err)
+	return signer, signer.key.Public().(*ecdsa.PublicKey)
+}
+
+func TestNewPromotionalOfferSigner(t *testing.T) {
+	t.Parallel()
+	_, err := NewPromotionalOfferSigner(&StoreConfig{KeyContent: []byte("invalid")})
+	assert.ErrorIs(t, err, ErrAuthKeyInvalidPem)
+}
+
+func TestPromotionalOfferSigner_SignPromotionalOffer(t *testing.T) {
+	t.Parallel()
+	signer, pub := newTestPromotionalOfferSigner(t)
+	offer := PromotionalOffer{
+		ProductID:           "com.example.monthly",
+		OfferID:             "WINBACK",
+		ApplicationUsername: "9c8a8e2c-0f2b-4b6c-9a4e-6b1f1c3d2e10",
+		Nonce:               uuid.MustParse("D7E1C6E0-2A8F-4A43-9C38-5B0A0B7A6D11"),
+		Timestamp:           1700000000000,
+	}
+
+	signature, err := signer.SignPromotionalOffer(offer)
+	require.NoError(t, err)
+	der, err := base64.StdEncoding.DecodeString(signature)
+	require.NoError(t, err)
+
+	payload := "com.example.app\u2063TESTKEYID\u2063com.example.monthly\u2063WINBACK\u2063" +
+		"9c8a8e2c-0f2b-4b6c-9a4e-6b1f1c3d2e10\u2063d7e1c6e0-2a8f-4a43-9c38-5b0a0b7a6d11\u20631700000000000"
+	digest := sha256.Sum256([]byte(payload))
+	assert.True(t, ecdsa.VerifyASN1(pub, digest[:], der))
+
+	offer.OfferID = "OTHER"
+	other, err := signer.SignPromotionalOffer(offer)
+	require.NoError(t, err)
+	der, err = base64.StdEncoding.DecodeString(other)
+	require.NoError(t, err)
+	assert.False(t, ecdsa.VerifyASN1(pub, digest[:], der))
+
+	offer.OfferID = "WINBACK"
+	offer.ApplicationUsername = "9C8A8E2C-0F2B-4B6C-9A4E-6B1F1C3D2E10"
+	upper, err := signer.SignPromotionalOffer(offer)
+	require.NoError(t, err)
+	der, err = base64.StdEncoding.DecodeString(upper)
+	require.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(pub, digest[:], der))
+}
+
+func TestPromotionalOfferSigner_JWS(t *testing.T) {
+	t.Parallel()
+	signer, pub := newTestPromotionalOfferSigner(t)
+
+	parse := func(t *testing.T, signed string) (*jwt.Token, jwt.MapClaims) {
+		t.Helper()
+		claims := jwt.MapClaims{}
+		token, err := jwt.ParseWithClaims(signed, claims, func(token *jwt.Token) (interface{}, error) {
+			return pub, nil
+		}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithoutClaimsValidation())
+		require.NoError(t, err)
+		return token, claims
+	}
+
+	t.Run("promotional offer", func(t *testing.T) {
+		t.Parallel()
+		signed, err := signer.CreatePromotionalOfferJWS("com.example.monthly", "WINBACK", "")
+		require.NoError(t, err)
+		token, claims := parse(t, signed)
+		assert.Equal(t, "TESTKEYID", token.Header["kid"])
+		assert.Equal(t, AudiencePromotionalOffer, claims["aud"])
+		assert.Equal(t, "test-issuer", claims["iss"])
+		assert.Equal(t, "com.example.app", claims["bid"])
+		assert.Equal(t, float64(1700000000), claims["iat"])
+		assert.Equal(t, "com.example.monthly", claims["productId"])
+		assert.Equal(t, "WINBACK", claims["offerIdentifier"])
+		assert.NotEmpty(t, claims["nonce"])
+		assert.NotContains(t, claims, "transactionId")
+	})
+
+	t.Run("introductory offer eligibility", func(t *testing.T) {
+		t.Parallel()
+		signed, err := signer.CreateIntroductoryOfferEligibilityJWS("com.example.monthly", false, "2000000000000001")
+		require.NoError(t, err)
+		_, claims := parse(t, signed)
+		assert.Equal(t, AudienceIntroductoryOfferEligibility, claims["aud"])
+		assert.Equal(t, false, claims["allowIntroductoryOffer"])
+		assert.Equal(t, "2000000000000001", claims["transactionId"])
+	})
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TransactionParseError reports a signed transaction of a batch that failed to be verified or decoded.
+type TransactionParseError struct {
+	Index int   // Index of the signed transaction in the batch
+	Err   error // The verification or decoding error
+}
+
+func (e *TransactionParseError) Error() string {
+	return fmt.Sprintf("appstore signed transaction %d: %v", e.Index, e.Err)
+}
+
+func (e *TransactionParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseTransactionsOptions configures ParseSignedTransactionsWithOptions.
+type ParseTransactionsOptions struct {
+	// Strict fails the whole batch on the first signed transaction that cannot be verified or decoded.
+	Strict bool
+}
+
+// ParsedTransactions is the result of parsing a batch of signed transactions.
+type ParsedTransactions struct {
+	Transactions []*JWSTransaction        // The verified transactions, in batch order
+	Failures     []*TransactionParseError // The signed transactions that failed, in batch order
+}
+
+// Err joins the failures, it returns nil when every signed transaction was verified.
+func (p *ParsedTransactions) Err() error {
+	errs := make([]error, len(p.Failures))
+	for i, f := range p.Failures {
+		errs[i] = f
+	}
+	return errors.Join(errs...)
+}
+
+// ParseSignedTransactionsWithOptions parses a batch of jws signed transactions, such as a page of GetTransactionHistory.
+// By default every signed transaction is parsed and the failures are reported alongside the verified transactions,
+// the returned error joins the failures. In strict mode the first failure is returned and the result is nil.
+func (a *StoreClient) ParseSignedTransactionsWithOptions(transactions []string, opts ParseTransactionsOptions) (*ParsedTransactions, error) {
+	result := &ParsedTransactions{
+		Transactions: make([]*JWSTransaction, 0, len(transactions)),
+	}
+	for i, v := range transactions {
+		tran, err := a.ParseSignedTransaction(v)
+		if err != nil {
+			failure := &TransactionParseError{Index: i, Err: err}
+			if opts.Strict {
+				return nil, failure
+			}
+			result.Failures = append(result.Failures, failure)
+			continue
+		}
+		result.Transactions = append(result.Transactions, tran)
+	}
+
+	return result, result.Err()
+}
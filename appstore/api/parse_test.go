@@ -0,0 +1,144 @@
+package api_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/api/apitest"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestParserClient returns a StoreClient trusting the root of ca for parsing signed data.
+func newTestParserClient(ca *apitest.CA) *api.StoreClient {
+	return api.NewStoreClient(&api.StoreConfig{RootCertificates: ca.RootCertificates()})
+}
+
+func TestStoreClient_ParseSignedTransactionsWithOptions(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+	client := newTestParserClient(ca)
+
+	valid := ca.Sign(api.JWSTransaction{TransactionID: "1"})
+	parts := strings.Split(ca.Sign(api.JWSTransaction{TransactionID: "2"}), ".")
+	tampered := strings.Join([]string{parts[0], strings.Split(ca.Sign(api.JWSTransaction{TransactionID: "3"}), ".")[1], parts[2]}, ".")
+	untrusted := apitest.NewCA(nil).Sign(api.JWSTransaction{TransactionID: "4"})
+	batch := []string{valid, tampered, valid, untrusted}
+
+	t.Run("failures are reported with their index", func(t *testing.T) {
+		t.Parallel()
+		result, err := client.ParseSignedTransactionsWithOptions(batch, api.ParseTransactionsOptions{})
+		require.Error(t, err)
+		require.NotNil(t, result)
+		assert.Len(t, result.Transactions, 2)
+		require.Len(t, result.Failures, 2)
+		assert.Equal(t, 1, result.Failures[0].Index)
+		assert.Equal(t, 3, result.Failures[1].Index)
+
+		var failure *api.TransactionParseError
+		assert.True(t, errors.As(err, &failure))
+		assert.Equal(t, err.Error(), result.Err().Error())
+	})
+
+	t.Run("strict mode fails on the first bad item", func(t *testing.T) {
+		t.Parallel()
+		result, err := client.ParseSignedTransactionsWithOptions(batch, api.ParseTransactionsOptions{Strict: true})
+		assert.Nil(t, result)
+		var failure *api.TransactionParseError
+		require.True(t, errors.As(err, &failure))
+		assert.Equal(t, 1, failure.Index)
+	})
+
+	t.Run("valid batch has no error", func(t *testing.T) {
+		t.Parallel()
+		transactions, err := client.ParseSignedTransactions([]string{valid, valid})
+		assert.NoError(t, err)
+		assert.Len(t, transactions, 2)
+
+		transactions, err = client.ParseSignedTransactions(nil)
+		assert.NoError(t, err)
+		assert.Empty(t, transactions)
+	})
+}
+
+func TestStoreClient_ParseSigned(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+	client := newTestParserClient(ca)
+
+	renewalInfo, err := client.ParseSignedRenewalInfo(ca.Sign(api.JWSRenewalInfoDecodedPayload{OriginalTransactionId: "1", AutoRenewStatus: api.AutoRenewStatusOn}))
+	require.NoError(t, err)
+	assert.Equal(t, api.AutoRenewStatusOn, renewalInfo.AutoRenewStatus)
+
+	appTran, err := client.ParseSignedAppTransaction(ca.Sign(api.JWSAppTransactionDecodedPayload{BundleId: "com.example.app", OriginalApplicationVersion: "1.0"}))
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", appTran.OriginalApplicationVersion)
+
+	notification, err := client.ParseSignedNotification(ca.Sign(appstore.SubscriptionNotificationV2DecodedPayload{NotificationType: appstore.NotificationTypeV2Test}))
+	require.NoError(t, err)
+	assert.Equal(t, appstore.NotificationTypeV2Test, notification.NotificationType)
+
+	_, err = client.ParseSignedRenewalInfo(apitest.NewCA(nil).Sign(api.JWSRenewalInfoDecodedPayload{}))
+	assert.Error(t, err, "untrusted chain must be rejected")
+}
+
+func TestStoreConfig_RootCertificates(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+	signed := ca.Sign(api.JWSTransaction{TransactionID: "1"})
+
+	tran, err := api.NewStoreClient(&api.StoreConfig{RootCertificates: ca.RootCertificates()}).ParseSignedTransaction(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "1", tran.TransactionID)
+
+	_, err = api.NewStoreClient(&api.StoreConfig{}).ParseSignedTransaction(signed)
+	assert.Error(t, err, "the test root isn't trusted by default")
+
+	_, err = api.NewStoreClient(&api.StoreConfig{RootCertificates: [][]byte{[]byte("not a certificate")}}).ParseSignedTransaction(signed)
+	assert.ErrorContains(t, err, "failed to parse root certificate")
+}
+
+func TestStoreClient_ParseJWSEncodeString(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+	client := newTestParserClient(ca)
+
+	tests := []struct {
+		Name   string
+		Claims jwt.Claims
+		Want   interface{}
+	}{
+		{
+			Name:   "transaction",
+			Claims: jwt.MapClaims{"transactionId": "1", "originalTransactionId": "1"},
+			Want:   &api.JWSTransaction{},
+		},
+		{
+			Name:   "renewal info mentioning a transaction id",
+			Claims: jwt.MapClaims{"transactionId": "1", "originalTransactionId": "1", "autoRenewStatus": 1},
+			Want:   &api.JWSRenewalInfoDecodedPayload{},
+		},
+		{
+			Name:   "app transaction",
+			Claims: jwt.MapClaims{"appTransactionId": "1", "originalApplicationVersion": "1.0"},
+			Want:   &api.JWSAppTransactionDecodedPayload{},
+		},
+		{
+			Name:   "notification",
+			Claims: jwt.MapClaims{"notificationType": "TEST", "notificationUUID": "uuid"},
+			Want:   &appstore.SubscriptionNotificationV2DecodedPayload{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			got, err := client.ParseJWSEncodeString(ca.Sign(tt.Claims))
+			require.NoError(t, err)
+			assert.IsType(t, tt.Want, got)
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned in RateLimitFailFast mode when the budget of an endpoint is exhausted.
+var ErrRateLimited = errors.New("ratelimit: endpoint budget exhausted")
+
+// RateLimitMode decides what happens to a request whose endpoint budget is exhausted.
+type RateLimitMode int
+
+const (
+	// RateLimitBlock waits until the endpoint budget allows the request, or the context is done.
+	RateLimitBlock RateLimitMode = iota
+	// RateLimitFailFast returns ErrRateLimited immediately.
+	RateLimitFailFast
+)
+
+// RateLimit is the request budget of an endpoint, Requests per Per, such as 3600 per time.Hour.
+// The whole budget may be used in a burst.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// pathTemplates are the endpoints the requests are budgeted by.
+var pathTemplates = []string{
+	PathLookUp,
+	PathTransactionHistory,
+	PathTransactionHistoryV1,
+	PathTransactionInfo,
+	PathRefundHistory,
+	PathGetALLSubscriptionStatus,
+	PathConsumptionInfoV2,
+	PathConsumptionInfo,
+	PathExtendSubscriptionRenewalDate,
+	PathExtendSubscriptionRenewalDateForAll,
+	PathGetStatusOfSubscriptionRenewalDate,
+	PathGetNotificationHistory,
+	PathRequestTestNotification,
+	PathGetTestNotificationStatus,
+	PathSetAppAccountToken,
+	PathGetAppTransactionInfo,
+	PathFinishTransaction,
+}
+
+// RateLimiter budgets the App Store Server API requests with a token bucket per endpoint path template,
+// such as PathTransactionHistory, so that a client stays within the hourly limits Apple enforces per endpoint.
+// Clients sharing a RateLimiter share the budgets, give bulk jobs their own RateLimiter with smaller limits
+// to keep the rest of the quota for live traffic. A RateLimiter is safe for concurrent use.
+type RateLimiter struct {
+	Limits  map[string]RateLimit // Budget per path template. Endpoints without a limit are not limited unless Default is set.
+	Default *RateLimit           // Optional budget of each endpoint without a limit in Limits
+	Mode    RateLimitMode        // Default is RateLimitBlock.
+
+	// OnBudget, if not nil, is called with the remaining budget of the endpoint after each request takes from it.
+	OnBudget func(pathTemplate string, remaining float64)
+	// Now is the time source of the buckets. Default is time.Now.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	capacity float64
+	rate     float64 // tokens per second
+	tokens   float64
+	updated  time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = min(b.capacity, b.tokens+elapsed*b.rate)
+	}
+	b.updated = now
+}
+
+// Wait takes a request from the budget of the endpoint of rawURL. In RateLimitBlock mode it waits for the budget
+// to refill, in RateLimitFailFast mode it returns ErrRateLimited.
+func (l *RateLimiter) Wait(ctx context.Context, rawURL string) error {
+	template, ok := matchPathTemplate(rawURL)
+	if !ok {
+		return nil
+	}
+	for {
+		wait, remaining, limited := l.take(template)
+		if !limited {
+			return nil
+		}
+		if wait <= 0 {
+			if l.OnBudget != nil {
+				l.OnBudget(template, remaining)
+			}
+			return nil
+		}
+		if l.Mode == RateLimitFailFast {
+			return fmt.Errorf("%w: %s, retry in %v", ErrRateLimited, template, wait)
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Remaining returns the remaining budget of the endpoint path template, false if it isn't limited.
+func (l *RateLimiter) Remaining(pathTemplate string) (float64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(pathTemplate)
+	if b == nil {
+		return 0, false
+	}
+	b.refill(l.now())
+	return b.tokens, true
+}
+
+// exhaust empties the budget of the endpoint of rawURL, when Apple answered that its limit is exceeded.
+func (l *RateLimiter) exhaust(rawURL string) {
+	template, ok := matchPathTemplate(rawURL)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b := l.bucket(template); b != nil {
+		b.refill(l.now())
+		b.tokens = 0
+	}
+}
+
+// take takes a token of the bucket of template, or returns how long to wait for one.
+func (l *RateLimiter) take(template string) (wait time.Duration, remaining float64, limited bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(template)
+	if b == nil {
+		return 0, 0, false
+	}
+	b.refill(l.now())
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, b.tokens, true
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second)), b.tokens, true
+}
+
+// bucket returns the bucket of template, nil if it isn't limited. l.mu must be held.
+func (l *RateLimiter) bucket(template string) *tokenBucket {
+	if b, ok := l.buckets[template]; ok {
+		return b
+	}
+	limit, ok := l.Limits[template]
+	if !ok {
+		if l.Default == nil {
+			return nil
+		}
+		limit = *l.Default
+	}
+	if limit.Requests <= 0 || limit.Per <= 0 {
+		return nil
+	}
+
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	b := &tokenBucket{
+		capacity: float64(limit.Requests),
+		rate:     float64(limit.Requests) / limit.Per.Seconds(),
+		tokens:   float64(limit.Requests),
+		updated:  l.now(),
+	}
+	l.buckets[template] = b
+	return b
+}
+
+func (l *RateLimiter) now() time.Time {
+	if l.Now != nil {
+		return l.Now()
+	}
+	return time.Now()
+}
+
+// matchPathTemplate returns the path template of the endpoint of rawURL.
+// A literal segment is preferred over a parameter, so that /transactions/appTransactions/{id} wins over /transactions/{id}/...
+func matchPathTemplate(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	segments := strings.Split(u.Path, "/")
+
+	best, bestLiterals := "", -1
+	for _, template := range pathTemplates {
+		templateSegments := strings.Split(template, "/")
+		if len(templateSegments) != len(segments) {
+			continue
+		}
+		literals := 0
+		for i, s := range templateSegments {
+			if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+				if segments[i] == "" {
+					literals = -1
+					break
+				}
+				continue
+			}
+			if s != segments[i] {
+				literals = -1
+				break
+			}
+			literals++
+		}
+		if literals > bestLiterals {
+			best, bestLiterals = template, literals
+		}
+	}
+	return best, bestLiterals >= 0
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPathTemplate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		URL      string
+		Template string
+	}{
+		{URL: HostProduction + "/inApps/v2/history/1000000000000001?revision=abc", Template: PathTransactionHistory},
+		{URL: HostProduction + "/inApps/v1/subscriptions/1000000000000001", Template: PathGetALLSubscriptionStatus},
+		{URL: HostProduction + "/inApps/v1/transactions/1000000000000001", Template: PathTransactionInfo},
+		{URL: HostProduction + "/inApps/v1/transactions/appTransactions/1000000000000001", Template: PathGetAppTransactionInfo},
+		{URL: HostProduction + "/inApps/v1/transactions/consumption/1000000000000001", Template: PathConsumptionInfo},
+		{URL: HostProduction + "/inApps/v1/transactions/1000000000000001/finish", Template: PathFinishTransaction},
+		{URL: HostProduction + "/inApps/v1/subscriptions/extend/mass/", Template: PathExtendSubscriptionRenewalDateForAll},
+		{URL: HostProduction + "/inApps/v1/notifications/test", Template: PathRequestTestNotification},
+		{URL: HostProduction + "/inApps/v1/notifications/test/token", Template: PathGetTestNotificationStatus},
+		{URL: HostProduction + "/unknown", Template: ""},
+	}
+	for _, tt := range tests {
+		template, ok := matchPathTemplate(tt.URL)
+		assert.Equal(t, tt.Template != "", ok, tt.URL)
+		assert.Equal(t, tt.Template, template, tt.URL)
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	var budgets []float64
+	limiter := &RateLimiter{
+		Limits: map[string]RateLimit{PathTransactionHistory: {Requests: 2, Per: time.Hour}},
+		Mode:   RateLimitFailFast,
+		Now:    func() time.Time { return now },
+		OnBudget: func(pathTemplate string, remaining float64) {
+			assert.Equal(t, PathTransactionHistory, pathTemplate)
+			budgets = append(budgets, remaining)
+		},
+	}
+	ctx := context.Background()
+	history := HostProduction + "/inApps/v2/history/1"
+
+	assert.NoError(t, limiter.Wait(ctx, history))
+	assert.NoError(t, limiter.Wait(ctx, history))
+	assert.ErrorIs(t, limiter.Wait(ctx, history), ErrRateLimited)
+	assert.Equal(t, []float64{1, 0}, budgets)
+
+	for range 5 {
+		assert.NoError(t, limiter.Wait(ctx, HostProduction+"/inApps/v1/subscriptions/1"), "endpoints have independent budgets")
+	}
+	_, ok := limiter.Remaining(PathGetALLSubscriptionStatus)
+	assert.False(t, ok)
+
+	now = now.Add(30 * time.Minute)
+	remaining, ok := limiter.Remaining(PathTransactionHistory)
+	assert.True(t, ok)
+	assert.InDelta(t, 1, remaining, 0.001)
+	assert.NoError(t, limiter.Wait(ctx, history))
+
+	limiter.exhaust(history)
+	now = now.Add(time.Minute)
+	assert.ErrorIs(t, limiter.Wait(ctx, history), ErrRateLimited)
+}
+
+func TestRateLimiter_WaitBlock(t *testing.T) {
+	t.Parallel()
+	limiter := &RateLimiter{Default: &RateLimit{Requests: 1, Per: 50 * time.Millisecond}}
+	ctx := context.Background()
+	url := HostProduction + "/inApps/v1/transactions/1"
+
+	start := time.Now()
+	require.NoError(t, limiter.Wait(ctx, url))
+	require.NoError(t, limiter.Wait(ctx, url))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, limiter.Wait(ctx, url), context.Canceled)
+}
+
+func TestStoreClient_RateLimiter(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeAPIError(w, http.StatusTooManyRequests, RateLimitExceededError)
+	}), func(config *StoreConfig) {
+		config.RateLimiter = &RateLimiter{Default: &RateLimit{Requests: 10, Per: time.Hour}, Mode: RateLimitFailFast}
+	})
+
+	_, err := client.GetTransactionInfo(context.Background(), "1")
+	assert.ErrorIs(t, err, RateLimitExceededError)
+	_, err = client.GetTransactionInfo(context.Background(), "1")
+	assert.ErrorIs(t, err, ErrRateLimited, "rate limit exceeded answer must exhaust the budget")
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+)
+
+// ErrNoNotificationFunc is returned by NotificationReconciler.Reconcile when it has no func to replay the notifications with.
+var ErrNoNotificationFunc = errors.New("appstore api: a Handle func is required unless in dry run")
+
+// NotificationReconcileClient is the part of the App Store Server API a NotificationReconciler calls, such as a StoreClient or an APIClient.
+// It decodes the notifications like the NotificationDecoder of a NotificationHandler.
+type NotificationReconcileClient interface {
+	NotificationHistoryIter(ctx context.Context, body NotificationHistoryRequest, opts *PageOptions) iter.Seq2[NotificationHistoryResponseItem, error]
+	NotificationDecoder
+}
+
+// ProcessedNotifications is the store of the notifications the server already processed, by notification UUID.
+type ProcessedNotifications interface {
+	IsProcessed(ctx context.Context, notificationUUID string) (bool, error)
+}
+
+// MissedNotification is a notification of the history that the server didn't process.
+type MissedNotification struct {
+	Notification           *NotificationV2        // The decoded notification
+	FirstSendAttemptResult FirstSendAttemptResult // The result of the first attempt to send it to the server
+	SendAttempts           []SendAttemptItem      // The attempts to send it to the server
+	Replayed               bool                   // Whether Handle processed it
+}
+
+// NotificationReplayError reports a notification of the history that couldn't be decoded or replayed.
+type NotificationReplayError struct {
+	NotificationUUID string // The notification UUID, empty when the signed payload couldn't be decoded
+	Err              error  // The decoding or Handle error
+}
+
+func (e *NotificationReplayError) Error() string {
+	if e.NotificationUUID == "" {
+		return fmt.Sprintf("appstore notification: %v", e.Err)
+	}
+	return fmt.Sprintf("appstore notification %s: %v", e.NotificationUUID, e.Err)
+}
+
+func (e *NotificationReplayError) Unwrap() error {
+	return e.Err
+}
+
+// NotificationReconcileReport is the outcome of a reconciliation.
+type NotificationReconcileReport struct {
+	Scanned   int                   // Number of notifications in the history of the window
+	Processed int                   // Number of notifications the server already processed
+	Missed    []*MissedNotification // The notifications the server didn't process, in history order
+	// SendAttemptResults counts the results of the attempts to send the missed notifications, such as
+	// FirstSendAttemptResultTimedOut. It tells why the server missed them.
+	SendAttemptResults map[FirstSendAttemptResult]int
+	Failures           []*NotificationReplayError // The notifications that couldn't be decoded or replayed
+}
+
+// Err joins the failures, it returns nil when every missed notification was replayed.
+func (r *NotificationReconcileReport) Err() error {
+	errs := make([]error, len(r.Failures))
+	for i, f := range r.Failures {
+		errs[i] = f
+	}
+	return errors.Join(errs...)
+}
+
+// FailedSendAttempts returns the number of failed attempts to send the missed notifications.
+func (r *NotificationReconcileReport) FailedSendAttempts() int {
+	n := 0
+	for result, count := range r.SendAttemptResults {
+		if result != FirstSendAttemptResultSuccess {
+			n += count
+		}
+	}
+	return n
+}
+
+// NotificationReconciler finds the App Store Server Notifications V2 the server missed in the notification history,
+// and replays them. A notification is missed when its UUID isn't in the Processed store, whether Apple failed to
+// deliver it or the server failed to process it.
+type NotificationReconciler struct {
+	Client    NotificationReconcileClient
+	Processed ProcessedNotifications
+	// Handle processes the missed notifications. Use the Dispatch of the NotificationHandler of the live notification
+	// endpoint, decoding with the same Client, and record the notification in the Processed store from its funcs.
+	// Required unless DryRun.
+	Handle NotificationFunc
+	// DryRun reports the missed notifications without replaying them.
+	DryRun bool
+	// OnlyFailures restricts the history to the notifications Apple failed to deliver. By default the whole history
+	// is compared, including the notifications the server acknowledged but didn't record as processed.
+	OnlyFailures bool
+	// Page configures the paging of the history, such as resuming from a cursor.
+	Page *PageOptions
+}
+
+// Reconcile pages the notification history between start and end, and replays the notifications whose UUID isn't
+// in the Processed store through Handle, in history order. A notification that can't be decoded or replayed is
+// reported in the failures and the reconciliation goes on, the returned error joins the failures.
+// An error of the history or of the Processed store stops it and is returned with the report so far.
+func (r *NotificationReconciler) Reconcile(ctx context.Context, start, end time.Time) (*NotificationReconcileReport, error) {
+	if r.Handle == nil && !r.DryRun {
+		return nil, ErrNoNotificationFunc
+	}
+
+	report := &NotificationReconcileReport{SendAttemptResults: make(map[FirstSendAttemptResult]int)}
+	body := NotificationHistoryRequest{
+		StartDate:    start.UnixMilli(),
+		EndDate:      end.UnixMilli(),
+		OnlyFailures: r.OnlyFailures,
+	}
+	for item, err := range r.Client.NotificationHistoryIter(ctx, body, r.Page) {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Scanned++
+
+		notification, err := r.Client.DecodeNotificationV2(item.SignedPayload)
+		if err != nil {
+			report.Failures = append(report.Failures, &NotificationReplayError{Err: err})
+			continue
+		}
+		processed, err := r.Processed.IsProcessed(ctx, notification.NotificationUUID)
+		if err != nil {
+			return report, fmt.Errorf("appstore notification %s: %w", notification.NotificationUUID, err)
+		}
+		if processed {
+			report.Processed++
+			continue
+		}
+
+		missed := &MissedNotification{
+			Notification:           notification,
+			FirstSendAttemptResult: item.FirstSendAttemptResult,
+			SendAttempts:           item.SendAttempts,
+		}
+		report.Missed = append(report.Missed, missed)
+		for _, attempt := range item.SendAttempts {
+			report.SendAttemptResults[attempt.SendAttemptResult]++
+		}
+		if r.DryRun {
+			continue
+		}
+
+		if err := r.Handle(ctx, notification); err != nil {
+			report.Failures = append(report.Failures, &NotificationReplayError{NotificationUUID: notification.NotificationUUID, Err: err})
+			continue
+		}
+		missed.Replayed = true
+	}
+	return report, report.Err()
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReconcileClient serves its history, whose signed payloads are the notification UUIDs.
+type fakeReconcileClient struct {
+	history    []NotificationHistoryResponseItem
+	historyErr error
+	requested  NotificationHistoryRequest
+}
+
+func (f *fakeReconcileClient) NotificationHistoryIter(_ context.Context, body NotificationHistoryRequest, _ *PageOptions) iter.Seq2[NotificationHistoryResponseItem, error] {
+	f.requested = body
+	return func(yield func(NotificationHistoryResponseItem, error) bool) {
+		for _, item := range f.history {
+			if !yield(item, nil) {
+				return
+			}
+		}
+		if f.historyErr != nil {
+			yield(NotificationHistoryResponseItem{}, f.historyErr)
+		}
+	}
+}
+
+func (f *fakeReconcileClient) DecodeNotificationV2(signedPayload string) (*NotificationV2, error) {
+	if signedPayload == "" {
+		return nil, errors.New("empty jws")
+	}
+	return &NotificationV2{NotificationType: appstore.NotificationTypeV2DidRenew, NotificationUUID: signedPayload}, nil
+}
+
+type processedSet map[string]bool
+
+func (s processedSet) IsProcessed(_ context.Context, notificationUUID string) (bool, error) {
+	return s[notificationUUID], nil
+}
+
+func newReconcileHistory() []NotificationHistoryResponseItem {
+	return []NotificationHistoryResponseItem{
+		{SignedPayload: "processed", FirstSendAttemptResult: FirstSendAttemptResultSuccess, SendAttempts: []SendAttemptItem{{SendAttemptResult: FirstSendAttemptResultSuccess}}},
+		{SignedPayload: "timed-out", FirstSendAttemptResult: FirstSendAttemptResultTimedOut, SendAttempts: []SendAttemptItem{
+			{SendAttemptResult: FirstSendAttemptResultTimedOut},
+			{SendAttemptResult: FirstSendAttemptResultTimedOut},
+			{SendAttemptResult: FirstSendAttemptResultTlsIssue},
+		}},
+		{SignedPayload: ""},
+		{SignedPayload: "acknowledged", FirstSendAttemptResult: FirstSendAttemptResultSuccess, SendAttempts: []SendAttemptItem{{SendAttemptResult: FirstSendAttemptResultSuccess}}},
+	}
+}
+
+func TestNotificationReconciler_Reconcile(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeReconcileClient{history: newReconcileHistory()}
+	var replayed []string
+	r := &NotificationReconciler{
+		Client:    client,
+		Processed: processedSet{"processed": true},
+		Handle: func(_ context.Context, n *NotificationV2) error {
+			replayed = append(replayed, n.NotificationUUID)
+			if n.NotificationUUID == "acknowledged" {
+				return errors.New("database unavailable")
+			}
+			return nil
+		},
+		OnlyFailures: true,
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	report, err := r.Reconcile(context.Background(), start, start.Add(24*time.Hour))
+	require.Error(t, err)
+	assert.Equal(t, NotificationHistoryRequest{StartDate: start.UnixMilli(), EndDate: start.Add(24 * time.Hour).UnixMilli(), OnlyFailures: true}, client.requested)
+	assert.Equal(t, []string{"timed-out", "acknowledged"}, replayed)
+
+	assert.Equal(t, 4, report.Scanned)
+	assert.Equal(t, 1, report.Processed)
+	require.Len(t, report.Missed, 2)
+	assert.True(t, report.Missed[0].Replayed)
+	assert.Equal(t, FirstSendAttemptResultTimedOut, report.Missed[0].FirstSendAttemptResult)
+	assert.False(t, report.Missed[1].Replayed)
+	require.Len(t, report.Failures, 2)
+	assert.Empty(t, report.Failures[0].NotificationUUID)
+	assert.Equal(t, "acknowledged", report.Failures[1].NotificationUUID)
+	assert.Equal(t, err.Error(), report.Err().Error())
+}
+
+func TestNotificationReconciler_DryRun(t *testing.T) {
+	t.Parallel()
+
+	r := &NotificationReconciler{
+		Client:    &fakeReconcileClient{history: newReconcileHistory()},
+		Processed: processedSet{"processed": true},
+		DryRun:    true,
+	}
+	report, err := r.Reconcile(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	require.Error(t, err)
+	require.Len(t, report.Missed, 2)
+	for _, missed := range report.Missed {
+		assert.False(t, missed.Replayed)
+	}
+	assert.Equal(t, map[FirstSendAttemptResult]int{
+		FirstSendAttemptResultTimedOut: 2,
+		FirstSendAttemptResultTlsIssue: 1,
+		FirstSendAttemptResultSuccess:  1,
+	}, report.SendAttemptResults)
+	assert.Equal(t, 3, report.FailedSendAttempts())
+}
+
+func TestNotificationReconciler_Stopped(t *testing.T) {
+	t.Parallel()
+
+	t.Run("no handle func", func(t *testing.T) {
+		t.Parallel()
+		r := &NotificationReconciler{Client: &fakeReconcileClient{}, Processed: processedSet{}}
+		_, err := r.Reconcile(context.Background(), time.Now().Add(-time.Hour), time.Now())
+		assert.ErrorIs(t, err, ErrNoNotificationFunc)
+	})
+
+	t.Run("history error", func(t *testing.T) {
+		t.Parallel()
+		r := &NotificationReconciler{
+			Client:    &fakeReconcileClient{history: newReconcileHistory()[:2], historyErr: GeneralInternalError},
+			Processed: processedSet{},
+			Handle:    func(context.Context, *NotificationV2) error { return nil },
+		}
+		report, err := r.Reconcile(context.Background(), time.Now().Add(-time.Hour), time.Now())
+		assert.ErrorIs(t, err, GeneralInternalError)
+		assert.Equal(t, 2, report.Scanned)
+		assert.Len(t, report.Missed, 2)
+	})
+}
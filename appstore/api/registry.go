@@ -0,0 +1,139 @@
+package api
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+)
+
+// list of Registry errors
+var (
+	ErrRegistryNoApps      = errors.New("registry: at least one app is required")
+	ErrRegistryDuplicateID = errors.New("registry: duplicate bundle id")
+	ErrUnknownBundleID     = errors.New("registry: unknown bundle id")
+)
+
+// AppConfig configures one app of a Registry. The key fields override the shared ones of RegistryConfig.StoreConfig.
+type AppConfig struct {
+	BundleID   string        // Your app’s bundle ID
+	KeyContent []byte        // Optional .p8 certificate of the app
+	Signer     crypto.Signer // Optional signer of the app
+	KeyID      string        // Optional private key ID of the app
+}
+
+// RegistryConfig configures a Registry.
+type RegistryConfig struct {
+	// StoreConfig holds the settings shared by the apps, such as the issuer and the key.
+	// Its BundleID and Sandbox fields are ignored.
+	StoreConfig
+	Apps       []AppConfig  // The apps of the issuer
+	HTTPClient *http.Client // Shared by the apps. Default has a 30 seconds timeout.
+}
+
+// Registry holds the App Store Server API clients of several apps under one App Store Connect issuer.
+// Each app has an APIClient routing between production and sandbox. The apps share the HTTP client, the RateLimiter
+// and the certificate verification, so verified chains and OCSP responses are cached once for all of them.
+type Registry struct {
+	clients map[string]*APIClient
+}
+
+// NewRegistry creates a Registry with a client for each app of config.
+func NewRegistry(config *RegistryConfig) (*Registry, error) {
+	if len(config.Apps) == 0 {
+		return nil, ErrRegistryNoApps
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	cert := newStoreCert(&config.StoreConfig)
+	recorder := newTelemetry(&config.StoreConfig)
+
+	r := &Registry{clients: make(map[string]*APIClient, len(config.Apps))}
+	for _, app := range config.Apps {
+		if _, ok := r.clients[app.BundleID]; ok {
+			return nil, fmt.Errorf("%w: %q", ErrRegistryDuplicateID, app.BundleID)
+		}
+
+		appConf := config.StoreConfig
+		appConf.BundleID = app.BundleID
+		if app.KeyContent != nil || app.Signer != nil {
+			appConf.KeyContent = app.KeyContent
+			appConf.Signer = app.Signer
+		}
+		if app.KeyID != "" {
+			appConf.KeyID = app.KeyID
+		}
+		token := &Token{}
+		token.WithConfig(&appConf)
+
+		newClient := func(sandbox bool) *StoreClient {
+			return &StoreClient{
+				Token:     token,
+				httpCli:   httpClient,
+				cert:      cert,
+				host:      getHost(sandbox, appConf.HostDebug),
+				retry:     appConf.RetryPolicy,
+				limiter:   appConf.RateLimiter,
+				telemetry: recorder,
+			}
+		}
+		r.clients[app.BundleID] = &APIClient{
+			productionCli: newClient(false),
+			sandboxCli:    newClient(true),
+			environments:  newEnvironmentMemory(defaultEnvironmentMemorySize),
+		}
+	}
+	return r, nil
+}
+
+// BundleIDs returns the bundle IDs of the apps, sorted.
+func (r *Registry) BundleIDs() []string {
+	ids := make([]string, 0, len(r.clients))
+	for id := range r.clients {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Client returns the client of the app.
+func (r *Registry) Client(bundleID string) (*APIClient, error) {
+	client, ok := r.clients[bundleID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBundleID, bundleID)
+	}
+	return client, nil
+}
+
+// ClientForNotification returns the client of the app a decoded notification is about,
+// taken from the bundleId of its data, summary, app data or external purchase token.
+func (r *Registry) ClientForNotification(notification *appstore.SubscriptionNotificationV2DecodedPayload) (*APIClient, error) {
+	bundleID, _, _, _ := notification.App()
+	return r.Client(bundleID)
+}
+
+// ParseSignedNotification verifies and decodes a notification signed payload and returns it with the client of its app.
+func (r *Registry) ParseSignedNotification(signedPayload string) (*APIClient, *appstore.SubscriptionNotificationV2DecodedPayload, error) {
+	// The verification doesn't depend on the app, every client shares it.
+	var first *APIClient
+	for _, client := range r.clients {
+		first = client
+		break
+	}
+	notification, err := first.ParseSignedNotification(signedPayload)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := r.ClientForNotification(notification)
+	if err != nil {
+		return nil, notification, err
+	}
+	return client, notification, nil
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+	registry, err := NewRegistry(&RegistryConfig{
+		StoreConfig: StoreConfig{
+			KeyContent: newTestKeyContent(t),
+			KeyID:      "SHAREDKEY",
+			Issuer:     "test-issuer",
+		},
+		Apps: []AppConfig{
+			{BundleID: "com.example.one"},
+			{BundleID: "com.example.two", KeyContent: newTestKeyContent(t), KeyID: "TWOKEY"},
+		},
+	})
+	require.NoError(t, err)
+	return registry
+}
+
+func TestNewRegistry(t *testing.T) {
+	t.Parallel()
+	_, err := NewRegistry(&RegistryConfig{})
+	assert.ErrorIs(t, err, ErrRegistryNoApps)
+
+	_, err = NewRegistry(&RegistryConfig{Apps: []AppConfig{{BundleID: "com.example.one"}, {BundleID: "com.example.one"}}})
+	assert.ErrorIs(t, err, ErrRegistryDuplicateID)
+}
+
+func TestRegistry_Client(t *testing.T) {
+	t.Parallel()
+	registry := newTestRegistry(t)
+	assert.Equal(t, []string{"com.example.one", "com.example.two"}, registry.BundleIDs())
+
+	one, err := registry.Client("com.example.one")
+	require.NoError(t, err)
+	two, err := registry.Client("com.example.two")
+	require.NoError(t, err)
+	_, err = registry.Client("com.example.unknown")
+	assert.ErrorIs(t, err, ErrUnknownBundleID)
+
+	assert.Equal(t, "com.example.one", one.Production().Token.BundleID)
+	assert.Equal(t, "SHAREDKEY", one.Production().Token.KeyID)
+	assert.Equal(t, "com.example.two", two.Production().Token.BundleID)
+	assert.Equal(t, "TWOKEY", two.Production().Token.KeyID)
+	assert.Equal(t, HostProduction, one.Production().host)
+	assert.Equal(t, HostSandBox, one.Sandbox().host)
+
+	assert.Same(t, one.Production().Token, one.Sandbox().Token, "environments of an app share the token")
+	assert.Same(t, one.Production().httpCli, two.Sandbox().httpCli, "apps share the http client")
+	assert.Same(t, one.Production().cert, two.Sandbox().cert, "apps share the certificate verification")
+
+	httpClient := &http.Client{}
+	registry, err = NewRegistry(&RegistryConfig{Apps: []AppConfig{{BundleID: "com.example.one"}}, HTTPClient: httpClient})
+	require.NoError(t, err)
+	one, _ = registry.Client("com.example.one")
+	assert.Same(t, httpClient, one.Production().httpCli)
+}
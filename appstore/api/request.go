@@ -0,0 +1,174 @@
+package api
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SortOrder https://developer.apple.com/documentation/appstoreserverapi/sort
+type SortOrder string
+
+const (
+	SortAscending  SortOrder = "ASCENDING"
+	SortDescending SortOrder = "DESCENDING"
+)
+
+// ProductType https://developer.apple.com/documentation/appstoreserverapi/producttype
+type ProductType string
+
+const (
+	ProductTypeAutoRenewable ProductType = "AUTO_RENEWABLE"
+	ProductTypeNonRenewable  ProductType = "NON_RENEWABLE"
+	ProductTypeConsumable    ProductType = "CONSUMABLE"
+	ProductTypeNonConsumable ProductType = "NON_CONSUMABLE"
+)
+
+// InAppOwnershipType https://developer.apple.com/documentation/appstoreserverapi/inappownershiptype
+type InAppOwnershipType string
+
+const (
+	InAppOwnershipTypeFamilyShared InAppOwnershipType = "FAMILY_SHARED"
+	InAppOwnershipTypePurchased    InAppOwnershipType = "PURCHASED"
+)
+
+// TransactionHistoryRequest is the typed query of https://developer.apple.com/documentation/appstoreserverapi/get-transaction-history
+// Zero values are left out of the query.
+type TransactionHistoryRequest struct {
+	StartDate                    time.Time          // Transactions purchased on or after this date
+	EndDate                      time.Time          // Transactions purchased before this date
+	ProductIds                   []string           // Filters by product identifiers
+	ProductTypes                 []ProductType      // Filters by product types
+	Sort                         SortOrder          // Order of the transactions by their modified date. Default is ASCENDING.
+	SubscriptionGroupIdentifiers []string           // Filters by subscription group identifiers
+	InAppOwnershipType           InAppOwnershipType // Filters by in-app ownership type
+	Revoked                      *bool              // Filters revoked or non-revoked transactions
+}
+
+// Validate checks the request on the client side, returning the Error the App Store Server API would respond with.
+func (r *TransactionHistoryRequest) Validate() error {
+	if !r.StartDate.IsZero() && !r.EndDate.IsZero() && !r.StartDate.Before(r.EndDate) {
+		return StartDateAfterEndDateError
+	}
+	for _, v := range r.ProductIds {
+		if v == "" {
+			return InvalidProductIdError
+		}
+	}
+	for _, v := range r.ProductTypes {
+		switch v {
+		case ProductTypeAutoRenewable, ProductTypeNonRenewable, ProductTypeConsumable, ProductTypeNonConsumable:
+		default:
+			return InvalidProductTypeError
+		}
+	}
+	switch r.Sort {
+	case "", SortAscending, SortDescending:
+	default:
+		return InvalidSortError
+	}
+	for _, v := range r.SubscriptionGroupIdentifiers {
+		if v == "" {
+			return InvalidSubscriptionGroupIdentifierError
+		}
+	}
+	switch r.InAppOwnershipType {
+	case "", InAppOwnershipTypeFamilyShared, InAppOwnershipTypePurchased:
+	default:
+		return InvalidInAppOwnershipTypeError
+	}
+	return nil
+}
+
+// Values validates the request and encodes it as the query of GetTransactionHistory or TransactionHistoryIter.
+func (r *TransactionHistoryRequest) Values() (*url.Values, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := &url.Values{}
+	if !r.StartDate.IsZero() {
+		query.Set("startDate", strconv.FormatInt(r.StartDate.UnixMilli(), 10))
+	}
+	if !r.EndDate.IsZero() {
+		query.Set("endDate", strconv.FormatInt(r.EndDate.UnixMilli(), 10))
+	}
+	for _, v := range r.ProductIds {
+		query.Add("productId", v)
+	}
+	for _, v := range r.ProductTypes {
+		query.Add("productType", string(v))
+	}
+	if r.Sort != "" {
+		query.Set("sort", string(r.Sort))
+	}
+	for _, v := range r.SubscriptionGroupIdentifiers {
+		query.Add("subscriptionGroupIdentifier", v)
+	}
+	if r.InAppOwnershipType != "" {
+		query.Set("inAppOwnershipType", string(r.InAppOwnershipType))
+	}
+	if r.Revoked != nil {
+		query.Set("revoked", strconv.FormatBool(*r.Revoked))
+	}
+	return query, nil
+}
+
+// SubscriptionStatusesRequest is the typed query of https://developer.apple.com/documentation/appstoreserverapi/get_all_subscription_statuses
+type SubscriptionStatusesRequest struct {
+	Status []AutoRenewSubscriptionStatus // Filters by subscription statuses
+}
+
+// Validate checks the request on the client side, returning the Error the App Store Server API would respond with.
+func (r *SubscriptionStatusesRequest) Validate() error {
+	for _, v := range r.Status {
+		if v < SubscriptionActive || v > SubscriptionRevoked {
+			return InvalidStatusError
+		}
+	}
+	return nil
+}
+
+// Values validates the request and encodes it as the query of GetALLSubscriptionStatuses.
+func (r *SubscriptionStatusesRequest) Values() (*url.Values, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := &url.Values{}
+	for _, v := range r.Status {
+		query.Add("status", strconv.Itoa(int(v)))
+	}
+	return query, nil
+}
+
+// maxConsumptionPercentage is the whole In-App Purchase consumed, ConsumptionRequest.ConsumptionPercentage is in milliunits.
+const maxConsumptionPercentage = 100000
+
+// Errors of the ConsumptionRequest fields Apple has no error code for.
+var (
+	ErrInvalidConsumptionPercentage = errors.New("appstore api: consumption percentage must be between 0 and 100000")
+	ErrInvalidRefundPreference      = errors.New("appstore api: invalid refund preference")
+)
+
+// Validate checks the request on the client side, returning the Error the App Store Server API would respond with.
+func (r *ConsumptionRequest) Validate() error {
+	if !r.CustomerConsented {
+		return InvalidCustomerConsentedError
+	}
+	switch r.DeliveryStatus {
+	case DELIVERED, UNDELIVERED_QUALITY_ISSUE, UNDELIVERED_WRONG_ITEM, UNDELIVERED_SERVER_OUTAGE, UNDELIVERED_OTHER:
+	default:
+		return InvalidDeliveryStatusError
+	}
+	if p := r.ConsumptionPercentage; p != nil && (*p < 0 || *p > maxConsumptionPercentage) {
+		return ErrInvalidConsumptionPercentage
+	}
+	switch r.RefundPreference {
+	case "", DECLINE, GRANT_FULL, GRANT_PRORATED:
+	default:
+		return ErrInvalidRefundPreference
+	}
+	return nil
+}
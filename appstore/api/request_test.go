@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionHistoryRequest_Values(t *testing.T) {
+	t.Parallel()
+	revoked := false
+	req := TransactionHistoryRequest{
+		StartDate:                    time.UnixMilli(1698148900000),
+		EndDate:                      time.UnixMilli(1698148950000),
+		ProductIds:                   []string{"com.example.1", "com.example.2"},
+		ProductTypes:                 []ProductType{ProductTypeAutoRenewable, ProductTypeNonConsumable},
+		Sort:                         SortDescending,
+		SubscriptionGroupIdentifiers: []string{"sub_group_id"},
+		InAppOwnershipType:           InAppOwnershipTypePurchased,
+		Revoked:                      &revoked,
+	}
+
+	query, err := req.Values()
+	assert.NoError(t, err)
+	assert.Equal(t, "1698148900000", query.Get("startDate"))
+	assert.Equal(t, "1698148950000", query.Get("endDate"))
+	assert.Equal(t, []string{"com.example.1", "com.example.2"}, (*query)["productId"])
+	assert.Equal(t, []string{"AUTO_RENEWABLE", "NON_CONSUMABLE"}, (*query)["productType"])
+	assert.Equal(t, "DESCENDING", query.Get("sort"))
+	assert.Equal(t, "sub_group_id", query.Get("subscriptionGroupIdentifier"))
+	assert.Equal(t, "PURCHASED", query.Get("inAppOwnershipType"))
+	assert.Equal(t, "false", query.Get("revoked"))
+
+	empty, err := (&TransactionHistoryRequest{}).Values()
+	assert.NoError(t, err)
+	assert.Empty(t, *empty)
+}
+
+func TestTransactionHistoryRequest_Validate(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	tests := []struct {
+		Request  TransactionHistoryRequest
+		Expected error
+	}{
+		{Request: TransactionHistoryRequest{Sort: "ASC"}, Expected: InvalidSortError},
+		{Request: TransactionHistoryRequest{ProductTypes: []ProductType{"AUTO_RENEWABLE_SUBSCRIPTION"}}, Expected: InvalidProductTypeError},
+		{Request: TransactionHistoryRequest{InAppOwnershipType: "SHARED"}, Expected: InvalidInAppOwnershipTypeError},
+		{Request: TransactionHistoryRequest{StartDate: now, EndDate: now}, Expected: StartDateAfterEndDateError},
+		{Request: TransactionHistoryRequest{ProductIds: []string{""}}, Expected: InvalidProductIdError},
+		{Request: TransactionHistoryRequest{SubscriptionGroupIdentifiers: []string{""}}, Expected: InvalidSubscriptionGroupIdentifierError},
+		{Request: TransactionHistoryRequest{StartDate: now, EndDate: now.Add(time.Hour), Sort: SortAscending}, Expected: nil},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.Expected, test.Request.Validate())
+	}
+}
+
+func TestSubscriptionStatusesRequest_Values(t *testing.T) {
+	t.Parallel()
+	query, err := (&SubscriptionStatusesRequest{Status: []AutoRenewSubscriptionStatus{SubscriptionActive, SubscriptionGracePeriod}}).Values()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "4"}, (*query)["status"])
+
+	_, err = (&SubscriptionStatusesRequest{Status: []AutoRenewSubscriptionStatus{6}}).Values()
+	assert.ErrorIs(t, err, InvalidStatusError)
+}
+
+func TestConsumptionRequest_Validate(t *testing.T) {
+	t.Parallel()
+	half, over := int32(50000), int32(100001)
+	tests := []struct {
+		Request  ConsumptionRequest
+		Expected error
+	}{
+		{Request: ConsumptionRequest{DeliveryStatus: DELIVERED}, Expected: InvalidCustomerConsentedError},
+		{Request: ConsumptionRequest{CustomerConsented: true, DeliveryStatus: "LOST"}, Expected: InvalidDeliveryStatusError},
+		{Request: ConsumptionRequest{CustomerConsented: true, DeliveryStatus: DELIVERED, ConsumptionPercentage: &over}, Expected: ErrInvalidConsumptionPercentage},
+		{Request: ConsumptionRequest{CustomerConsented: true, DeliveryStatus: DELIVERED, RefundPreference: "GRANT"}, Expected: ErrInvalidRefundPreference},
+		{Request: ConsumptionRequest{CustomerConsented: true, DeliveryStatus: UNDELIVERED_OTHER}, Expected: nil},
+		{Request: ConsumptionRequest{CustomerConsented: true, DeliveryStatus: DELIVERED, ConsumptionPercentage: &half, RefundPreference: GRANT_PRORATED}, Expected: nil},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.Expected, test.Request.Validate())
+	}
+}
+
+func TestStoreClient_GetTransactionHistoryWithRequest(t *testing.T) {
+	t.Parallel()
+	var calls atomic.Int32
+	client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		assert.Equal(t, "DESCENDING", r.URL.Query().Get("sort"))
+		_, _ = w.Write([]byte(`{"hasMore":false,"revision":"rev"}`))
+	}), nil)
+
+	_, err := client.GetTransactionHistoryWithRequest(context.Background(), "1000", TransactionHistoryRequest{Sort: "DESC"})
+	assert.ErrorIs(t, err, InvalidSortError)
+	assert.Equal(t, int32(0), calls.Load())
+
+	responses, err := client.GetTransactionHistoryWithRequest(context.Background(), "1000", TransactionHistoryRequest{Sort: SortDescending})
+	assert.NoError(t, err)
+	assert.Len(t, responses, 1)
+	assert.Equal(t, int32(1), calls.Load())
+}
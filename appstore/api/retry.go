@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 500 * time.Millisecond
+	defaultRetryMaxBackoff     = 10 * time.Second
+)
+
+// RetryPolicy configures how StoreClient.Do retries a request that failed with a retryable Error or HTTPError.
+// A request is retried when Error.Retryable or HTTPError.Retryable reports true, or when Apple returns RateLimitExceededError
+// together with a Retry-After header, in which case the client waits until the indicated time.
+type RetryPolicy struct {
+	MaxAttempts    int           // Maximum number of attempts including the first one. Default is 3.
+	InitialBackoff time.Duration // Backoff before the second attempt, doubled on every further attempt. Default is 500ms.
+	MaxBackoff     time.Duration // Upper bound of a single backoff. Default is 10s.
+	MaxElapsedTime time.Duration // Upper bound of the total time spent on a call including waits. Zero means no limit.
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return defaultRetryMaxAttempts
+	}
+	return p.MaxAttempts
+}
+
+// backoff returns the jittered wait before the given attempt, attempt starts from 1 for the first retry.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	initial := p.InitialBackoff
+	if initial <= 0 {
+		initial = defaultRetryInitialBackoff
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	d := initial
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+
+	// Equal jitter: keep half of the backoff and randomize the other half.
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// delay reports how long to wait before retrying after err, and whether err is retryable at all.
+func (p *RetryPolicy) delay(err error, attempt int, now time.Time) (time.Duration, bool) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return p.backoff(attempt), httpErr.Retryable()
+	}
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return 0, false
+	}
+	if apiErr.Retryable() {
+		return p.backoff(attempt), true
+	}
+	if errors.Is(apiErr, RateLimitExceededError) && apiErr.RetryAfter() > 0 {
+		return retryAfterDelay(apiErr.RetryAfter(), now), true
+	}
+	return 0, false
+}
+
+// retryAfterDelay converts the Retry-After header value into a wait duration.
+// Apple documents the value as the UNIX time in milliseconds when the request can be retried,
+// small values are treated as a number of seconds as described in RFC 9110.
+func retryAfterDelay(retryAfter int64, now time.Time) time.Duration {
+	if retryAfter > 1e12 {
+		d := time.UnixMilli(retryAfter).Sub(now)
+		if d < 0 {
+			return 0
+		}
+		return d
+	}
+	return time.Duration(retryAfter) * time.Second
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// transient reports whether err may succeed later: a retryable Error or HTTPError, or an exhausted rate limit.
+func transient(err error) bool {
+	if errors.Is(err, ErrRateLimited) || errors.Is(err, RateLimitExceededError) {
+		return true
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Retryable()
+	}
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Retryable()
+}
@@ -5,18 +5,25 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/ecdsa"
-	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/internal/telemetry"
 	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -43,13 +50,24 @@ const (
 )
 
 type StoreConfig struct {
-	KeyContent         []byte       // Loads a .p8 certificate
-	KeyID              string       // Your private key ID from App Store Connect (Ex: 2X9R4HXF34)
-	BundleID           string       // Your app’s bundle ID
-	Issuer             string       // Your issuer ID from the Keys page in App Store Connect (Ex: "57246542-96fe-1a63-e053-0824d011072a")
-	Sandbox            bool         // default is Production
-	TokenIssuedAtFunc  func() int64 // The token’s creation time func. Default is current timestamp.
-	TokenExpiredAtFunc func() int64 // The token’s expiration time func. Default is one hour later.
+	KeyContent         []byte        // Loads a .p8 certificate
+	Signer             crypto.Signer // Signs with an ECDSA P-256 key held elsewhere, such as in a KMS or HSM, instead of KeyContent
+	KeyID              string        // Your private key ID from App Store Connect (Ex: 2X9R4HXF34)
+	BundleID           string        // Your app’s bundle ID
+	Issuer             string        // Your issuer ID from the Keys page in App Store Connect (Ex: "57246542-96fe-1a63-e053-0824d011072a")
+	Sandbox            bool          // default is Production
+	TokenIssuedAtFunc  func() int64  // The token’s creation time func. Default is current timestamp.
+	TokenExpiredAtFunc func() int64  // The token’s expiration time func. Default is one hour later.
+	RetryPolicy        *RetryPolicy  // Retries requests failed with a retryable error. Default is no retry.
+	RateLimiter        *RateLimiter  // Budgets the requests per endpoint. Default is no limit.
+
+	TracerProvider trace.TracerProvider // Records a span of each request when set. Default is no tracing.
+	MeterProvider  metric.MeterProvider // Records the duration of each request when set. Default is no metrics.
+
+	RootCertificates    [][]byte                  // DER or PEM encoded trusted root certificates of signed data. Default is Apple Root CA - G3.
+	OCSP                *appstore.OCSPChecker     // Optional online revocation check of the x5c chain of signed data
+	VerificationTime    appstore.VerificationTime // The time the x5c chain of signed data is verified at. Default is the current time.
+	VerificationNowFunc func() time.Time          // The current time func used to verify the x5c chain. Default is time.Now.
 
 	// internal variables
 	HostDebug string // can be used to override the host for testing
@@ -69,34 +87,45 @@ type (
 
 	SubscriptionGetter interface {
 		GetALLSubscriptionStatuses(ctx context.Context, originalTransactionId string, query *url.Values) (rsp *StatusResponse, err error)
+		GetALLSubscriptionStatusesWithRequest(ctx context.Context, originalTransactionId string, req SubscriptionStatusesRequest) (rsp *StatusResponse, err error)
 		GetRefundHistory(ctx context.Context, originalTransactionId string) (responses []*RefundLookupResponse, err error)
 		GetSubscriptionRenewalDataStatus(ctx context.Context, productId, requestIdentifier string) (statusCode int, rsp *MassExtendRenewalDateStatusResponse, err error)
 		GetTransactionHistory(ctx context.Context, transactionId string, query *url.Values) (responses []*HistoryResponse, err error)
+		GetTransactionHistoryWithRequest(ctx context.Context, transactionId string, req TransactionHistoryRequest) (responses []*HistoryResponse, err error)
 		GetTransactionInfo(ctx context.Context, transactionId string) (rsp *TransactionInfoResponse, err error)
 		LookupOrderID(ctx context.Context, orderId string) (rsp *OrderLookupResponse, err error)
 		GetAppTransactionInfo(ctx context.Context, transactionId string) (rsp *AppTransactionInfoResponse, err error)
 		FinishTransaction(ctx context.Context, transactionId string) (statusCode int, err error)
+		TransactionHistoryIter(ctx context.Context, transactionId string, query *url.Values, opts *PageOptions) iter.Seq2[*JWSTransaction, error]
+		RefundHistoryIter(ctx context.Context, originalTransactionId string, opts *PageOptions) iter.Seq2[*JWSTransaction, error]
 	}
 
 	SubscriptionExtender interface {
 		ExtendSubscriptionRenewalDate(ctx context.Context, originalTransactionId string, body ExtendRenewalDateRequest) (statusCode int, err error)
+		ExtendSubscriptionRenewalDateWithResponse(ctx context.Context, originalTransactionId string, body ExtendRenewalDateRequest) (statusCode int, rsp *ExtendRenewalDateResponse, err error)
 		ExtendSubscriptionRenewalDateForAll(ctx context.Context, body MassExtendRenewalDateRequest) (statusCode int, err error)
 	}
 
 	TransactionParser interface {
 		ParseSignedTransactions(transactions []string) ([]*JWSTransaction, error)
+		ParseSignedTransactionsWithOptions(transactions []string, opts ParseTransactionsOptions) (*ParsedTransactions, error)
 		ParseJWSEncodeString(jwsEncode string) (interface{}, error)
 		ParseSignedTransaction(transaction string) (*JWSTransaction, error)
+		ParseSignedRenewalInfo(renewalInfo string) (*JWSRenewalInfoDecodedPayload, error)
+		ParseSignedAppTransaction(appTransaction string) (*JWSAppTransactionDecodedPayload, error)
+		ParseSignedNotification(signedPayload string) (*appstore.SubscriptionNotificationV2DecodedPayload, error)
+		DecodeNotificationV2(signedPayload string) (*NotificationV2, error)
 	}
 
 	NotificationGetter interface {
 		GetAllNotificationHistory(ctx context.Context, body NotificationHistoryRequest, duration time.Duration) (responses []NotificationHistoryResponseItem, err error)
 		GetNotificationHistory(ctx context.Context, body NotificationHistoryRequest, paginationToken string) (rsp *NotificationHistoryResponses, err error)
-		GetTestNotificationStatus(ctx context.Context, testNotificationToken string) (int, []byte, error)
+		GetTestNotificationStatus(ctx context.Context, testNotificationToken string) (statusCode int, rsp *CheckTestNotificationResponse, err error)
+		NotificationHistoryIter(ctx context.Context, body NotificationHistoryRequest, opts *PageOptions) iter.Seq2[NotificationHistoryResponseItem, error]
 	}
 
 	NotificationSender interface {
-		SendRequestTestNotification(ctx context.Context) (int, []byte, error)
+		SendRequestTestNotification(ctx context.Context) (statusCode int, rsp *SendTestNotificationResponse, err error)
 	}
 
 	ConsumptionSender interface {
@@ -110,10 +139,13 @@ type (
 )
 
 type StoreClient struct {
-	Token   *Token
-	httpCli *http.Client
-	cert    *Cert
-	host    string
+	Token     *Token
+	httpCli   *http.Client
+	cert      *Cert
+	host      string
+	retry     *RetryPolicy
+	limiter   *RateLimiter
+	telemetry *telemetry.Recorder
 }
 
 // NewStoreClient create a appstore server api client
@@ -123,11 +155,14 @@ func NewStoreClient(config *StoreConfig) *StoreClient {
 
 	client := &StoreClient{
 		Token: token,
-		cert:  &Cert{},
+		cert:  newStoreCert(config),
 		httpCli: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		host: getHost(config.Sandbox, config.HostDebug),
+		host:      getHost(config.Sandbox, config.HostDebug),
+		retry:     config.RetryPolicy,
+		limiter:   config.RateLimiter,
+		telemetry: newTelemetry(config),
 	}
 	return client
 }
@@ -138,14 +173,33 @@ func NewStoreClientWithHTTPClient(config *StoreConfig, httpClient *http.Client)
 	token.WithConfig(config)
 
 	client := &StoreClient{
-		Token:   token,
-		cert:    &Cert{},
-		httpCli: httpClient,
-		host:    getHost(config.Sandbox, config.HostDebug),
+		Token:     token,
+		cert:      newStoreCert(config),
+		httpCli:   httpClient,
+		host:      getHost(config.Sandbox, config.HostDebug),
+		retry:     config.RetryPolicy,
+		limiter:   config.RateLimiter,
+		telemetry: newTelemetry(config),
 	}
 	return client
 }
 
+func newTelemetry(config *StoreConfig) *telemetry.Recorder {
+	return telemetry.New(telemetryStore, config.TracerProvider, config.MeterProvider)
+}
+
+// newStoreCert creates the Cert of config, a root certificate that fails to parse fails the verification of signed data.
+func newStoreCert(config *StoreConfig) *Cert {
+	cert, err := newCert(config.RootCertificates)
+	if err != nil {
+		cert = &Cert{rootsErr: err}
+	}
+	cert.ocsp = config.OCSP
+	cert.verificationTime = config.VerificationTime
+	cert.now = config.VerificationNowFunc
+	return cert
+}
+
 func getHost(sandbox bool, debugHost string) string {
 	if debugHost != "" {
 		return debugHost
@@ -163,15 +217,11 @@ func (a *StoreClient) GetALLSubscriptionStatuses(ctx context.Context, originalTr
 	if query != nil {
 		URL = URL + "?" + query.Encode()
 	}
-	statusCode, body, err := a.Do(ctx, http.MethodGet, URL, nil)
+	_, body, err := a.Do(ctx, http.MethodGet, URL, nil)
 	if err != nil {
 		return
 	}
 
-	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("appstore api: %v return status code %v", URL, statusCode)
-	}
-
 	err = json.Unmarshal(body, &rsp)
 	if err != nil {
 		return nil, err
@@ -180,19 +230,24 @@ func (a *StoreClient) GetALLSubscriptionStatuses(ctx context.Context, originalTr
 	return
 }
 
+// GetALLSubscriptionStatusesWithRequest is GetALLSubscriptionStatuses with a typed query validated before the request is sent.
+func (a *StoreClient) GetALLSubscriptionStatusesWithRequest(ctx context.Context, originalTransactionId string, req SubscriptionStatusesRequest) (rsp *StatusResponse, err error) {
+	query, err := req.Values()
+	if err != nil {
+		return nil, err
+	}
+	return a.GetALLSubscriptionStatuses(ctx, originalTransactionId, query)
+}
+
 // LookupOrderID https://developer.apple.com/documentation/appstoreserverapi/look_up_order_id
 func (a *StoreClient) LookupOrderID(ctx context.Context, orderId string) (rsp *OrderLookupResponse, err error) {
 	URL := a.host + PathLookUp
 	URL = strings.Replace(URL, "{orderId}", orderId, -1)
-	statusCode, body, err := a.Do(ctx, http.MethodGet, URL, nil)
+	_, body, err := a.Do(ctx, http.MethodGet, URL, nil)
 	if err != nil {
 		return
 	}
 
-	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("appstore api: %v return status code %v", URL, statusCode)
-	}
-
 	err = json.Unmarshal(body, &rsp)
 	if err != nil {
 		return nil, err
@@ -203,31 +258,17 @@ func (a *StoreClient) LookupOrderID(ctx context.Context, orderId string) (rsp *O
 
 // GetTransactionHistory https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history
 func (a *StoreClient) GetTransactionHistory(ctx context.Context, transactionId string, query *url.Values) (responses []*HistoryResponse, err error) {
-	URL := a.host + PathTransactionHistory
-	URL = strings.Replace(URL, "{transactionId}", transactionId, -1)
-
 	if query == nil {
 		query = &url.Values{}
 	}
 
 	for {
-		rsp := HistoryResponse{}
-
-		statusCode, body, errOmit := a.Do(ctx, http.MethodGet, URL+"?"+query.Encode(), nil)
-		if errOmit != nil {
-			return nil, errOmit
-		}
-
-		if statusCode != http.StatusOK {
-			return nil, fmt.Errorf("appstore api: %v return status code %v", URL, statusCode)
-		}
-
-		err = json.Unmarshal(body, &rsp)
+		rsp, err := a.getTransactionHistoryPage(ctx, transactionId, *query)
 		if err != nil {
 			return nil, err
 		}
 
-		responses = append(responses, &rsp)
+		responses = append(responses, rsp)
 		if !rsp.HasMore {
 			break
 		}
@@ -236,26 +277,49 @@ func (a *StoreClient) GetTransactionHistory(ctx context.Context, transactionId s
 			query.Set("revision", rsp.Revision)
 		}
 
-		time.Sleep(10 * time.Millisecond)
+		if err = sleepContext(ctx, defaultPageDelay); err != nil {
+			return nil, err
+		}
 	}
 
 	return
 }
 
+// GetTransactionHistoryWithRequest is GetTransactionHistory with a typed query validated before the request is sent.
+func (a *StoreClient) GetTransactionHistoryWithRequest(ctx context.Context, transactionId string, req TransactionHistoryRequest) (responses []*HistoryResponse, err error) {
+	query, err := req.Values()
+	if err != nil {
+		return nil, err
+	}
+	return a.GetTransactionHistory(ctx, transactionId, query)
+}
+
+func (a *StoreClient) getTransactionHistoryPage(ctx context.Context, transactionId string, query url.Values) (*HistoryResponse, error) {
+	URL := a.host + PathTransactionHistory
+	URL = strings.Replace(URL, "{transactionId}", transactionId, -1)
+
+	_, body, err := a.Do(ctx, http.MethodGet, URL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := &HistoryResponse{}
+	if err = json.Unmarshal(body, rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
 // GetTransactionInfo https://developer.apple.com/documentation/appstoreserverapi/get_transaction_info
 func (a *StoreClient) GetTransactionInfo(ctx context.Context, transactionId string) (rsp *TransactionInfoResponse, err error) {
 	URL := a.host + PathTransactionInfo
 	URL = strings.Replace(URL, "{transactionId}", transactionId, -1)
 
-	statusCode, body, err := a.Do(ctx, http.MethodGet, URL, nil)
+	_, body, err := a.Do(ctx, http.MethodGet, URL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("appstore api: %v return status code %v", URL, statusCode)
-	}
-
 	err = json.Unmarshal(body, &rsp)
 	if err != nil {
 		return nil, err
@@ -269,15 +333,11 @@ func (a *StoreClient) GetAppTransactionInfo(ctx context.Context, transactionId s
 	URL := a.host + PathGetAppTransactionInfo
 	URL = strings.Replace(URL, "{transactionId}", transactionId, -1)
 
-	statusCode, body, err := a.Do(ctx, http.MethodGet, URL, nil)
+	_, body, err := a.Do(ctx, http.MethodGet, URL, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("appstore api: %v return status code %v", URL, statusCode)
-	}
-
 	err = json.Unmarshal(body, &rsp)
 	if err != nil {
 		return nil, err
@@ -288,43 +348,50 @@ func (a *StoreClient) GetAppTransactionInfo(ctx context.Context, transactionId s
 
 // GetRefundHistory https://developer.apple.com/documentation/appstoreserverapi/get_refund_history
 func (a *StoreClient) GetRefundHistory(ctx context.Context, originalTransactionId string) (responses []*RefundLookupResponse, err error) {
-	baseURL := a.host + PathRefundHistory
-	baseURL = strings.Replace(baseURL, "{originalTransactionId}", originalTransactionId, -1)
-
-	URL := baseURL
+	revision := ""
 	for {
-		rsp := RefundLookupResponse{}
-
-		statusCode, body, errOmit := a.Do(ctx, http.MethodGet, URL, nil)
-		if errOmit != nil {
-			return nil, errOmit
-		}
-
-		if statusCode != http.StatusOK {
-			return nil, fmt.Errorf("appstore api: %v return status code %v", URL, statusCode)
-		}
-
-		err = json.Unmarshal(body, &rsp)
+		rsp, err := a.getRefundHistoryPage(ctx, originalTransactionId, revision)
 		if err != nil {
 			return nil, err
 		}
 
-		responses = append(responses, &rsp)
+		responses = append(responses, rsp)
 		if !rsp.HasMore {
 			break
 		}
 
-		data := url.Values{}
 		if rsp.HasMore && rsp.Revision != "" {
-			data.Set("revision", rsp.Revision)
-			URL = baseURL + "?" + data.Encode()
+			revision = rsp.Revision
 		}
 
-		time.Sleep(10 * time.Millisecond)
+		if err = sleepContext(ctx, defaultPageDelay); err != nil {
+			return nil, err
+		}
 	}
 	return
 }
 
+func (a *StoreClient) getRefundHistoryPage(ctx context.Context, originalTransactionId string, revision string) (*RefundLookupResponse, error) {
+	URL := a.host + PathRefundHistory
+	URL = strings.Replace(URL, "{originalTransactionId}", originalTransactionId, -1)
+	if revision != "" {
+		data := url.Values{}
+		data.Set("revision", revision)
+		URL += "?" + data.Encode()
+	}
+
+	_, body, err := a.Do(ctx, http.MethodGet, URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := &RefundLookupResponse{}
+	if err = json.Unmarshal(body, rsp); err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
 // https://developer.apple.com/documentation/appstoreserverapi/send-consumption-information-v1
 func (a *StoreClient) SendConsumptionInfo(ctx context.Context, originalTransactionId string, body ConsumptionRequestBody) (statusCode int, err error) {
 	URL := a.host + PathConsumptionInfo
@@ -363,20 +430,36 @@ func (a *StoreClient) SendConsumptionInfoV2(ctx context.Context, transactionId s
 
 // ExtendSubscriptionRenewalDate https://developer.apple.com/documentation/appstoreserverapi/extend_a_subscription_renewal_date
 func (a *StoreClient) ExtendSubscriptionRenewalDate(ctx context.Context, originalTransactionId string, body ExtendRenewalDateRequest) (statusCode int, err error) {
+	statusCode, _, err = a.ExtendSubscriptionRenewalDateWithResponse(ctx, originalTransactionId, body)
+	return statusCode, err
+}
+
+// ExtendSubscriptionRenewalDateWithResponse is like ExtendSubscriptionRenewalDate, it also returns the new effective date.
+// rsp is nil when the response has no body.
+// https://developer.apple.com/documentation/appstoreserverapi/extend_a_subscription_renewal_date
+func (a *StoreClient) ExtendSubscriptionRenewalDateWithResponse(ctx context.Context, originalTransactionId string, body ExtendRenewalDateRequest) (statusCode int, rsp *ExtendRenewalDateResponse, err error) {
 	URL := a.host + PathExtendSubscriptionRenewalDate
 	URL = strings.Replace(URL, "{originalTransactionId}", originalTransactionId, -1)
 
 	bodyBuf := new(bytes.Buffer)
 	err = json.NewEncoder(bodyBuf).Encode(body)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
-	statusCode, _, err = a.Do(ctx, http.MethodPut, URL, bodyBuf)
+	statusCode, respBody, err := a.Do(ctx, http.MethodPut, URL, bodyBuf)
 	if err != nil {
-		return statusCode, err
+		return statusCode, nil, err
 	}
-	return statusCode, nil
+	if len(respBody) == 0 {
+		return statusCode, nil, nil
+	}
+
+	err = json.Unmarshal(respBody, &rsp)
+	if err != nil {
+		return statusCode, nil, err
+	}
+	return statusCode, rsp, nil
 }
 
 // ExtendSubscriptionRenewalDateForAll https://developer.apple.com/documentation/appstoreserverapi/extend_subscription_renewal_dates_for_all_active_subscribers
@@ -407,10 +490,6 @@ func (a *StoreClient) GetSubscriptionRenewalDataStatus(ctx context.Context, prod
 		return statusCode, nil, err
 	}
 
-	if statusCode != http.StatusOK {
-		return statusCode, nil, fmt.Errorf("appstore api: %v return status code %v", URL, statusCode)
-	}
-
 	err = json.Unmarshal(body, &rsp)
 	if err != nil {
 		return statusCode, nil, err
@@ -436,7 +515,9 @@ func (a *StoreClient) GetAllNotificationHistory(ctx context.Context, body Notifi
 			break
 		}
 
-		time.Sleep(duration)
+		if err = sleepContext(ctx, duration); err != nil {
+			return nil, err
+		}
 	}
 
 	return responses, nil
@@ -458,15 +539,11 @@ func (a *StoreClient) GetNotificationHistory(ctx context.Context, body Notificat
 		return nil, err
 	}
 
-	statusCode, rspBody, err := a.Do(ctx, http.MethodPost, URL, bodyBuf)
+	_, rspBody, err := a.Do(ctx, http.MethodPost, URL, bodyBuf)
 	if err != nil {
 		return nil, err
 	}
 
-	if statusCode != http.StatusOK {
-		return nil, fmt.Errorf("appstore api: %v return status code %v", URL, statusCode)
-	}
-
 	if err = json.Unmarshal(rspBody, &rsp); err != nil {
 		return nil, err
 	}
@@ -475,18 +552,36 @@ func (a *StoreClient) GetNotificationHistory(ctx context.Context, body Notificat
 }
 
 // SendRequestTestNotification https://developer.apple.com/documentation/appstoreserverapi/request_a_test_notification
-func (a *StoreClient) SendRequestTestNotification(ctx context.Context) (int, []byte, error) {
+func (a *StoreClient) SendRequestTestNotification(ctx context.Context) (statusCode int, rsp *SendTestNotificationResponse, err error) {
 	URL := a.host + PathRequestTestNotification
 
-	return a.Do(ctx, http.MethodPost, URL, nil)
+	statusCode, body, err := a.Do(ctx, http.MethodPost, URL, nil)
+	if err != nil {
+		return statusCode, nil, err
+	}
+
+	err = json.Unmarshal(body, &rsp)
+	if err != nil {
+		return statusCode, nil, err
+	}
+	return statusCode, rsp, nil
 }
 
 // GetTestNotificationStatus https://developer.apple.com/documentation/appstoreserverapi/get_test_notification_status
-func (a *StoreClient) GetTestNotificationStatus(ctx context.Context, testNotificationToken string) (int, []byte, error) {
+func (a *StoreClient) GetTestNotificationStatus(ctx context.Context, testNotificationToken string) (statusCode int, rsp *CheckTestNotificationResponse, err error) {
 	URL := a.host + PathGetTestNotificationStatus
 	URL = strings.Replace(URL, "{testNotificationToken}", testNotificationToken, -1)
 
-	return a.Do(ctx, http.MethodGet, URL, nil)
+	statusCode, body, err := a.Do(ctx, http.MethodGet, URL, nil)
+	if err != nil {
+		return statusCode, nil, err
+	}
+
+	err = json.Unmarshal(body, &rsp)
+	if err != nil {
+		return statusCode, nil, err
+	}
+	return statusCode, rsp, nil
 }
 
 // SetAppAccountToken https://developer.apple.com/documentation/appstoreserverapi/set-app-account-token
@@ -518,71 +613,58 @@ func (a *StoreClient) FinishTransaction(ctx context.Context, transactionId strin
 
 // ParseSignedTransactions parse the jws singed transactions
 // Per doc: https://datatracker.ietf.org/doc/html/rfc7515#section-4.1.6
+// The verified transactions are returned together with the failures joined in the error,
+// use ParseSignedTransactionsWithOptions to get the index of each failure or to fail on the first one.
 func (a *StoreClient) ParseSignedTransactions(transactions []string) ([]*JWSTransaction, error) {
-	result := make([]*JWSTransaction, 0)
-	for _, v := range transactions {
-		trans, err := a.ParseSignedTransaction(v)
-		if err == nil && trans != nil {
-			result = append(result, trans)
-		}
-	}
-
-	return result, nil
+	result, err := a.ParseSignedTransactionsWithOptions(transactions, ParseTransactionsOptions{})
+	return result.Transactions, err
 }
 
 // ParseJWSEncodeString parse the jws encode string, such as JWSTransaction and JWSRenewalInfoDecodedPayload
+// The payload type is detected from its top level fields, prefer the typed ParseSigned methods when the type is known.
 func (a *StoreClient) ParseJWSEncodeString(jwsEncode string) (interface{}, error) {
 	// Split the JWS format string into its three parts
 	parts := strings.Split(jwsEncode, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("appstore jws encode string must have 3 parts, got %d", len(parts))
+	}
 
 	// Decode the payload part of the JWS format string
 	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
 	if err != nil {
 		return nil, err
 	}
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(payload, &fields); err != nil {
+		return nil, err
+	}
+	has := func(name string) bool {
+		_, ok := fields[name]
+		return ok
+	}
 
-	// Determine which struct to use based on the payload contents
-	if strings.Contains(string(payload), "transactionId") {
-		transaction := &JWSTransaction{}
-		err = a.parseJWS(jwsEncode, transaction)
-		return transaction, err
-	} else if strings.Contains(string(payload), "renewalDate") {
-		renewalInfo := &JWSRenewalInfoDecodedPayload{}
-		err = a.parseJWS(jwsEncode, renewalInfo)
-		return renewalInfo, err
+	// Determine which struct to use based on the payload fields
+	switch {
+	case has("notificationType"):
+		return a.ParseSignedNotification(jwsEncode)
+	case has("autoRenewStatus") || has("renewalDate"):
+		return a.ParseSignedRenewalInfo(jwsEncode)
+	case has("transactionId"):
+		return a.ParseSignedTransaction(jwsEncode)
+	case has("originalApplicationVersion"):
+		return a.ParseSignedAppTransaction(jwsEncode)
 	}
 
 	return nil, nil
 }
 
 func (a *StoreClient) parseJWS(jwsEncode string, claims jwt.Claims) error {
-	rootCertBytes, err := a.cert.extractCertByIndex(jwsEncode, 2)
-	if err != nil {
-		return err
-	}
-	rootCert, err := x509.ParseCertificate(rootCertBytes)
-	if err != nil {
-		return fmt.Errorf("appstore failed to parse root certificate")
-	}
-
-	intermediaCertBytes, err := a.cert.extractCertByIndex(jwsEncode, 1)
-	if err != nil {
-		return err
-	}
-	intermediaCert, err := x509.ParseCertificate(intermediaCertBytes)
-	if err != nil {
-		return fmt.Errorf("appstore failed to parse intermediate certificate")
-	}
-
-	leafCertBytes, err := a.cert.extractCertByIndex(jwsEncode, 0)
+	header, err := parseJWSHeader(jwsEncode)
 	if err != nil {
 		return err
 	}
-	leafCert, err := x509.ParseCertificate(leafCertBytes)
+	leafCert, err := a.cert.verifyX5C(header.X5C, a.cert.verificationTimeOf(jwsEncode), nil)
 	if err != nil {
-		return fmt.Errorf("appstore failed to parse leaf certificate")
-	}
-	if err = a.cert.verifyCert(rootCert, intermediaCert, leafCert); err != nil {
 		return err
 	}
 
@@ -609,13 +691,112 @@ func (a *StoreClient) ParseSignedTransaction(transaction string) (*JWSTransactio
 	return tran, nil
 }
 
+// ParseSignedRenewalInfo parse one jws signed renewal info, such as LastTransactionsItem.SignedRenewalInfo
+func (a *StoreClient) ParseSignedRenewalInfo(renewalInfo string) (*JWSRenewalInfoDecodedPayload, error) {
+	info := &JWSRenewalInfoDecodedPayload{}
+
+	err := a.parseJWS(renewalInfo, info)
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// ParseSignedAppTransaction parse one jws signed app transaction for API like GetAppTransactionInfo
+func (a *StoreClient) ParseSignedAppTransaction(appTransaction string) (*JWSAppTransactionDecodedPayload, error) {
+	appTran := &JWSAppTransactionDecodedPayload{}
+
+	err := a.parseJWS(appTransaction, appTran)
+	if err != nil {
+		return nil, err
+	}
+
+	return appTran, nil
+}
+
+// ParseSignedNotification parse the jws signed payload of an App Store Server Notification V2
+func (a *StoreClient) ParseSignedNotification(signedPayload string) (*appstore.SubscriptionNotificationV2DecodedPayload, error) {
+	notification := &appstore.SubscriptionNotificationV2DecodedPayload{}
+
+	err := a.parseJWS(signedPayload, notification)
+	if err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
 // Do Per doc: https://developer.apple.com/documentation/appstoreserverapi#topics
+// The body is read once before the first attempt, so that it can be replayed when the request is retried per StoreConfig.RetryPolicy.
+// A status other than 2xx is returned as an Error when the body carries an errorCode, else as an HTTPError.
 func (a *StoreClient) Do(ctx context.Context, method string, url string, body io.Reader) (int, []byte, error) {
+	var payload []byte
+	if body != nil {
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return 0, nil, fmt.Errorf("appstore read request body err %w", err)
+		}
+		payload = b
+	}
+
+	if a.retry == nil {
+		return a.do(ctx, method, url, payload)
+	}
+
+	start := time.Now()
+	maxAttempts := a.retry.maxAttempts()
+	for attempt := 1; ; attempt++ {
+		statusCode, rspBody, err := a.do(ctx, method, url, payload)
+		if err == nil || attempt >= maxAttempts || ctx.Err() != nil {
+			return statusCode, rspBody, err
+		}
+
+		now := time.Now()
+		wait, ok := a.retry.delay(err, attempt, now)
+		if !ok {
+			return statusCode, rspBody, err
+		}
+		if a.retry.MaxElapsedTime > 0 && now.Add(wait).Sub(start) > a.retry.MaxElapsedTime {
+			return statusCode, rspBody, err
+		}
+		if sleepErr := sleepContext(ctx, wait); sleepErr != nil {
+			return statusCode, rspBody, err
+		}
+	}
+}
+
+// do sends a single request to the App Store Server API, within the budget of its endpoint if rate limited.
+func (a *StoreClient) do(ctx context.Context, method string, url string, payload []byte) (statusCode int, rspBody []byte, err error) {
+	if a.telemetry != nil {
+		operation, template := operationOf(url)
+		var call *telemetry.Call
+		ctx, call = a.telemetry.Start(ctx, operation, method, template)
+		defer func() {
+			call.SetStatus(statusCode)
+			var apiErr *Error
+			if errors.As(err, &apiErr) {
+				call.SetErrorCode(strconv.Itoa(apiErr.ErrorCode()))
+			}
+			call.End(err)
+		}()
+	}
+
+	if a.limiter != nil {
+		if err := a.limiter.Wait(ctx, url); err != nil {
+			return 0, nil, err
+		}
+	}
+
 	authToken, err := a.Token.GenerateIfExpired()
 	if err != nil {
 		return 0, nil, fmt.Errorf("appstore generate token err %w", err)
 	}
 
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return 0, nil, fmt.Errorf("appstore new http request err %w", err)
@@ -637,11 +818,15 @@ func (a *StoreClient) Do(ctx context.Context, method string, url string, body io
 		return resp.StatusCode, nil, fmt.Errorf("appstore read http body err %w", err)
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		if rErr, ok := newAppStoreAPIError(bodyBytes, resp.Header); ok {
+			if a.limiter != nil && errors.Is(rErr, RateLimitExceededError) {
+				a.limiter.exhaust(url)
+			}
 			return resp.StatusCode, bodyBytes, rErr
 		}
+		return resp.StatusCode, bodyBytes, newHTTPError(method, url, resp.StatusCode, bodyBytes)
 	}
 
-	return resp.StatusCode, bodyBytes, err
+	return resp.StatusCode, bodyBytes, nil
 }
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestKeyContent returns a freshly generated .p8 style PEM for signing API tokens.
+func newTestKeyContent(t testing.TB) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// newTestStoreClient starts a server with handler and returns a StoreClient pointing at it.
+func newTestStoreClient(t testing.TB, handler http.Handler, configure func(*StoreConfig)) *StoreClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	config := &StoreConfig{
+		KeyContent: newTestKeyContent(t),
+		KeyID:      "TESTKEYID",
+		BundleID:   "com.example.app",
+		Issuer:     "test-issuer",
+		HostDebug:  srv.URL,
+	}
+	if configure != nil {
+		configure(config)
+	}
+	return NewStoreClientWithHTTPClient(config, srv.Client())
+}
+
+func writeAPIError(w http.ResponseWriter, status int, apiErr *Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = io.WriteString(w, `{"errorCode":`+strconv.Itoa(apiErr.ErrorCode())+`,"errorMessage":"`+apiErr.ErrorMessage()+`"}`)
+}
+
+func TestStoreClient_DoRetry(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should retry retryable errors and replay the body", func(t *testing.T) {
+		t.Parallel()
+		var calls atomic.Int32
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			assert.Equal(t, `{"extendByDays":1,"extendReasonCode":1,"requestIdentifier":"id"}`, strings.TrimSpace(string(body)))
+			if calls.Add(1) < 3 {
+				writeAPIError(w, http.StatusInternalServerError, GeneralInternalRetryableError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}), func(c *StoreConfig) {
+			c.RetryPolicy = &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+		})
+
+		statusCode, err := client.ExtendSubscriptionRenewalDate(context.Background(), "1000", ExtendRenewalDateRequest{
+			ExtendByDays:      1,
+			ExtendReasonCode:  CustomerSatisfaction,
+			RequestIdentifier: "id",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("should give up after max attempts", func(t *testing.T) {
+		t.Parallel()
+		var calls atomic.Int32
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			writeAPIError(w, http.StatusNotFound, AccountNotFoundRetryableError)
+		}), func(c *StoreConfig) {
+			c.RetryPolicy = &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+		})
+
+		_, err := client.GetTransactionInfo(context.Background(), "1000")
+		assert.ErrorIs(t, err, AccountNotFoundRetryableError)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("should not retry non retryable errors", func(t *testing.T) {
+		t.Parallel()
+		var calls atomic.Int32
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			writeAPIError(w, http.StatusNotFound, TransactionIdNotFoundError)
+		}), func(c *StoreConfig) {
+			c.RetryPolicy = &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}
+		})
+
+		_, err := client.GetTransactionInfo(context.Background(), "1000")
+		assert.ErrorIs(t, err, TransactionIdNotFoundError)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("should wait out Retry-After on rate limit", func(t *testing.T) {
+		t.Parallel()
+		var calls atomic.Int32
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", strconv.FormatInt(time.Now().Add(20*time.Millisecond).UnixMilli(), 10))
+				writeAPIError(w, http.StatusTooManyRequests, RateLimitExceededError)
+				return
+			}
+			_, _ = io.WriteString(w, `{"signedTransactionInfo":"info"}`)
+		}), func(c *StoreConfig) {
+			c.RetryPolicy = &RetryPolicy{MaxAttempts: 2}
+		})
+
+		rsp, err := client.GetTransactionInfo(context.Background(), "1000")
+		assert.NoError(t, err)
+		assert.Equal(t, "info", rsp.SignedTransactionInfo)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("should stop when the wait exceeds max elapsed time", func(t *testing.T) {
+		t.Parallel()
+		var calls atomic.Int32
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Retry-After", "3600")
+			writeAPIError(w, http.StatusTooManyRequests, RateLimitExceededError)
+		}), func(c *StoreConfig) {
+			c.RetryPolicy = &RetryPolicy{MaxAttempts: 3, MaxElapsedTime: time.Second}
+		})
+
+		_, err := client.GetTransactionInfo(context.Background(), "1000")
+		assert.ErrorIs(t, err, RateLimitExceededError)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("should stop when the context is cancelled", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		var calls atomic.Int32
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			cancel()
+			writeAPIError(w, http.StatusInternalServerError, GeneralInternalRetryableError)
+		}), func(c *StoreConfig) {
+			c.RetryPolicy = &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}
+		})
+
+		_, err := client.GetTransactionInfo(ctx, "1000")
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func TestStoreClient_HTTPError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should return an HTTPError for a status without an error body", func(t *testing.T) {
+		t.Parallel()
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = io.WriteString(w, "Unauthenticated")
+		}), nil)
+
+		_, err := client.GetTransactionInfo(context.Background(), "1000")
+		var httpErr *HTTPError
+		require.ErrorAs(t, err, &httpErr)
+		assert.Equal(t, http.StatusUnauthorized, httpErr.StatusCode)
+		assert.Equal(t, http.MethodGet, httpErr.Method)
+		assert.Equal(t, PathTransactionInfo, httpErr.PathTemplate)
+		assert.Equal(t, "Unauthenticated", httpErr.Body)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+		statusCode, err := client.FinishTransaction(context.Background(), "1000")
+		assert.Equal(t, http.StatusUnauthorized, statusCode)
+		assert.ErrorAs(t, err, &httpErr)
+	})
+
+	t.Run("should accept a 2xx status", func(t *testing.T) {
+		t.Parallel()
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		}), nil)
+
+		statusCode, err := client.SendConsumptionInfo(context.Background(), "1000", ConsumptionRequestBody{})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, statusCode)
+	})
+
+	t.Run("should decode the body of a 2xx status", func(t *testing.T) {
+		t.Parallel()
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = io.WriteString(w, `{"signedTransactionInfo":"info"}`)
+		}), nil)
+
+		rsp, err := client.GetTransactionInfo(context.Background(), "1000")
+		require.NoError(t, err)
+		assert.Equal(t, "info", rsp.SignedTransactionInfo)
+	})
+
+	t.Run("should retry a gateway failure", func(t *testing.T) {
+		t.Parallel()
+		var calls atomic.Int32
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			_, _ = io.WriteString(w, `{"signedTransactionInfo":"info"}`)
+		}), func(c *StoreConfig) {
+			c.RetryPolicy = &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+		})
+
+		rsp, err := client.GetTransactionInfo(context.Background(), "1000")
+		require.NoError(t, err)
+		assert.Equal(t, "info", rsp.SignedTransactionInfo)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	t.Parallel()
+	p := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		d := p.backoff(attempt)
+		assert.GreaterOrEqual(t, d, want/2)
+		assert.LessOrEqual(t, d, want)
+	}
+}
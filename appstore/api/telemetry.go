@@ -0,0 +1,35 @@
+package api
+
+// telemetryStore is the iap.store attribute of the App Store Server API calls.
+const telemetryStore = "appstore_server_api"
+
+// pathOperations names the operation of each endpoint path template after the StoreClient method calling it.
+var pathOperations = map[string]string{
+	PathLookUp:                              "LookupOrderID",
+	PathTransactionHistory:                  "GetTransactionHistory",
+	PathTransactionHistoryV1:                "GetTransactionHistoryV1",
+	PathTransactionInfo:                     "GetTransactionInfo",
+	PathRefundHistory:                       "GetRefundHistory",
+	PathGetALLSubscriptionStatus:            "GetALLSubscriptionStatuses",
+	PathConsumptionInfoV2:                   "SendConsumptionInfoV2",
+	PathConsumptionInfo:                     "SendConsumptionInfo",
+	PathExtendSubscriptionRenewalDate:       "ExtendSubscriptionRenewalDate",
+	PathExtendSubscriptionRenewalDateForAll: "ExtendSubscriptionRenewalDateForAll",
+	PathGetStatusOfSubscriptionRenewalDate:  "GetSubscriptionRenewalDataStatus",
+	PathGetNotificationHistory:              "GetNotificationHistory",
+	PathRequestTestNotification:             "SendRequestTestNotification",
+	PathGetTestNotificationStatus:           "GetTestNotificationStatus",
+	PathSetAppAccountToken:                  "SetAppAccountToken",
+	PathGetAppTransactionInfo:               "GetAppTransactionInfo",
+	PathFinishTransaction:                   "FinishTransaction",
+}
+
+// operationOf returns the operation and the path template of the endpoint of rawURL.
+// A URL outside of the known endpoints, sent with Do, has the "Do" operation and no template.
+func operationOf(rawURL string) (operation, pathTemplate string) {
+	template, ok := matchPathTemplate(rawURL)
+	if !ok {
+		return "Do", ""
+	}
+	return pathOperations[template], template
+}
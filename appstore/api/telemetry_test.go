@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/awa/go-iap/internal/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOperationOf(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name      string
+		URL       string
+		Operation string
+		Template  string
+	}{
+		{
+			Name:      "transaction info",
+			URL:       HostProduction + "/inApps/v1/transactions/2000000000000001",
+			Operation: "GetTransactionInfo",
+			Template:  PathTransactionInfo,
+		},
+		{
+			Name:      "app transaction info over transaction info",
+			URL:       HostProduction + "/inApps/v1/transactions/appTransactions/2000000000000001",
+			Operation: "GetAppTransactionInfo",
+			Template:  PathGetAppTransactionInfo,
+		},
+		{
+			Name:      "history with query",
+			URL:       HostProduction + "/inApps/v2/history/2000000000000001?revision=abc",
+			Operation: "GetTransactionHistory",
+			Template:  PathTransactionHistory,
+		},
+		{
+			Name:      "unknown endpoint",
+			URL:       HostProduction + "/inApps/v9/unknown",
+			Operation: "Do",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			operation, template := operationOf(tt.URL)
+			assert.Equal(t, tt.Operation, operation)
+			assert.Equal(t, tt.Template, template)
+		})
+	}
+
+	for template := range pathOperations {
+		assert.Contains(t, pathTemplates, template)
+	}
+	assert.Len(t, pathOperations, len(pathTemplates))
+}
+
+func TestStoreClient_Telemetry(t *testing.T) {
+	t.Parallel()
+
+	spans := tracetest.NewSpanRecorder()
+	client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, r.Header.Get("Authorization"))
+		writeAPIError(w, http.StatusNotFound, TransactionIdNotFoundError)
+	}), func(c *StoreConfig) {
+		c.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	})
+
+	_, err := client.GetTransactionInfo(context.Background(), "2000000000000001")
+	require.ErrorIs(t, err, TransactionIdNotFoundError)
+
+	ended := spans.Ended()
+	require.Len(t, ended, 1)
+	span := ended[0]
+	assert.Equal(t, "appstore_server_api GetTransactionInfo", span.Name())
+	assert.Equal(t, codes.Error, span.Status().Code)
+
+	attrs := attribute.NewSet(span.Attributes()...)
+	for key, want := range map[attribute.Key]string{
+		telemetry.URLTemplateKey: PathTransactionInfo,
+		telemetry.MethodKey:      http.MethodGet,
+		telemetry.StatusCodeKey:  "404",
+		telemetry.ErrorCodeKey:   "4040010",
+	} {
+		v, ok := attrs.Value(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, want, v.Emit(), key)
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const defaultTestNotificationPollInterval = 2 * time.Second
+
+// TestNotificationClient is the part of the App Store Server API CheckNotificationEndpoint calls, such as a StoreClient or an APIClient.
+type TestNotificationClient interface {
+	SendRequestTestNotification(ctx context.Context) (statusCode int, rsp *SendTestNotificationResponse, err error)
+	GetTestNotificationStatus(ctx context.Context, testNotificationToken string) (statusCode int, rsp *CheckTestNotificationResponse, err error)
+}
+
+// CheckNotificationEndpoint requests a test notification and polls its status every pollInterval, 2s by default,
+// until Apple reports an attempt to send it to the App Store Server Notifications URL of the environment.
+// It returns the result of the first attempt, FirstSendAttemptResultSuccess when the endpoint answered with a 2xx status.
+// Bound the wait with the deadline of ctx. An APIClient checks the production URL, pass its Sandbox() client to check
+// the sandbox URL.
+func CheckNotificationEndpoint(ctx context.Context, client TestNotificationClient, pollInterval time.Duration) (FirstSendAttemptResult, error) {
+	if pollInterval <= 0 {
+		pollInterval = defaultTestNotificationPollInterval
+	}
+
+	_, sent, err := client.SendRequestTestNotification(ctx)
+	if err != nil {
+		return "", err
+	}
+	if sent == nil || sent.TestNotificationToken == "" {
+		return "", InvalidTestNotificationTokenError
+	}
+
+	for {
+		if err := sleepContext(ctx, pollInterval); err != nil {
+			return "", err
+		}
+		_, status, err := client.GetTestNotificationStatus(ctx, sent.TestNotificationToken)
+		switch {
+		case err == nil:
+			if result, ok := status.FirstSendAttemptResult(); ok {
+				return result, nil
+			}
+		case errors.Is(err, TestNotificationNotFoundError), transient(err):
+			// Not available yet.
+		default:
+			return "", err
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreClient_TestNotification(t *testing.T) {
+	t.Parallel()
+
+	client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/inApps/v1/notifications/test":
+			assert.Equal(t, http.MethodPost, r.Method)
+			_, _ = io.WriteString(w, `{"testNotificationToken":"ce3af791-365e-4c60-841b-1674b43c1609_1698148900000"}`)
+		case "/inApps/v1/notifications/test/ce3af791-365e-4c60-841b-1674b43c1609_1698148900000":
+			_, _ = io.WriteString(w, `{"signedPayload":"eyJ...","sendAttempts":[{"attemptDate":1698148900000,"sendAttemptResult":"NO_RESPONSE"},{"attemptDate":1698148950000,"sendAttemptResult":"SUCCESS"}]}`)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}), nil)
+
+	statusCode, sent, err := client.SendRequestTestNotification(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, "ce3af791-365e-4c60-841b-1674b43c1609_1698148900000", sent.TestNotificationToken)
+
+	_, status, err := client.GetTestNotificationStatus(context.Background(), sent.TestNotificationToken)
+	require.NoError(t, err)
+	assert.Equal(t, "eyJ...", status.SignedPayload)
+	require.Len(t, status.SendAttempts, 2)
+	assert.Equal(t, SendAttemptItem{AttemptDate: 1698148950000, SendAttemptResult: FirstSendAttemptResultSuccess}, status.SendAttempts[1])
+	result, ok := status.FirstSendAttemptResult()
+	assert.True(t, ok)
+	assert.Equal(t, FirstSendAttemptResultNoResponse, result)
+}
+
+func TestCheckNotificationEndpoint(t *testing.T) {
+	t.Parallel()
+
+	t.Run("polls until a send attempt", func(t *testing.T) {
+		t.Parallel()
+		var polls atomic.Int32
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				_, _ = io.WriteString(w, `{"testNotificationToken":"token"}`)
+				return
+			}
+			switch polls.Add(1) {
+			case 1:
+				writeAPIError(w, http.StatusNotFound, TestNotificationNotFoundError)
+			case 2:
+				_, _ = io.WriteString(w, `{"signedPayload":"eyJ...","sendAttempts":[]}`)
+			default:
+				_, _ = io.WriteString(w, `{"signedPayload":"eyJ...","sendAttempts":[{"attemptDate":1698148900000,"sendAttemptResult":"TLS_ISSUE"}]}`)
+			}
+		}), nil)
+
+		result, err := CheckNotificationEndpoint(context.Background(), client, time.Millisecond)
+		require.NoError(t, err)
+		assert.Equal(t, FirstSendAttemptResultTlsIssue, result)
+		assert.Equal(t, int32(3), polls.Load())
+	})
+
+	t.Run("fails on a non-retryable error", func(t *testing.T) {
+		t.Parallel()
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeAPIError(w, http.StatusNotFound, ServerNotificationURLNotFoundError)
+		}), nil)
+
+		_, err := CheckNotificationEndpoint(context.Background(), client, time.Millisecond)
+		assert.ErrorIs(t, err, ServerNotificationURLNotFoundError)
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		t.Parallel()
+		client := newTestStoreClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				_, _ = io.WriteString(w, `{"testNotificationToken":"token"}`)
+				return
+			}
+			writeAPIError(w, http.StatusNotFound, TestNotificationNotFoundError)
+		}), nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := CheckNotificationEndpoint(ctx, client, 5*time.Millisecond)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -1,10 +1,16 @@
 package api
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/pem"
 	"errors"
+	"math/big"
 	"sync"
 	"time"
 
@@ -17,19 +23,21 @@ import (
 var (
 	ErrAuthKeyInvalidPem  = errors.New("token: AuthKey must be a valid .p8 PEM file")
 	ErrAuthKeyInvalidType = errors.New("token: AuthKey must be of type ecdsa.PrivateKey")
+	ErrSignerInvalidType  = errors.New("token: Signer must hold an ECDSA P-256 key")
 )
 
 // Token represents an Apple Provider Authentication Token (JSON Web Token).
 type Token struct {
 	sync.Mutex
 
-	KeyContent    []byte       // Loads a .p8 certificate
-	KeyID         string       // Your private key ID from App Store Connect (Ex: 2X9R4HXF34)
-	BundleID      string       // Your app’s bundle ID
-	Issuer        string       // Your issuer ID from the Keys page in App Store Connect (Ex: "57246542-96fe-1a63-e053-0824d011072a")
-	Sandbox       bool         // default is Production
-	IssuedAtFunc  func() int64 // The token’s creation time func. Default is current timestamp.
-	ExpiredAtFunc func() int64 // The token’s expiration time func.
+	KeyContent    []byte        // Loads a .p8 certificate
+	Signer        crypto.Signer // Signs with an ECDSA P-256 key held elsewhere instead of KeyContent
+	KeyID         string        // Your private key ID from App Store Connect (Ex: 2X9R4HXF34)
+	BundleID      string        // Your app’s bundle ID
+	Issuer        string        // Your issuer ID from the Keys page in App Store Connect (Ex: "57246542-96fe-1a63-e053-0824d011072a")
+	Sandbox       bool          // default is Production
+	IssuedAtFunc  func() int64  // The token’s creation time func. Default is current timestamp.
+	ExpiredAtFunc func() int64  // The token’s expiration time func.
 
 	// internal variables
 	AuthKey   *ecdsa.PrivateKey // .p8 private key
@@ -39,6 +47,8 @@ type Token struct {
 
 func (t *Token) WithConfig(c *StoreConfig) {
 	t.KeyContent = append(t.KeyContent[:0:0], c.KeyContent...)
+	t.Signer = c.Signer
+	t.AuthKey = nil
 	t.KeyID = c.KeyID
 	t.BundleID = c.BundleID
 	t.Issuer = c.Issuer
@@ -69,11 +79,10 @@ func (t *Token) Expired() bool {
 
 // Generate creates a new token.
 func (t *Token) Generate() error {
-	key, err := t.passKeyFromByte(t.KeyContent)
+	signer, err := t.signer()
 	if err != nil {
 		return err
 	}
-	t.AuthKey = key
 
 	now := time.Now()
 	issuedAt := now.Unix()
@@ -99,10 +108,10 @@ func (t *Token) Generate() error {
 			"nonce": uuid.New(),
 			"bid":   t.BundleID,
 		},
-		Method: jwt.SigningMethodES256,
+		Method: signingMethodES256Signer,
 	}
 
-	bearer, err := jwtToken.SignedString(t.AuthKey)
+	bearer, err := jwtToken.SignedString(signer)
 	if err != nil {
 		return err
 	}
@@ -112,8 +121,28 @@ func (t *Token) Generate() error {
 	return nil
 }
 
+// signer returns the Signer if set, otherwise the .p8 key of KeyContent, which is parsed once.
+func (t *Token) signer() (crypto.Signer, error) {
+	if t.Signer != nil {
+		return t.Signer, nil
+	}
+	if t.AuthKey == nil {
+		key, err := t.passKeyFromByte(t.KeyContent)
+		if err != nil {
+			return nil, err
+		}
+		t.AuthKey = key
+	}
+	return t.AuthKey, nil
+}
+
 // passKeyFromByte loads a .p8 certificate from an in memory byte array and returns an *ecdsa.PrivateKey.
 func (t *Token) passKeyFromByte(bytes []byte) (*ecdsa.PrivateKey, error) {
+	return parsePrivateKey(bytes)
+}
+
+// parsePrivateKey loads a .p8 certificate from an in memory byte array and returns an *ecdsa.PrivateKey.
+func parsePrivateKey(bytes []byte) (*ecdsa.PrivateKey, error) {
 	block, _ := pem.Decode(bytes)
 	if block == nil {
 		return nil, ErrAuthKeyInvalidPem
@@ -131,3 +160,46 @@ func (t *Token) passKeyFromByte(bytes []byte) (*ecdsa.PrivateKey, error) {
 		return nil, ErrAuthKeyInvalidType
 	}
 }
+
+// signingMethodES256Signer is the ES256 JWT signing method for any crypto.Signer holding an ECDSA P-256 key,
+// so that the key doesn't need to be in process memory.
+var signingMethodES256Signer = &signerSigningMethod{}
+
+type signerSigningMethod struct{}
+
+func (m *signerSigningMethod) Alg() string {
+	return jwt.SigningMethodES256.Alg()
+}
+
+func (m *signerSigningMethod) Verify(signingString string, sig []byte, key interface{}) error {
+	return jwt.SigningMethodES256.Verify(signingString, sig, key)
+}
+
+// Sign signs with key, a crypto.Signer, and converts its ASN.1 signature into the fixed size r || s of JWS.
+func (m *signerSigningMethod) Sign(signingString string, key interface{}) ([]byte, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, jwt.ErrInvalidKeyType
+	}
+	if pub, ok := signer.Public().(*ecdsa.PublicKey); !ok || pub.Curve != elliptic.P256() {
+		return nil, ErrSignerInvalidType
+	}
+
+	digest := sha256.Sum256([]byte(signingString))
+	der, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err = asn1.Unmarshal(der, &sig); err != nil {
+		return nil, err
+	}
+
+	const size = 32
+	out := make([]byte, 2*size)
+	sig.R.FillBytes(out[:size])
+	sig.S.FillBytes(out[size:])
+	return out, nil
+}
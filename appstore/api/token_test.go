@@ -0,0 +1,76 @@
+package api
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKMSSigner stands for a key held outside of the process, only its Sign method is reachable.
+type testKMSSigner struct {
+	key   crypto.Signer
+	calls int
+}
+
+func (s *testKMSSigner) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s *testKMSSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.calls++
+	return s.key.Sign(rand, digest, opts)
+}
+
+func TestToken_Generate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("signs with the crypto.Signer", func(t *testing.T) {
+		t.Parallel()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		signer := &testKMSSigner{key: key}
+
+		token := &Token{}
+		token.WithConfig(&StoreConfig{Signer: signer, KeyID: "TESTKEYID", BundleID: "com.example.app", Issuer: "test-issuer"})
+		bearer, err := token.GenerateIfExpired()
+		require.NoError(t, err)
+		assert.Equal(t, 1, signer.calls)
+		assert.Nil(t, token.AuthKey)
+
+		claims := jwt.MapClaims{}
+		parsed, err := jwt.ParseWithClaims(bearer, claims, func(*jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		}, jwt.WithValidMethods([]string{"ES256"}))
+		require.NoError(t, err)
+		assert.Equal(t, "TESTKEYID", parsed.Header["kid"])
+		assert.Equal(t, "com.example.app", claims["bid"])
+	})
+
+	t.Run("parses the .p8 key once", func(t *testing.T) {
+		t.Parallel()
+		token := &Token{}
+		token.WithConfig(&StoreConfig{KeyContent: newTestKeyContent(t)})
+		require.NoError(t, token.Generate())
+		key := token.AuthKey
+		require.NotNil(t, key)
+		require.NoError(t, token.Generate())
+		assert.Same(t, key, token.AuthKey)
+	})
+
+	t.Run("rejects a signer that isn't ECDSA P-256", func(t *testing.T) {
+		t.Parallel()
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		token := &Token{}
+		token.WithConfig(&StoreConfig{Signer: key})
+		assert.ErrorIs(t, token.Generate(), ErrSignerInvalidType)
+	})
+}
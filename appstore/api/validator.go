@@ -1,8 +1,16 @@
 package api
 
 import (
+	"container/list"
 	"context"
 	"errors"
+	"io"
+	"iter"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
 )
 
 // IAPAPIClient is an interface to call validation API in App Store Server API
@@ -10,9 +18,21 @@ type IAPAPIClient interface {
 	Verify(ctx context.Context, transactionId string) (*TransactionInfoResponse, error)
 }
 
+// Verify that APIClient implements StoreAPIClient
+var _ StoreAPIClient = (*APIClient)(nil)
+
+// defaultEnvironmentMemorySize bounds the number of transaction IDs whose environment APIClient remembers.
+const defaultEnvironmentMemorySize = 10000
+
+// APIClient routes the App Store Server API calls between the production and sandbox environments,
+// for callers that don't know where a purchase was made, such as TestFlight users.
+// Calls about a transaction go to production first and fall back to sandbox when production doesn't know the transaction.
+// The environment that answered is remembered per transaction ID, so that later calls go there directly.
+// Calls that aren't about a transaction, such as the notification history, go to production.
 type APIClient struct {
 	productionCli *StoreClient
 	sandboxCli    *StoreClient
+	environments  *environmentMemory
 }
 
 func NewAPIClient(config StoreConfig) *APIClient {
@@ -20,13 +40,355 @@ func NewAPIClient(config StoreConfig) *APIClient {
 	prodConf.Sandbox = false
 	sandboxConf := config
 	sandboxConf.Sandbox = true
-	return &APIClient{productionCli: NewStoreClient(&prodConf), sandboxCli: NewStoreClient(&sandboxConf)}
+	return &APIClient{
+		productionCli: NewStoreClient(&prodConf),
+		sandboxCli:    NewStoreClient(&sandboxConf),
+		environments:  newEnvironmentMemory(defaultEnvironmentMemorySize),
+	}
 }
 
-func (c *APIClient) Verify(ctx context.Context, transactionId string) (*TransactionInfoResponse, error) {
-	result, err := c.productionCli.GetTransactionInfo(ctx, transactionId)
-	if err != nil && errors.Is(err, TransactionIdNotFoundError) {
-		result, err = c.sandboxCli.GetTransactionInfo(ctx, transactionId)
+// Production returns the client of the production environment.
+func (c *APIClient) Production() *StoreClient {
+	return c.productionCli
+}
+
+// Sandbox returns the client of the sandbox environment.
+func (c *APIClient) Sandbox() *StoreClient {
+	return c.sandboxCli
+}
+
+// EnvironmentOf returns the environment that answered the last call about the transaction ID, or the order ID of
+// LookupOrderID. Call it after the call, to know where the purchase was made.
+func (c *APIClient) EnvironmentOf(transactionId string) (Environment, bool) {
+	return c.environments.get(transactionId)
+}
+
+// isNotFound reports whether err means that the environment doesn't know the transaction.
+func isNotFound(err error) bool {
+	return errors.Is(err, TransactionIdNotFoundError) ||
+		errors.Is(err, OriginalTransactionIdNotFoundError) ||
+		errors.Is(err, OriginalTransactionIdNotFoundRetryableError)
+}
+
+// clients returns the clients to try for the transaction ID, the remembered environment first.
+func (c *APIClient) clients(transactionId string) [2]*StoreClient {
+	if env, ok := c.environments.get(transactionId); ok && env == Sandbox {
+		return [2]*StoreClient{c.sandboxCli, c.productionCli}
+	}
+	return [2]*StoreClient{c.productionCli, c.sandboxCli}
+}
+
+func (c *APIClient) environment(cli *StoreClient) Environment {
+	if cli == c.sandboxCli {
+		return Sandbox
+	}
+	return Production
+}
+
+// route calls call with the client of each environment in turn, until one doesn't report notFound.
+func route[T any](c *APIClient, transactionId string, notFound func(T, error) bool, call func(*StoreClient) (T, error)) (T, error) {
+	var (
+		result T
+		err    error
+	)
+	for _, cli := range c.clients(transactionId) {
+		result, err = call(cli)
+		if notFound(result, err) {
+			continue
+		}
+		if err == nil {
+			c.environments.set(transactionId, c.environment(cli))
+		}
+		break
 	}
 	return result, err
 }
+
+func notFoundError[T any](_ T, err error) bool {
+	return isNotFound(err)
+}
+
+// routeSeq is like route for iterators, it falls back only when the first page reports notFound.
+func routeSeq[T any](c *APIClient, transactionId string, seq func(*StoreClient) iter.Seq2[T, error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var notFoundErr error
+		for _, cli := range c.clients(transactionId) {
+			answered := false
+			notFound := false
+			for v, err := range seq(cli) {
+				if !answered {
+					if isNotFound(err) {
+						notFound, notFoundErr = true, err
+						break
+					}
+					answered = true
+					if err == nil {
+						c.environments.set(transactionId, c.environment(cli))
+					}
+				}
+				if !yield(v, err) {
+					return
+				}
+			}
+			if !notFound {
+				return
+			}
+		}
+		var zero T
+		yield(zero, notFoundErr)
+	}
+}
+
+func (c *APIClient) Verify(ctx context.Context, transactionId string) (*TransactionInfoResponse, error) {
+	return c.GetTransactionInfo(ctx, transactionId)
+}
+
+// GetALLSubscriptionStatuses https://developer.apple.com/documentation/appstoreserverapi/get_all_subscription_statuses
+func (c *APIClient) GetALLSubscriptionStatuses(ctx context.Context, originalTransactionId string, query *url.Values) (*StatusResponse, error) {
+	return route(c, originalTransactionId, notFoundError, func(cli *StoreClient) (*StatusResponse, error) {
+		return cli.GetALLSubscriptionStatuses(ctx, originalTransactionId, query)
+	})
+}
+
+// GetALLSubscriptionStatusesWithRequest https://developer.apple.com/documentation/appstoreserverapi/get_all_subscription_statuses
+func (c *APIClient) GetALLSubscriptionStatusesWithRequest(ctx context.Context, originalTransactionId string, req SubscriptionStatusesRequest) (*StatusResponse, error) {
+	return route(c, originalTransactionId, notFoundError, func(cli *StoreClient) (*StatusResponse, error) {
+		return cli.GetALLSubscriptionStatusesWithRequest(ctx, originalTransactionId, req)
+	})
+}
+
+// GetRefundHistory https://developer.apple.com/documentation/appstoreserverapi/get_refund_history
+func (c *APIClient) GetRefundHistory(ctx context.Context, originalTransactionId string) ([]*RefundLookupResponse, error) {
+	return route(c, originalTransactionId, notFoundError, func(cli *StoreClient) ([]*RefundLookupResponse, error) {
+		return cli.GetRefundHistory(ctx, originalTransactionId)
+	})
+}
+
+// GetSubscriptionRenewalDataStatus https://developer.apple.com/documentation/appstoreserverapi/get_status_of_subscription_renewal_date_extensions
+func (c *APIClient) GetSubscriptionRenewalDataStatus(ctx context.Context, productId, requestIdentifier string) (int, *MassExtendRenewalDateStatusResponse, error) {
+	return c.productionCli.GetSubscriptionRenewalDataStatus(ctx, productId, requestIdentifier)
+}
+
+// GetTransactionHistory https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history
+func (c *APIClient) GetTransactionHistory(ctx context.Context, transactionId string, query *url.Values) ([]*HistoryResponse, error) {
+	return route(c, transactionId, notFoundError, func(cli *StoreClient) ([]*HistoryResponse, error) {
+		return cli.GetTransactionHistory(ctx, transactionId, query)
+	})
+}
+
+// GetTransactionHistoryWithRequest https://developer.apple.com/documentation/appstoreserverapi/get_transaction_history
+func (c *APIClient) GetTransactionHistoryWithRequest(ctx context.Context, transactionId string, req TransactionHistoryRequest) ([]*HistoryResponse, error) {
+	return route(c, transactionId, notFoundError, func(cli *StoreClient) ([]*HistoryResponse, error) {
+		return cli.GetTransactionHistoryWithRequest(ctx, transactionId, req)
+	})
+}
+
+// GetTransactionInfo https://developer.apple.com/documentation/appstoreserverapi/get_transaction_info
+func (c *APIClient) GetTransactionInfo(ctx context.Context, transactionId string) (*TransactionInfoResponse, error) {
+	return route(c, transactionId, notFoundError, func(cli *StoreClient) (*TransactionInfoResponse, error) {
+		return cli.GetTransactionInfo(ctx, transactionId)
+	})
+}
+
+// LookupOrderID https://developer.apple.com/documentation/appstoreserverapi/look_up_order_id
+// An order ID that is invalid in production is looked up in sandbox.
+func (c *APIClient) LookupOrderID(ctx context.Context, orderId string) (*OrderLookupResponse, error) {
+	return route(c, orderId, func(rsp *OrderLookupResponse, err error) bool {
+		return err == nil && rsp != nil && rsp.Status != 0
+	}, func(cli *StoreClient) (*OrderLookupResponse, error) {
+		return cli.LookupOrderID(ctx, orderId)
+	})
+}
+
+// GetAppTransactionInfo https://developer.apple.com/documentation/appstoreserverapi/get-app-transaction-info
+func (c *APIClient) GetAppTransactionInfo(ctx context.Context, transactionId string) (*AppTransactionInfoResponse, error) {
+	return route(c, transactionId, notFoundError, func(cli *StoreClient) (*AppTransactionInfoResponse, error) {
+		return cli.GetAppTransactionInfo(ctx, transactionId)
+	})
+}
+
+// FinishTransaction https://developer.apple.com/documentation/appstoreserverapi/finish-transaction
+func (c *APIClient) FinishTransaction(ctx context.Context, transactionId string) (int, error) {
+	return route(c, transactionId, notFoundError, func(cli *StoreClient) (int, error) {
+		return cli.FinishTransaction(ctx, transactionId)
+	})
+}
+
+// TransactionHistoryIter iterates over the transaction history of the environment that knows the transaction.
+func (c *APIClient) TransactionHistoryIter(ctx context.Context, transactionId string, query *url.Values, opts *PageOptions) iter.Seq2[*JWSTransaction, error] {
+	return routeSeq(c, transactionId, func(cli *StoreClient) iter.Seq2[*JWSTransaction, error] {
+		return cli.TransactionHistoryIter(ctx, transactionId, query, opts)
+	})
+}
+
+// RefundHistoryIter iterates over the refund history of the environment that knows the transaction.
+func (c *APIClient) RefundHistoryIter(ctx context.Context, originalTransactionId string, opts *PageOptions) iter.Seq2[*JWSTransaction, error] {
+	return routeSeq(c, originalTransactionId, func(cli *StoreClient) iter.Seq2[*JWSTransaction, error] {
+		return cli.RefundHistoryIter(ctx, originalTransactionId, opts)
+	})
+}
+
+// ExtendSubscriptionRenewalDate https://developer.apple.com/documentation/appstoreserverapi/extend_a_subscription_renewal_date
+func (c *APIClient) ExtendSubscriptionRenewalDate(ctx context.Context, originalTransactionId string, body ExtendRenewalDateRequest) (int, error) {
+	return route(c, originalTransactionId, notFoundError, func(cli *StoreClient) (int, error) {
+		return cli.ExtendSubscriptionRenewalDate(ctx, originalTransactionId, body)
+	})
+}
+
+// ExtendSubscriptionRenewalDateWithResponse https://developer.apple.com/documentation/appstoreserverapi/extend_a_subscription_renewal_date
+func (c *APIClient) ExtendSubscriptionRenewalDateWithResponse(ctx context.Context, originalTransactionId string, body ExtendRenewalDateRequest) (int, *ExtendRenewalDateResponse, error) {
+	var statusCode int
+	rsp, err := route(c, originalTransactionId, notFoundError, func(cli *StoreClient) (*ExtendRenewalDateResponse, error) {
+		var (
+			rsp *ExtendRenewalDateResponse
+			err error
+		)
+		statusCode, rsp, err = cli.ExtendSubscriptionRenewalDateWithResponse(ctx, originalTransactionId, body)
+		return rsp, err
+	})
+	return statusCode, rsp, err
+}
+
+// ExtendSubscriptionRenewalDateForAll https://developer.apple.com/documentation/appstoreserverapi/extend_subscription_renewal_dates_for_all_active_subscribers
+func (c *APIClient) ExtendSubscriptionRenewalDateForAll(ctx context.Context, body MassExtendRenewalDateRequest) (int, error) {
+	return c.productionCli.ExtendSubscriptionRenewalDateForAll(ctx, body)
+}
+
+func (c *APIClient) ParseSignedTransactions(transactions []string) ([]*JWSTransaction, error) {
+	return c.productionCli.ParseSignedTransactions(transactions)
+}
+
+func (c *APIClient) ParseSignedTransactionsWithOptions(transactions []string, opts ParseTransactionsOptions) (*ParsedTransactions, error) {
+	return c.productionCli.ParseSignedTransactionsWithOptions(transactions, opts)
+}
+
+func (c *APIClient) ParseJWSEncodeString(jwsEncode string) (interface{}, error) {
+	return c.productionCli.ParseJWSEncodeString(jwsEncode)
+}
+
+func (c *APIClient) ParseSignedTransaction(transaction string) (*JWSTransaction, error) {
+	return c.productionCli.ParseSignedTransaction(transaction)
+}
+
+func (c *APIClient) ParseSignedRenewalInfo(renewalInfo string) (*JWSRenewalInfoDecodedPayload, error) {
+	return c.productionCli.ParseSignedRenewalInfo(renewalInfo)
+}
+
+func (c *APIClient) ParseSignedAppTransaction(appTransaction string) (*JWSAppTransactionDecodedPayload, error) {
+	return c.productionCli.ParseSignedAppTransaction(appTransaction)
+}
+
+func (c *APIClient) ParseSignedNotification(signedPayload string) (*appstore.SubscriptionNotificationV2DecodedPayload, error) {
+	return c.productionCli.ParseSignedNotification(signedPayload)
+}
+
+func (c *APIClient) DecodeNotificationV2(signedPayload string) (*NotificationV2, error) {
+	return c.productionCli.DecodeNotificationV2(signedPayload)
+}
+
+// GetAllNotificationHistory https://developer.apple.com/documentation/appstoreserverapi/get_notification_history
+func (c *APIClient) GetAllNotificationHistory(ctx context.Context, body NotificationHistoryRequest, duration time.Duration) ([]NotificationHistoryResponseItem, error) {
+	return c.productionCli.GetAllNotificationHistory(ctx, body, duration)
+}
+
+// GetNotificationHistory https://developer.apple.com/documentation/appstoreserverapi/get_notification_history
+func (c *APIClient) GetNotificationHistory(ctx context.Context, body NotificationHistoryRequest, paginationToken string) (*NotificationHistoryResponses, error) {
+	return c.productionCli.GetNotificationHistory(ctx, body, paginationToken)
+}
+
+// GetTestNotificationStatus https://developer.apple.com/documentation/appstoreserverapi/get_test_notification_status
+// It asks production, call it on Sandbox() for a test notification requested there.
+func (c *APIClient) GetTestNotificationStatus(ctx context.Context, testNotificationToken string) (int, *CheckTestNotificationResponse, error) {
+	return c.productionCli.GetTestNotificationStatus(ctx, testNotificationToken)
+}
+
+func (c *APIClient) NotificationHistoryIter(ctx context.Context, body NotificationHistoryRequest, opts *PageOptions) iter.Seq2[NotificationHistoryResponseItem, error] {
+	return c.productionCli.NotificationHistoryIter(ctx, body, opts)
+}
+
+// SendRequestTestNotification https://developer.apple.com/documentation/appstoreserverapi/request_a_test_notification
+// It requests a test notification to the production URL, call it on Sandbox() for the sandbox URL.
+func (c *APIClient) SendRequestTestNotification(ctx context.Context) (int, *SendTestNotificationResponse, error) {
+	return c.productionCli.SendRequestTestNotification(ctx)
+}
+
+// SendConsumptionInfo https://developer.apple.com/documentation/appstoreserverapi/send_consumption_information
+func (c *APIClient) SendConsumptionInfo(ctx context.Context, originalTransactionId string, body ConsumptionRequestBody) (int, error) {
+	return route(c, originalTransactionId, notFoundError, func(cli *StoreClient) (int, error) {
+		return cli.SendConsumptionInfo(ctx, originalTransactionId, body)
+	})
+}
+
+// SendConsumptionInfoV2 https://developer.apple.com/documentation/appstoreserverapi/send-consumption-information
+func (c *APIClient) SendConsumptionInfoV2(ctx context.Context, transactionId string, body ConsumptionRequest) (int, error) {
+	return route(c, transactionId, notFoundError, func(cli *StoreClient) (int, error) {
+		return cli.SendConsumptionInfoV2(ctx, transactionId, body)
+	})
+}
+
+// SetAppAccountToken https://developer.apple.com/documentation/appstoreserverapi/set-app-account-token
+func (c *APIClient) SetAppAccountToken(ctx context.Context, originalTransactionId string, body UpdateAppAccountTokenRequest) (int, error) {
+	return route(c, originalTransactionId, notFoundError, func(cli *StoreClient) (int, error) {
+		return cli.SetAppAccountToken(ctx, originalTransactionId, body)
+	})
+}
+
+// Do sends the request with the production client, the url carries the host of the environment.
+func (c *APIClient) Do(ctx context.Context, method string, url string, body io.Reader) (int, []byte, error) {
+	return c.productionCli.Do(ctx, method, url, body)
+}
+
+// environmentMemory is a bounded LRU map from transaction ID to the environment that knows it.
+// An environmentMemory is safe for concurrent use.
+type environmentMemory struct {
+	size int
+
+	mu      sync.Mutex
+	lru     *list.List // of *environmentEntry, most recently used first
+	entries map[string]*list.Element
+}
+
+type environmentEntry struct {
+	transactionId string
+	environment   Environment
+}
+
+func newEnvironmentMemory(size int) *environmentMemory {
+	return &environmentMemory{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (m *environmentMemory) get(transactionId string) (Environment, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[transactionId]
+	if !ok {
+		return "", false
+	}
+	m.lru.MoveToFront(e)
+	return e.Value.(*environmentEntry).environment, true
+}
+
+func (m *environmentMemory) set(transactionId string, env Environment) {
+	if transactionId == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[transactionId]; ok {
+		e.Value.(*environmentEntry).environment = env
+		m.lru.MoveToFront(e)
+		return
+	}
+	m.entries[transactionId] = m.lru.PushFront(&environmentEntry{transactionId: transactionId, environment: env})
+	for m.lru.Len() > m.size {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		delete(m.entries, oldest.Value.(*environmentEntry).transactionId)
+	}
+}
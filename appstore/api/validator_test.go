@@ -0,0 +1,118 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/api/apitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAPIClient returns an APIClient whose environments are served by production and sandbox, with the configuration of
+// their clients altered by configure if not nil.
+func newTestAPIClient(t *testing.T, production, sandbox http.Handler, configure func(*api.StoreConfig)) *api.APIClient {
+	t.Helper()
+	return api.NewTestAPIClient(api.NewTestStoreClient(t, production, configure), api.NewTestStoreClient(t, sandbox, configure))
+}
+
+// knownTransactions answers with the transaction info of the given IDs and TransactionIdNotFoundError for the others.
+func knownTransactions(calls *atomic.Int32, ids ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		for _, known := range ids {
+			if id == known {
+				_ = json.NewEncoder(w).Encode(api.TransactionInfoResponse{SignedTransactionInfo: id})
+				return
+			}
+		}
+		api.WriteAPIError(w, http.StatusNotFound, api.TransactionIdNotFoundError)
+	})
+}
+
+func TestAPIClient_Routing(t *testing.T) {
+	t.Parallel()
+	var productionCalls, sandboxCalls atomic.Int32
+	client := newTestAPIClient(t, knownTransactions(&productionCalls, "prod"), knownTransactions(&sandboxCalls, "sandbox"), nil)
+
+	ctx := context.Background()
+	rsp, err := client.GetTransactionInfo(ctx, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, "prod", rsp.SignedTransactionInfo)
+	env, ok := client.EnvironmentOf("prod")
+	assert.True(t, ok)
+	assert.Equal(t, api.Production, env)
+	assert.Equal(t, int32(0), sandboxCalls.Load())
+
+	rsp, err = client.Verify(ctx, "sandbox")
+	require.NoError(t, err)
+	assert.Equal(t, "sandbox", rsp.SignedTransactionInfo)
+	assert.Equal(t, int32(2), productionCalls.Load())
+	assert.Equal(t, int32(1), sandboxCalls.Load())
+
+	env, ok = client.EnvironmentOf("sandbox")
+	assert.True(t, ok)
+	assert.Equal(t, api.Sandbox, env)
+
+	_, err = client.GetTransactionInfo(ctx, "sandbox")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), productionCalls.Load(), "remembered environment must be called first")
+	assert.Equal(t, int32(2), sandboxCalls.Load())
+
+	_, err = client.GetTransactionInfo(ctx, "unknown")
+	assert.ErrorIs(t, err, api.TransactionIdNotFoundError)
+	_, ok = client.EnvironmentOf("unknown")
+	assert.False(t, ok)
+}
+
+func TestAPIClient_LookupOrderID(t *testing.T) {
+	t.Parallel()
+	lookup := func(status int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(api.OrderLookupResponse{Status: status})
+		})
+	}
+	client := newTestAPIClient(t, lookup(1), lookup(0), nil)
+
+	rsp, err := client.LookupOrderID(context.Background(), "ORDER")
+	require.NoError(t, err)
+	assert.Equal(t, 0, rsp.Status)
+	env, _ := client.EnvironmentOf("ORDER")
+	assert.Equal(t, api.Sandbox, env)
+}
+
+func TestAPIClient_TransactionHistoryIter(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+	history := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(api.HistoryResponse{
+			SignedTransactions: []string{ca.Sign(api.JWSTransaction{TransactionID: "1"})},
+		})
+	})
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.WriteAPIError(w, http.StatusNotFound, api.OriginalTransactionIdNotFoundError)
+	})
+	client := newTestAPIClient(t, notFound, history, func(c *api.StoreConfig) {
+		c.RootCertificates = ca.RootCertificates()
+	})
+
+	var ids []string
+	for tran, err := range client.TransactionHistoryIter(context.Background(), "1", nil, &api.PageOptions{Delay: -1}) {
+		require.NoError(t, err)
+		ids = append(ids, tran.TransactionID)
+	}
+	assert.Equal(t, []string{"1"}, ids)
+	env, _ := client.EnvironmentOf("1")
+	assert.Equal(t, api.Sandbox, env)
+
+	client = newTestAPIClient(t, notFound, notFound, nil)
+	for _, err := range client.RefundHistoryIter(context.Background(), "1", nil) {
+		assert.ErrorIs(t, err, api.OriginalTransactionIdNotFoundError)
+	}
+}
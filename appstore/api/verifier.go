@@ -0,0 +1,182 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Errors returned by SignedDataVerifier, wrapped together with the details of the failure.
+var (
+	ErrVerifierAppAppleIDRequired = errors.New("verifier: AppAppleID is required in the Production environment")
+	ErrInvalidSignature           = errors.New("verifier: invalid JWS signature")
+	ErrInvalidChain               = errors.New("verifier: invalid x5c certificate chain")
+	ErrMissingAppleOID            = errors.New("verifier: certificate is missing the Apple marker extension")
+	ErrInvalidBundleID            = errors.New("verifier: bundle id does not match")
+	ErrInvalidAppAppleID          = errors.New("verifier: app apple id does not match")
+	ErrInvalidEnvironment         = errors.New("verifier: environment does not match")
+)
+
+// SignedDataVerifierConfig configures the app and environment a SignedDataVerifier accepts signed data from.
+type SignedDataVerifierConfig struct {
+	BundleID         string      // Your app’s bundle ID
+	AppAppleID       int64       // Your app’s Apple ID, required in the Production environment
+	Environment      Environment // The environment the signed data must come from
+	RootCertificates [][]byte    // DER or PEM encoded trusted root certificates. Default is Apple Root CA - G3.
+
+	OCSP                *appstore.OCSPChecker     // Optional online revocation check of the x5c chain
+	VerificationTime    appstore.VerificationTime // The time the x5c chain is verified at. Default is the current time.
+	VerificationNowFunc func() time.Time          // The current time func. Default is time.Now.
+}
+
+// SignedDataVerifier verifies and decodes the JWS signed data of the App Store.
+// Besides the signature and the x5c chain, it checks that the data belongs to the configured app and environment.
+// Doc: https://developer.apple.com/documentation/appstoreserverapi/jwstransaction
+type SignedDataVerifier struct {
+	cert        *Cert
+	bundleID    string
+	appAppleID  int64
+	environment Environment
+}
+
+// NewSignedDataVerifier creates a SignedDataVerifier.
+func NewSignedDataVerifier(config *SignedDataVerifierConfig) (*SignedDataVerifier, error) {
+	if config.Environment == Production && config.AppAppleID == 0 {
+		return nil, ErrVerifierAppAppleIDRequired
+	}
+
+	cert, err := newCert(config.RootCertificates)
+	if err != nil {
+		return nil, err
+	}
+	cert.ocsp = config.OCSP
+	cert.verificationTime = config.VerificationTime
+	cert.now = config.VerificationNowFunc
+
+	return &SignedDataVerifier{
+		cert:        cert,
+		bundleID:    config.BundleID,
+		appAppleID:  config.AppAppleID,
+		environment: config.Environment,
+	}, nil
+}
+
+// VerifyAndDecodeTransaction verifies and decodes a signedTransaction or signedTransactionInfo.
+func (v *SignedDataVerifier) VerifyAndDecodeTransaction(signedTransaction string) (*JWSTransaction, error) {
+	tran := &JWSTransaction{}
+	if err := v.verify(signedTransaction, tran); err != nil {
+		return nil, err
+	}
+	if tran.BundleID != v.bundleID {
+		return nil, fmt.Errorf("%w: expected %q, got %q", ErrInvalidBundleID, v.bundleID, tran.BundleID)
+	}
+	if err := v.checkEnvironment(tran.Environment); err != nil {
+		return nil, err
+	}
+	return tran, nil
+}
+
+// VerifyAndDecodeRenewalInfo verifies and decodes a signedRenewalInfo.
+func (v *SignedDataVerifier) VerifyAndDecodeRenewalInfo(signedRenewalInfo string) (*JWSRenewalInfoDecodedPayload, error) {
+	renewalInfo := &JWSRenewalInfoDecodedPayload{}
+	if err := v.verify(signedRenewalInfo, renewalInfo); err != nil {
+		return nil, err
+	}
+	if err := v.checkEnvironment(renewalInfo.Environment); err != nil {
+		return nil, err
+	}
+	return renewalInfo, nil
+}
+
+// VerifyAndDecodeAppTransaction verifies and decodes a signedAppTransactionInfo.
+func (v *SignedDataVerifier) VerifyAndDecodeAppTransaction(signedAppTransaction string) (*JWSAppTransactionDecodedPayload, error) {
+	appTran := &JWSAppTransactionDecodedPayload{}
+	if err := v.verify(signedAppTransaction, appTran); err != nil {
+		return nil, err
+	}
+	if err := v.checkApp(appTran.BundleId, appTran.AppAppleId, appTran.ReceiptType); err != nil {
+		return nil, err
+	}
+	return appTran, nil
+}
+
+// VerifyAndDecodeNotification verifies and decodes the signedPayload of an App Store Server Notification V2.
+// The app and environment are checked against the section the notification carries: its data, summary, app data
+// or external purchase token.
+func (v *SignedDataVerifier) VerifyAndDecodeNotification(signedPayload string) (*appstore.SubscriptionNotificationV2DecodedPayload, error) {
+	notification := &appstore.SubscriptionNotificationV2DecodedPayload{}
+	if err := v.verify(signedPayload, notification); err != nil {
+		return nil, err
+	}
+
+	bundleID, appAppleID, environment, ok := notification.App()
+	if !ok {
+		return nil, fmt.Errorf("%w: notification carries no bundle id", ErrInvalidBundleID)
+	}
+	if err := v.checkApp(bundleID, appAppleID, Environment(environment)); err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
+
+func (v *SignedDataVerifier) checkApp(bundleID string, appAppleID int64, environment Environment) error {
+	if bundleID != v.bundleID {
+		return fmt.Errorf("%w: expected %q, got %q", ErrInvalidBundleID, v.bundleID, bundleID)
+	}
+	if v.environment == Production && appAppleID != v.appAppleID {
+		return fmt.Errorf("%w: expected %d, got %d", ErrInvalidAppAppleID, v.appAppleID, appAppleID)
+	}
+	return v.checkEnvironment(environment)
+}
+
+func (v *SignedDataVerifier) checkEnvironment(environment Environment) error {
+	if environment != v.environment {
+		return fmt.Errorf("%w: expected %q, got %q", ErrInvalidEnvironment, v.environment, environment)
+	}
+	return nil
+}
+
+// verify checks the x5c chain and the Apple marker extensions of the token, then its signature, and decodes the payload into claims.
+func (v *SignedDataVerifier) verify(token string, claims jwt.Claims) error {
+	header, err := parseJWSHeader(token)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+
+	leaf, err := v.cert.verifyX5C(header.X5C, v.cert.verificationTimeOf(token), checkAppleOIDs)
+	if errors.Is(err, ErrMissingAppleOID) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidChain, err)
+	}
+
+	pk, ok := leaf.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("%w: public key must be of type ecdsa.PublicKey", ErrInvalidChain)
+	}
+
+	_, err = jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return pk, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSignature, err)
+	}
+	return nil
+}
+
+// checkAppleOIDs checks that the leaf and intermediate certificates carry the Apple marker extensions.
+func checkAppleOIDs(leaf, intermediate, _ *x509.Certificate) error {
+	if !hasExtension(leaf, oidAppleLeafCert) {
+		return fmt.Errorf("%w: leaf certificate %q", ErrMissingAppleOID, leaf.Subject.CommonName)
+	}
+	if !hasExtension(intermediate, oidAppleIntermediateCert) {
+		return fmt.Errorf("%w: intermediate certificate %q", ErrMissingAppleOID, intermediate.Subject.CommonName)
+	}
+	return nil
+}
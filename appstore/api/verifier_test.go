@@ -0,0 +1,209 @@
+package api_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awa/go-iap/appstore"
+	"github.com/awa/go-iap/appstore/api"
+	"github.com/awa/go-iap/appstore/api/apitest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestVerifier(t testing.TB, ca *apitest.CA, environment api.Environment) *api.SignedDataVerifier {
+	t.Helper()
+	verifier, err := api.NewSignedDataVerifier(&api.SignedDataVerifierConfig{
+		BundleID:         "com.example.app",
+		AppAppleID:       1234,
+		Environment:      environment,
+		RootCertificates: ca.RootCertificates(),
+	})
+	require.NoError(t, err)
+	return verifier
+}
+
+func TestNewSignedDataVerifier(t *testing.T) {
+	t.Parallel()
+	_, err := api.NewSignedDataVerifier(&api.SignedDataVerifierConfig{BundleID: "com.example.app", Environment: api.Production})
+	assert.ErrorIs(t, err, api.ErrVerifierAppAppleIDRequired)
+
+	_, err = api.NewSignedDataVerifier(&api.SignedDataVerifierConfig{BundleID: "com.example.app", Environment: api.Sandbox, RootCertificates: [][]byte{[]byte("invalid")}})
+	assert.Error(t, err)
+
+	_, err = api.NewSignedDataVerifier(&api.SignedDataVerifierConfig{BundleID: "com.example.app", Environment: api.Sandbox})
+	assert.NoError(t, err)
+}
+
+func TestSignedDataVerifier_VerifyAndDecodeTransaction(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+
+	tests := []struct {
+		Name     string
+		CA       *apitest.CA
+		Claims   api.JWSTransaction
+		Expected error
+	}{
+		{Name: "valid", CA: ca, Claims: api.JWSTransaction{TransactionID: "1", BundleID: "com.example.app", Environment: api.Production}},
+		{Name: "wrong bundle", CA: ca, Claims: api.JWSTransaction{TransactionID: "1", BundleID: "com.other.app", Environment: api.Production}, Expected: api.ErrInvalidBundleID},
+		{Name: "wrong environment", CA: ca, Claims: api.JWSTransaction{TransactionID: "1", BundleID: "com.example.app", Environment: api.Sandbox}, Expected: api.ErrInvalidEnvironment},
+		{Name: "untrusted root", CA: apitest.NewCA(nil), Claims: api.JWSTransaction{TransactionID: "1", BundleID: "com.example.app", Environment: api.Production}, Expected: api.ErrInvalidChain},
+		{Name: "leaf without apple oid", CA: apitest.NewCA(&apitest.CAOptions{WithoutLeafOID: true}), Claims: api.JWSTransaction{BundleID: "com.example.app", Environment: api.Production}, Expected: api.ErrMissingAppleOID},
+		{Name: "intermediate without apple oid", CA: apitest.NewCA(&apitest.CAOptions{WithoutIntermediateOID: true}), Claims: api.JWSTransaction{BundleID: "com.example.app", Environment: api.Production}, Expected: api.ErrMissingAppleOID},
+	}
+	verifier := newTestVerifier(t, ca, api.Production)
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			tran, err := verifier.VerifyAndDecodeTransaction(test.CA.Sign(test.Claims))
+			if test.Expected != nil {
+				assert.ErrorIs(t, err, test.Expected)
+				assert.Nil(t, tran)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "1", tran.TransactionID)
+		})
+	}
+
+	t.Run("tampered payload", func(t *testing.T) {
+		signed := strings.Split(ca.Sign(api.JWSTransaction{TransactionID: "1", BundleID: "com.example.app", Environment: api.Production}), ".")
+		other := strings.Split(ca.Sign(api.JWSTransaction{TransactionID: "2", BundleID: "com.example.app", Environment: api.Production}), ".")
+		_, err := verifier.VerifyAndDecodeTransaction(signed[0] + "." + other[1] + "." + signed[2])
+		assert.ErrorIs(t, err, api.ErrInvalidSignature)
+	})
+}
+
+func TestSignedDataVerifier_VerifyAndDecodeRenewalInfo(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+	verifier := newTestVerifier(t, ca, api.Sandbox)
+
+	info, err := verifier.VerifyAndDecodeRenewalInfo(ca.Sign(api.JWSRenewalInfoDecodedPayload{OriginalTransactionId: "1", Environment: api.Sandbox}))
+	assert.NoError(t, err)
+	assert.Equal(t, "1", info.OriginalTransactionId)
+
+	_, err = verifier.VerifyAndDecodeRenewalInfo(ca.Sign(api.JWSRenewalInfoDecodedPayload{OriginalTransactionId: "1", Environment: api.Production}))
+	assert.ErrorIs(t, err, api.ErrInvalidEnvironment)
+}
+
+func TestSignedDataVerifier_VerifyAndDecodeAppTransaction(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+	verifier := newTestVerifier(t, ca, api.Production)
+
+	appTran, err := verifier.VerifyAndDecodeAppTransaction(ca.Sign(api.JWSAppTransactionDecodedPayload{AppAppleId: 1234, BundleId: "com.example.app", ReceiptType: api.Production, AppTransactionId: "a"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "a", appTran.AppTransactionId)
+
+	_, err = verifier.VerifyAndDecodeAppTransaction(ca.Sign(api.JWSAppTransactionDecodedPayload{AppAppleId: 5678, BundleId: "com.example.app", ReceiptType: api.Production}))
+	assert.ErrorIs(t, err, api.ErrInvalidAppAppleID)
+}
+
+func TestSignedDataVerifier_VerifyAndDecodeNotification(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+	verifier := newTestVerifier(t, ca, api.Production)
+
+	notification, err := verifier.VerifyAndDecodeNotification(ca.Sign(appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationType: appstore.NotificationTypeV2DidRenew,
+		NotificationUUID: "uuid",
+		Data:             appstore.SubscriptionNotificationV2Data{AppAppleID: 1234, BundleID: "com.example.app", Environment: "Production"},
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "uuid", notification.NotificationUUID)
+
+	notification, err = verifier.VerifyAndDecodeNotification(ca.Sign(appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationType: appstore.NotificationTypeV2RenewalExtension,
+		Subtype:          appstore.SubTypeV2Summary,
+		Summary:          appstore.SubscriptionNotificationV2Summary{AppAppleId: 1234, BundleID: "com.example.app", Environment: "Production"},
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, appstore.SubtypeV2(appstore.SubTypeV2Summary), notification.Subtype)
+
+	_, err = verifier.VerifyAndDecodeNotification(ca.Sign(appstore.SubscriptionNotificationV2DecodedPayload{
+		Data: appstore.SubscriptionNotificationV2Data{AppAppleID: 1234, BundleID: "com.example.app", Environment: "Sandbox"},
+	}))
+	assert.ErrorIs(t, err, api.ErrInvalidEnvironment)
+}
+
+func TestSignedDataVerifier_VerifyAndDecodeNotification_AppData(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+	verifier := newTestVerifier(t, ca, api.Production)
+
+	notification, err := verifier.VerifyAndDecodeNotification(ca.Sign(appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationUUID: "uuid",
+		AppData:          appstore.SubscriptionNotificationV2AppData{AppAppleID: 1234, BundleID: "com.example.app", Environment: "Production"},
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "uuid", notification.NotificationUUID)
+
+	_, err = verifier.VerifyAndDecodeNotification(ca.Sign(appstore.SubscriptionNotificationV2DecodedPayload{
+		AppData: appstore.SubscriptionNotificationV2AppData{AppAppleID: 1234, BundleID: "com.example.other", Environment: "Production"},
+	}))
+	assert.ErrorIs(t, err, api.ErrInvalidBundleID)
+
+	_, err = verifier.VerifyAndDecodeNotification(ca.Sign(appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationType: appstore.NotificationTypeV2Test,
+	}))
+	assert.ErrorIs(t, err, api.ErrInvalidBundleID)
+}
+
+func TestSignedDataVerifier_VerifyAndDecodeNotification_ExternalPurchaseToken(t *testing.T) {
+	t.Parallel()
+	ca := apitest.NewCA(nil)
+
+	notification, err := newTestVerifier(t, ca, api.Production).VerifyAndDecodeNotification(ca.Sign(appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationType:      appstore.NotificationTypeV2ExternalPurchaseToken,
+		Subtype:               appstore.SubTypeV2Unreported,
+		ExternalPurchaseToken: appstore.ExternalPurchaseToken{ExternalPurchaseId: "b2158121-7af9-49d4-9561-1f588205523e", AppAppleId: 1234, BundleId: "com.example.app"},
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "b2158121-7af9-49d4-9561-1f588205523e", notification.ExternalPurchaseToken.ExternalPurchaseId)
+
+	sandboxToken := appstore.SubscriptionNotificationV2DecodedPayload{
+		NotificationType:      appstore.NotificationTypeV2ExternalPurchaseToken,
+		Subtype:               appstore.SubTypeV2Unreported,
+		ExternalPurchaseToken: appstore.ExternalPurchaseToken{ExternalPurchaseId: "SANDBOX_b2158121-7af9-49d4-9561-1f588205523e", AppAppleId: 1234, BundleId: "com.example.app"},
+	}
+	_, err = newTestVerifier(t, ca, api.Sandbox).VerifyAndDecodeNotification(ca.Sign(sandboxToken))
+	assert.NoError(t, err)
+	_, err = newTestVerifier(t, ca, api.Production).VerifyAndDecodeNotification(ca.Sign(sandboxToken))
+	assert.ErrorIs(t, err, api.ErrInvalidEnvironment)
+}
+
+func TestSignedDataVerifier_VerificationTime(t *testing.T) {
+	t.Parallel()
+	notBefore := time.Now().Add(-48 * time.Hour)
+	ca := apitest.NewCA(&apitest.CAOptions{NotBefore: notBefore, NotAfter: notBefore.Add(24 * time.Hour)})
+	signed := ca.Sign(api.JWSTransaction{
+		TransactionID: "1",
+		BundleID:      "com.example.app",
+		Environment:   api.Sandbox,
+		SignedDate:    notBefore.Add(time.Hour).UnixMilli(),
+	})
+
+	newVerifier := func(mode appstore.VerificationTime, now func() time.Time) *api.SignedDataVerifier {
+		verifier, err := api.NewSignedDataVerifier(&api.SignedDataVerifierConfig{
+			BundleID:            "com.example.app",
+			Environment:         api.Sandbox,
+			RootCertificates:    ca.RootCertificates(),
+			VerificationTime:    mode,
+			VerificationNowFunc: now,
+		})
+		require.NoError(t, err)
+		return verifier
+	}
+
+	_, err := newVerifier(appstore.VerifyAtCurrentTime, nil).VerifyAndDecodeTransaction(signed)
+	assert.ErrorIs(t, err, api.ErrInvalidChain)
+
+	tran, err := newVerifier(appstore.VerifyAtSignedDate, nil).VerifyAndDecodeTransaction(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", tran.TransactionID)
+
+	_, err = newVerifier(appstore.VerifyAtCurrentTime, func() time.Time { return notBefore.Add(2 * time.Hour) }).VerifyAndDecodeTransaction(signed)
+	assert.NoError(t, err)
+}
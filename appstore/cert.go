@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // rootPEM is generated through `openssl x509 -inform der -in AppleRootCA-G3.cer -out apple_root.pem`
@@ -29,7 +30,55 @@ at+qIxUCMG1mihDK1A3UT82NQz60imOlM27jbdoXt2QfyFMm+YhidDkLF1vLUagM
 -----END CERTIFICATE-----
 `
 
-type Cert struct{}
+// VerificationTime selects the time the x5c certificate chain is verified at.
+type VerificationTime int
+
+const (
+	// VerifyAtCurrentTime verifies the chain at the current time.
+	VerifyAtCurrentTime VerificationTime = iota
+	// VerifyAtSignedDate verifies the chain at the signedDate of the payload, the current time is used when it is absent.
+	// Historical data keeps verifying after the signing certificate expires, as Apple's reference verifier does.
+	VerifyAtSignedDate
+)
+
+// Time returns the time to verify the chain of the JWS token at.
+func (v VerificationTime) Time(tokenStr string, now time.Time) time.Time {
+	if v != VerifyAtSignedDate {
+		return now
+	}
+
+	parts := strings.Split(tokenStr, ".")
+	if len(parts) != 3 {
+		return now
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return now
+	}
+	var claims struct {
+		SignedDate int64 `json:"signedDate"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil || claims.SignedDate <= 0 {
+		return now
+	}
+	return time.UnixMilli(claims.SignedDate)
+}
+
+type Cert struct {
+	// OCSP optionally checks the revocation status of the leaf and intermediate certificates.
+	OCSP *OCSPChecker
+	// VerificationTime selects the time the chain is verified at. Default is VerifyAtCurrentTime.
+	VerificationTime VerificationTime
+	// Now is the time source for the current time. Default is time.Now.
+	Now func() time.Time
+}
+
+func (c *Cert) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
 
 // ExtractCertByIndex extracts the certificate from the token string by index.
 func (c *Cert) extractCertByIndex(tokenStr string, index int) ([]byte, error) {
@@ -63,8 +112,8 @@ func (c *Cert) extractCertByIndex(tokenStr string, index int) ([]byte, error) {
 	return certByte, nil
 }
 
-// VerifyCert verifies the certificate chain.
-func (c *Cert) verifyCert(rootCert, intermediaCert, leafCert *x509.Certificate) error {
+// VerifyCert verifies the certificate chain at the given time.
+func (c *Cert) verifyCert(rootCert, intermediaCert, leafCert *x509.Certificate, at time.Time) error {
 	roots := x509.NewCertPool()
 	ok := roots.AppendCertsFromPEM([]byte(rootPEM))
 	if !ok {
@@ -77,17 +126,22 @@ func (c *Cert) verifyCert(rootCert, intermediaCert, leafCert *x509.Certificate)
 	opts := x509.VerifyOptions{
 		Roots:         roots,
 		Intermediates: intermedia,
+		CurrentTime:   at,
 	}
 	_, err := rootCert.Verify(opts)
 	if err != nil {
 		return err
 	}
 
-	_, err = leafCert.Verify(opts)
+	chains, err := leafCert.Verify(opts)
 	if err != nil {
 		return err
 	}
 
+	if c.OCSP != nil {
+		chain := chains[0]
+		return c.OCSP.CheckChain(chain[0], chain[1], chain[len(chain)-1])
+	}
 	return nil
 }
 
@@ -118,7 +172,7 @@ func (c *Cert) ExtractPublicKeyFromToken(token string) (*ecdsa.PublicKey, error)
 	if err != nil {
 		return nil, fmt.Errorf("appstore failed to parse leaf certificate")
 	}
-	if err = c.verifyCert(rootCert, intermediaCert, leafCert); err != nil {
+	if err = c.verifyCert(rootCert, intermediaCert, leafCert, c.VerificationTime.Time(token, c.now())); err != nil {
 		return nil, err
 	}
 
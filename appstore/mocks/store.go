@@ -12,10 +12,12 @@ package mocks
 import (
 	context "context"
 	io "io"
+	iter "iter"
 	url "net/url"
 	reflect "reflect"
 	time "time"
 
+	appstore "github.com/awa/go-iap/appstore"
 	api "github.com/awa/go-iap/appstore/api"
 	gomock "go.uber.org/mock/gomock"
 )
@@ -44,6 +46,21 @@ func (m *MockStoreAPIClient) EXPECT() *MockStoreAPIClientMockRecorder {
 	return m.recorder
 }
 
+// DecodeNotificationV2 mocks base method.
+func (m *MockStoreAPIClient) DecodeNotificationV2(signedPayload string) (*api.NotificationV2, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecodeNotificationV2", signedPayload)
+	ret0, _ := ret[0].(*api.NotificationV2)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DecodeNotificationV2 indicates an expected call of DecodeNotificationV2.
+func (mr *MockStoreAPIClientMockRecorder) DecodeNotificationV2(signedPayload any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecodeNotificationV2", reflect.TypeOf((*MockStoreAPIClient)(nil).DecodeNotificationV2), signedPayload)
+}
+
 // Do mocks base method.
 func (m *MockStoreAPIClient) Do(ctx context.Context, method, arg2 string, body io.Reader) (int, []byte, error) {
 	m.ctrl.T.Helper()
@@ -90,6 +107,37 @@ func (mr *MockStoreAPIClientMockRecorder) ExtendSubscriptionRenewalDateForAll(ct
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtendSubscriptionRenewalDateForAll", reflect.TypeOf((*MockStoreAPIClient)(nil).ExtendSubscriptionRenewalDateForAll), ctx, body)
 }
 
+// ExtendSubscriptionRenewalDateWithResponse mocks base method.
+func (m *MockStoreAPIClient) ExtendSubscriptionRenewalDateWithResponse(ctx context.Context, originalTransactionId string, body api.ExtendRenewalDateRequest) (int, *api.ExtendRenewalDateResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExtendSubscriptionRenewalDateWithResponse", ctx, originalTransactionId, body)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(*api.ExtendRenewalDateResponse)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ExtendSubscriptionRenewalDateWithResponse indicates an expected call of ExtendSubscriptionRenewalDateWithResponse.
+func (mr *MockStoreAPIClientMockRecorder) ExtendSubscriptionRenewalDateWithResponse(ctx, originalTransactionId, body any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtendSubscriptionRenewalDateWithResponse", reflect.TypeOf((*MockStoreAPIClient)(nil).ExtendSubscriptionRenewalDateWithResponse), ctx, originalTransactionId, body)
+}
+
+// FinishTransaction mocks base method.
+func (m *MockStoreAPIClient) FinishTransaction(ctx context.Context, transactionId string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishTransaction", ctx, transactionId)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinishTransaction indicates an expected call of FinishTransaction.
+func (mr *MockStoreAPIClientMockRecorder) FinishTransaction(ctx, transactionId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishTransaction", reflect.TypeOf((*MockStoreAPIClient)(nil).FinishTransaction), ctx, transactionId)
+}
+
 // GetALLSubscriptionStatuses mocks base method.
 func (m *MockStoreAPIClient) GetALLSubscriptionStatuses(ctx context.Context, originalTransactionId string, query *url.Values) (*api.StatusResponse, error) {
 	m.ctrl.T.Helper()
@@ -105,6 +153,21 @@ func (mr *MockStoreAPIClientMockRecorder) GetALLSubscriptionStatuses(ctx, origin
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetALLSubscriptionStatuses", reflect.TypeOf((*MockStoreAPIClient)(nil).GetALLSubscriptionStatuses), ctx, originalTransactionId, query)
 }
 
+// GetALLSubscriptionStatusesWithRequest mocks base method.
+func (m *MockStoreAPIClient) GetALLSubscriptionStatusesWithRequest(ctx context.Context, originalTransactionId string, req api.SubscriptionStatusesRequest) (*api.StatusResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetALLSubscriptionStatusesWithRequest", ctx, originalTransactionId, req)
+	ret0, _ := ret[0].(*api.StatusResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetALLSubscriptionStatusesWithRequest indicates an expected call of GetALLSubscriptionStatusesWithRequest.
+func (mr *MockStoreAPIClientMockRecorder) GetALLSubscriptionStatusesWithRequest(ctx, originalTransactionId, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetALLSubscriptionStatusesWithRequest", reflect.TypeOf((*MockStoreAPIClient)(nil).GetALLSubscriptionStatusesWithRequest), ctx, originalTransactionId, req)
+}
+
 // GetAllNotificationHistory mocks base method.
 func (m *MockStoreAPIClient) GetAllNotificationHistory(ctx context.Context, body api.NotificationHistoryRequest, duration time.Duration) ([]api.NotificationHistoryResponseItem, error) {
 	m.ctrl.T.Helper()
@@ -182,11 +245,11 @@ func (mr *MockStoreAPIClientMockRecorder) GetSubscriptionRenewalDataStatus(ctx,
 }
 
 // GetTestNotificationStatus mocks base method.
-func (m *MockStoreAPIClient) GetTestNotificationStatus(ctx context.Context, testNotificationToken string) (int, []byte, error) {
+func (m *MockStoreAPIClient) GetTestNotificationStatus(ctx context.Context, testNotificationToken string) (int, *api.CheckTestNotificationResponse, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetTestNotificationStatus", ctx, testNotificationToken)
 	ret0, _ := ret[0].(int)
-	ret1, _ := ret[1].([]byte)
+	ret1, _ := ret[1].(*api.CheckTestNotificationResponse)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
@@ -212,6 +275,21 @@ func (mr *MockStoreAPIClientMockRecorder) GetTransactionHistory(ctx, transaction
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionHistory", reflect.TypeOf((*MockStoreAPIClient)(nil).GetTransactionHistory), ctx, transactionId, query)
 }
 
+// GetTransactionHistoryWithRequest mocks base method.
+func (m *MockStoreAPIClient) GetTransactionHistoryWithRequest(ctx context.Context, transactionId string, req api.TransactionHistoryRequest) ([]*api.HistoryResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransactionHistoryWithRequest", ctx, transactionId, req)
+	ret0, _ := ret[0].([]*api.HistoryResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransactionHistoryWithRequest indicates an expected call of GetTransactionHistoryWithRequest.
+func (mr *MockStoreAPIClientMockRecorder) GetTransactionHistoryWithRequest(ctx, transactionId, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionHistoryWithRequest", reflect.TypeOf((*MockStoreAPIClient)(nil).GetTransactionHistoryWithRequest), ctx, transactionId, req)
+}
+
 // GetTransactionInfo mocks base method.
 func (m *MockStoreAPIClient) GetTransactionInfo(ctx context.Context, transactionId string) (*api.TransactionInfoResponse, error) {
 	m.ctrl.T.Helper()
@@ -242,6 +320,20 @@ func (mr *MockStoreAPIClientMockRecorder) LookupOrderID(ctx, orderId any) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LookupOrderID", reflect.TypeOf((*MockStoreAPIClient)(nil).LookupOrderID), ctx, orderId)
 }
 
+// NotificationHistoryIter mocks base method.
+func (m *MockStoreAPIClient) NotificationHistoryIter(ctx context.Context, body api.NotificationHistoryRequest, opts *api.PageOptions) iter.Seq2[api.NotificationHistoryResponseItem, error] {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotificationHistoryIter", ctx, body, opts)
+	ret0, _ := ret[0].(iter.Seq2[api.NotificationHistoryResponseItem, error])
+	return ret0
+}
+
+// NotificationHistoryIter indicates an expected call of NotificationHistoryIter.
+func (mr *MockStoreAPIClientMockRecorder) NotificationHistoryIter(ctx, body, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotificationHistoryIter", reflect.TypeOf((*MockStoreAPIClient)(nil).NotificationHistoryIter), ctx, body, opts)
+}
+
 // ParseJWSEncodeString mocks base method.
 func (m *MockStoreAPIClient) ParseJWSEncodeString(jwsEncode string) (any, error) {
 	m.ctrl.T.Helper()
@@ -257,6 +349,51 @@ func (mr *MockStoreAPIClientMockRecorder) ParseJWSEncodeString(jwsEncode any) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseJWSEncodeString", reflect.TypeOf((*MockStoreAPIClient)(nil).ParseJWSEncodeString), jwsEncode)
 }
 
+// ParseSignedAppTransaction mocks base method.
+func (m *MockStoreAPIClient) ParseSignedAppTransaction(appTransaction string) (*api.JWSAppTransactionDecodedPayload, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ParseSignedAppTransaction", appTransaction)
+	ret0, _ := ret[0].(*api.JWSAppTransactionDecodedPayload)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ParseSignedAppTransaction indicates an expected call of ParseSignedAppTransaction.
+func (mr *MockStoreAPIClientMockRecorder) ParseSignedAppTransaction(appTransaction any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseSignedAppTransaction", reflect.TypeOf((*MockStoreAPIClient)(nil).ParseSignedAppTransaction), appTransaction)
+}
+
+// ParseSignedNotification mocks base method.
+func (m *MockStoreAPIClient) ParseSignedNotification(signedPayload string) (*appstore.SubscriptionNotificationV2DecodedPayload, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ParseSignedNotification", signedPayload)
+	ret0, _ := ret[0].(*appstore.SubscriptionNotificationV2DecodedPayload)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ParseSignedNotification indicates an expected call of ParseSignedNotification.
+func (mr *MockStoreAPIClientMockRecorder) ParseSignedNotification(signedPayload any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseSignedNotification", reflect.TypeOf((*MockStoreAPIClient)(nil).ParseSignedNotification), signedPayload)
+}
+
+// ParseSignedRenewalInfo mocks base method.
+func (m *MockStoreAPIClient) ParseSignedRenewalInfo(renewalInfo string) (*api.JWSRenewalInfoDecodedPayload, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ParseSignedRenewalInfo", renewalInfo)
+	ret0, _ := ret[0].(*api.JWSRenewalInfoDecodedPayload)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ParseSignedRenewalInfo indicates an expected call of ParseSignedRenewalInfo.
+func (mr *MockStoreAPIClientMockRecorder) ParseSignedRenewalInfo(renewalInfo any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseSignedRenewalInfo", reflect.TypeOf((*MockStoreAPIClient)(nil).ParseSignedRenewalInfo), renewalInfo)
+}
+
 // ParseSignedTransaction mocks base method.
 func (m *MockStoreAPIClient) ParseSignedTransaction(transaction string) (*api.JWSTransaction, error) {
 	m.ctrl.T.Helper()
@@ -287,6 +424,35 @@ func (mr *MockStoreAPIClientMockRecorder) ParseSignedTransactions(transactions a
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseSignedTransactions", reflect.TypeOf((*MockStoreAPIClient)(nil).ParseSignedTransactions), transactions)
 }
 
+// ParseSignedTransactionsWithOptions mocks base method.
+func (m *MockStoreAPIClient) ParseSignedTransactionsWithOptions(transactions []string, opts api.ParseTransactionsOptions) (*api.ParsedTransactions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ParseSignedTransactionsWithOptions", transactions, opts)
+	ret0, _ := ret[0].(*api.ParsedTransactions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ParseSignedTransactionsWithOptions indicates an expected call of ParseSignedTransactionsWithOptions.
+func (mr *MockStoreAPIClientMockRecorder) ParseSignedTransactionsWithOptions(transactions, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseSignedTransactionsWithOptions", reflect.TypeOf((*MockStoreAPIClient)(nil).ParseSignedTransactionsWithOptions), transactions, opts)
+}
+
+// RefundHistoryIter mocks base method.
+func (m *MockStoreAPIClient) RefundHistoryIter(ctx context.Context, originalTransactionId string, opts *api.PageOptions) iter.Seq2[*api.JWSTransaction, error] {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundHistoryIter", ctx, originalTransactionId, opts)
+	ret0, _ := ret[0].(iter.Seq2[*api.JWSTransaction, error])
+	return ret0
+}
+
+// RefundHistoryIter indicates an expected call of RefundHistoryIter.
+func (mr *MockStoreAPIClientMockRecorder) RefundHistoryIter(ctx, originalTransactionId, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundHistoryIter", reflect.TypeOf((*MockStoreAPIClient)(nil).RefundHistoryIter), ctx, originalTransactionId, opts)
+}
+
 // SendConsumptionInfo mocks base method.
 func (m *MockStoreAPIClient) SendConsumptionInfo(ctx context.Context, originalTransactionId string, body api.ConsumptionRequestBody) (int, error) {
 	m.ctrl.T.Helper()
@@ -318,11 +484,11 @@ func (mr *MockStoreAPIClientMockRecorder) SendConsumptionInfoV2(ctx, transaction
 }
 
 // SendRequestTestNotification mocks base method.
-func (m *MockStoreAPIClient) SendRequestTestNotification(ctx context.Context) (int, []byte, error) {
+func (m *MockStoreAPIClient) SendRequestTestNotification(ctx context.Context) (int, *api.SendTestNotificationResponse, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "SendRequestTestNotification", ctx)
 	ret0, _ := ret[0].(int)
-	ret1, _ := ret[1].([]byte)
+	ret1, _ := ret[1].(*api.SendTestNotificationResponse)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
@@ -347,3 +513,17 @@ func (mr *MockStoreAPIClientMockRecorder) SetAppAccountToken(ctx, originalTransa
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAppAccountToken", reflect.TypeOf((*MockStoreAPIClient)(nil).SetAppAccountToken), ctx, originalTransactionId, body)
 }
+
+// TransactionHistoryIter mocks base method.
+func (m *MockStoreAPIClient) TransactionHistoryIter(ctx context.Context, transactionId string, query *url.Values, opts *api.PageOptions) iter.Seq2[*api.JWSTransaction, error] {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransactionHistoryIter", ctx, transactionId, query, opts)
+	ret0, _ := ret[0].(iter.Seq2[*api.JWSTransaction, error])
+	return ret0
+}
+
+// TransactionHistoryIter indicates an expected call of TransactionHistoryIter.
+func (mr *MockStoreAPIClientMockRecorder) TransactionHistoryIter(ctx, transactionId, query, opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransactionHistoryIter", reflect.TypeOf((*MockStoreAPIClient)(nil).TransactionHistoryIter), ctx, transactionId, query, opts)
+}
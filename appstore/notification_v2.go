@@ -1,6 +1,10 @@
 package appstore
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
 
 // NotificationTypeV2 is type
 type NotificationTypeV2 string
@@ -131,6 +135,18 @@ const (
 	OfferDiscountTypePayUpFront OfferDiscountType = "PAY_UP_FRONT"
 )
 
+// ConsumptionRequestReason is the customer-provided reason for a refund request.
+// https://developer.apple.com/documentation/appstoreservernotifications/consumptionrequestreason
+type ConsumptionRequestReason string
+
+const (
+	ConsumptionRequestReasonUnintendedPurchase      ConsumptionRequestReason = "UNINTENDED_PURCHASE"
+	ConsumptionRequestReasonFulfillmentIssue        ConsumptionRequestReason = "FULFILLMENT_ISSUE"
+	ConsumptionRequestReasonUnsatisfiedWithPurchase ConsumptionRequestReason = "UNSATISFIED_WITH_PURCHASE"
+	ConsumptionRequestReasonLegal                   ConsumptionRequestReason = "LEGAL"
+	ConsumptionRequestReasonOther                   ConsumptionRequestReason = "OTHER"
+)
+
 type (
 	// SubscriptionNotificationV2 is struct for
 	// https://developer.apple.com/documentation/appstoreservernotifications/responsebodyv2
@@ -147,13 +163,15 @@ type (
 	// SubscriptionNotificationV2DecodedPayload is struct
 	// https://developer.apple.com/documentation/appstoreservernotifications/responsebodyv2decodedpayload
 	SubscriptionNotificationV2DecodedPayload struct {
-		NotificationType    NotificationTypeV2                `json:"notificationType"`
-		Subtype             SubtypeV2                         `json:"subtype"`
-		NotificationUUID    string                            `json:"notificationUUID"`
-		NotificationVersion string                            `json:"version"`
-		SignedDate          int64                             `json:"signedDate"`
-		Data                SubscriptionNotificationV2Data    `json:"data,omitempty"`
-		Summary             SubscriptionNotificationV2Summary `json:"summary,omitempty"`
+		NotificationType      NotificationTypeV2                `json:"notificationType"`
+		Subtype               SubtypeV2                         `json:"subtype"`
+		NotificationUUID      string                            `json:"notificationUUID"`
+		NotificationVersion   string                            `json:"version"`
+		SignedDate            int64                             `json:"signedDate"`
+		Data                  SubscriptionNotificationV2Data    `json:"data,omitempty"`
+		Summary               SubscriptionNotificationV2Summary `json:"summary,omitempty"`
+		ExternalPurchaseToken ExternalPurchaseToken             `json:"externalPurchaseToken,omitempty"`
+		AppData               SubscriptionNotificationV2AppData `json:"appData,omitempty"`
 		jwt.RegisteredClaims
 	}
 
@@ -173,13 +191,33 @@ type (
 	// SubscriptionNotificationV2Data is struct
 	// https://developer.apple.com/documentation/appstoreservernotifications/data
 	SubscriptionNotificationV2Data struct {
-		AppAppleID            int                             `json:"appAppleId"`
-		BundleID              string                          `json:"bundleId"`
-		BundleVersion         string                          `json:"bundleVersion"`
-		Environment           string                          `json:"environment"`
-		SignedRenewalInfo     JWSRenewalInfo                  `json:"signedRenewalInfo"`
-		SignedTransactionInfo JWSTransaction                  `json:"signedTransactionInfo"`
-		Status                AutoRenewableSubscriptionStatus `json:"status"`
+		AppAppleID               int                             `json:"appAppleId"`
+		AppTransactionId         string                          `json:"appTransactionId,omitempty"`
+		BundleID                 string                          `json:"bundleId"`
+		BundleVersion            string                          `json:"bundleVersion"`
+		Environment              string                          `json:"environment"`
+		SignedRenewalInfo        JWSRenewalInfo                  `json:"signedRenewalInfo"`
+		SignedTransactionInfo    JWSTransaction                  `json:"signedTransactionInfo"`
+		Status                   AutoRenewableSubscriptionStatus `json:"status"`
+		ConsumptionRequestReason ConsumptionRequestReason        `json:"consumptionRequestReason,omitempty"`
+	}
+
+	// SubscriptionNotificationV2AppData is struct
+	// https://developer.apple.com/documentation/appstoreservernotifications/appdata
+	SubscriptionNotificationV2AppData struct {
+		AppAppleID               int64  `json:"appAppleId"`
+		BundleID                 string `json:"bundleId"`
+		Environment              string `json:"environment"`
+		SignedAppTransactionInfo string `json:"signedAppTransactionInfo"`
+	}
+
+	// ExternalPurchaseToken is struct of the EXTERNAL_PURCHASE_TOKEN notifications
+	// https://developer.apple.com/documentation/appstoreservernotifications/externalpurchasetoken
+	ExternalPurchaseToken struct {
+		ExternalPurchaseId string `json:"externalPurchaseId"`
+		TokenCreationDate  int64  `json:"tokenCreationDate"`
+		AppAppleId         int64  `json:"appAppleId"`
+		BundleId           string `json:"bundleId"`
 	}
 
 	// SubscriptionNotificationV2JWSDecodedHeader is struct
@@ -251,3 +289,31 @@ type (
 		jwt.RegisteredClaims
 	}
 )
+
+// sandboxExternalPurchaseIDPrefix prefixes the external purchase ID of the tokens created in the Sandbox environment.
+const sandboxExternalPurchaseIDPrefix = "SANDBOX"
+
+// Environment returns the environment the token was created in, the external purchase ID of the Sandbox tokens
+// starts with SANDBOX.
+func (t ExternalPurchaseToken) Environment() Environment {
+	if strings.HasPrefix(t.ExternalPurchaseId, sandboxExternalPurchaseIDPrefix) {
+		return Sandbox
+	}
+	return Production
+}
+
+// App returns the bundle ID, app Apple ID and environment of the app a notification is about, taken from the section
+// it carries: its data, summary, app data or external purchase token. ok is false when none of them is present.
+func (p *SubscriptionNotificationV2DecodedPayload) App() (bundleID string, appAppleID int64, environment Environment, ok bool) {
+	switch {
+	case p.Data.BundleID != "":
+		return p.Data.BundleID, int64(p.Data.AppAppleID), Environment(p.Data.Environment), true
+	case p.Summary.BundleID != "":
+		return p.Summary.BundleID, p.Summary.AppAppleId, Environment(p.Summary.Environment), true
+	case p.AppData.BundleID != "":
+		return p.AppData.BundleID, p.AppData.AppAppleID, Environment(p.AppData.Environment), true
+	case p.ExternalPurchaseToken.BundleId != "":
+		return p.ExternalPurchaseToken.BundleId, p.ExternalPurchaseToken.AppAppleId, p.ExternalPurchaseToken.Environment(), true
+	}
+	return "", 0, "", false
+}
@@ -0,0 +1,177 @@
+package appstore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// OCSPFailurePolicy decides whether a certificate is accepted when its revocation status cannot be determined,
+// for example when the OCSP responder is unreachable or answers with an unknown status.
+// A revoked certificate is always rejected.
+type OCSPFailurePolicy int
+
+const (
+	// OCSPFailOpen accepts the certificate when its revocation status cannot be determined.
+	OCSPFailOpen OCSPFailurePolicy = iota
+	// OCSPFailClosed rejects the certificate when its revocation status cannot be determined.
+	OCSPFailClosed
+)
+
+// list of OCSP errors
+var (
+	ErrCertificateRevoked = errors.New("appstore: certificate has been revoked")
+	ErrOCSPUnavailable    = errors.New("appstore: certificate revocation status is unavailable")
+)
+
+// maxOCSPResponseSize bounds the size of an OCSP response read from a responder.
+const maxOCSPResponseSize = 1 << 20
+
+var defaultOCSPHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// OCSPChecker checks the revocation status of the x5c certificates with their OCSP responders.
+// Good responses are cached until their nextUpdate, a response past its nextUpdate is stale and handled per the
+// FailurePolicy. An OCSPChecker is safe for concurrent use.
+type OCSPChecker struct {
+	HTTPClient    *http.Client      // Client used to reach the OCSP responders. Default has a 10 seconds timeout.
+	FailurePolicy OCSPFailurePolicy // Default is OCSPFailOpen.
+	Now           func() time.Time  // Time source used for the cache. Default is time.Now.
+
+	mu    sync.Mutex
+	cache map[string]time.Time // expiry of the good status per issuer and serial number
+}
+
+// NewOCSPChecker creates an OCSPChecker, httpClient may be nil.
+func NewOCSPChecker(httpClient *http.Client, policy OCSPFailurePolicy) *OCSPChecker {
+	return &OCSPChecker{
+		HTTPClient:    httpClient,
+		FailurePolicy: policy,
+	}
+}
+
+// CheckChain checks the revocation status of the leaf and intermediate certificates of a verified chain.
+func (o *OCSPChecker) CheckChain(leaf, intermediate, root *x509.Certificate) error {
+	if err := o.Check(leaf, intermediate); err != nil {
+		return err
+	}
+	return o.Check(intermediate, root)
+}
+
+// Check checks the revocation status of cert issued by issuer.
+func (o *OCSPChecker) Check(cert, issuer *x509.Certificate) error {
+	key := hex.EncodeToString(issuer.RawSubjectPublicKeyInfo) + ":" + cert.SerialNumber.String()
+	now := o.now()
+	if o.cached(key, now) {
+		return nil
+	}
+
+	rsp, err := o.query(cert, issuer)
+	if err != nil {
+		return o.fail(cert, err)
+	}
+
+	switch rsp.Status {
+	case ocsp.Good:
+		if rsp.NextUpdate.IsZero() {
+			return nil
+		}
+		if !rsp.NextUpdate.After(now) {
+			return o.fail(cert, fmt.Errorf("responder returned a stale response, next update was %v", rsp.NextUpdate))
+		}
+		o.store(key, rsp.NextUpdate)
+		return nil
+	case ocsp.Revoked:
+		return fmt.Errorf("%w: %q at %v", ErrCertificateRevoked, cert.Subject.CommonName, rsp.RevokedAt)
+	default:
+		return o.fail(cert, errors.New("responder returned unknown status"))
+	}
+}
+
+func (o *OCSPChecker) query(cert, issuer *x509.Certificate) (*ocsp.Response, error) {
+	if len(cert.OCSPServer) == 0 {
+		return nil, errors.New("certificate has no OCSP responder")
+	}
+
+	reqBody, err := ocsp.CreateRequest(cert, issuer, &ocsp.RequestOptions{Hash: crypto.SHA256})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, cert.OCSPServer[0], bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	resp, err := o.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responder returned http status code %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return ocsp.ParseResponseForCert(body, cert, issuer)
+}
+
+func (o *OCSPChecker) fail(cert *x509.Certificate, err error) error {
+	if o.FailurePolicy == OCSPFailOpen {
+		return nil
+	}
+	return fmt.Errorf("%w: %q: %w", ErrOCSPUnavailable, cert.Subject.CommonName, err)
+}
+
+func (o *OCSPChecker) cached(key string, now time.Time) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	expiry, ok := o.cache[key]
+	if !ok {
+		return false
+	}
+	if !now.Before(expiry) {
+		delete(o.cache, key)
+		return false
+	}
+	return true
+}
+
+func (o *OCSPChecker) store(key string, expiry time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.cache == nil {
+		o.cache = make(map[string]time.Time)
+	}
+	o.cache[key] = expiry
+}
+
+func (o *OCSPChecker) httpClient() *http.Client {
+	if o.HTTPClient != nil {
+		return o.HTTPClient
+	}
+	return defaultOCSPHTTPClient
+}
+
+func (o *OCSPChecker) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,195 @@
+package appstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+type testOCSPResponder struct {
+	issuer     *x509.Certificate
+	issuerKey  *ecdsa.PrivateKey
+	status     int
+	nextUpdate time.Duration // The delay of the nextUpdate of the responses, default is an hour
+	calls      atomic.Int32
+	offset     atomic.Int64 // The offset of the clock of the responder from the current time, in nanoseconds
+}
+
+// now returns the current time of the responder, also used as the clock of the checkers.
+func (r *testOCSPResponder) now() time.Time {
+	return time.Now().Add(time.Duration(r.offset.Load()))
+}
+
+func (r *testOCSPResponder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.calls.Add(1)
+	body, _ := io.ReadAll(req.Body)
+	ocspReq, err := ocsp.ParseRequest(body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	now := r.now()
+	nextUpdate := r.nextUpdate
+	if nextUpdate == 0 {
+		nextUpdate = time.Hour
+	}
+	rsp, err := ocsp.CreateResponse(r.issuer, r.issuer, ocsp.Response{
+		Status:       r.status,
+		SerialNumber: ocspReq.SerialNumber,
+		ThisUpdate:   now.Add(-2 * time.Minute),
+		NextUpdate:   now.Add(nextUpdate),
+		RevokedAt:    now.Add(-time.Minute),
+	}, r.issuerKey)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	_, _ = w.Write(rsp)
+}
+
+// newOCSPTestChain issues a CA and a leaf whose OCSP responder is responderURL.
+func newOCSPTestChain(t *testing.T, responderURL string) (leaf, issuer *x509.Certificate, issuerKey *ecdsa.PrivateKey) {
+	t.Helper()
+	issuerKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, issuerTemplate, issuerTemplate, &issuerKey.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test Leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		OCSPServer:   []string{responderURL},
+	}, issuer, &leafKey.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf, issuer, issuerKey
+}
+
+// checkPolicies checks that an undetermined revocation status is accepted when failing open and rejected when failing closed.
+func checkPolicies(t *testing.T, httpClient *http.Client, leaf, issuer *x509.Certificate) {
+	t.Helper()
+	if err := NewOCSPChecker(httpClient, OCSPFailOpen).Check(leaf, issuer); err != nil {
+		t.Errorf("expected the certificate to be accepted when failing open, got %v", err)
+	}
+	if err := NewOCSPChecker(httpClient, OCSPFailClosed).Check(leaf, issuer); !errors.Is(err, ErrOCSPUnavailable) {
+		t.Errorf("expected ErrOCSPUnavailable when failing closed, got %v", err)
+	}
+}
+
+func TestOCSPChecker_Check(t *testing.T) {
+	t.Parallel()
+
+	t.Run("good status is cached until nextUpdate", func(t *testing.T) {
+		t.Parallel()
+		responder := &testOCSPResponder{status: ocsp.Good}
+		srv := httptest.NewServer(responder)
+		defer srv.Close()
+		leaf, issuer, issuerKey := newOCSPTestChain(t, srv.URL)
+		responder.issuer, responder.issuerKey = issuer, issuerKey
+
+		checker := NewOCSPChecker(srv.Client(), OCSPFailClosed)
+		checker.Now = responder.now
+
+		for range 2 {
+			if err := checker.Check(leaf, issuer); err != nil {
+				t.Fatalf("expected a good status, got %v", err)
+			}
+		}
+		if calls := responder.calls.Load(); calls != 1 {
+			t.Errorf("expected the status to be cached, the responder was called %d times", calls)
+		}
+
+		responder.offset.Store(int64(2 * time.Hour))
+		if err := checker.Check(leaf, issuer); err != nil {
+			t.Fatalf("expected a good status, got %v", err)
+		}
+		if calls := responder.calls.Load(); calls != 2 {
+			t.Errorf("expected the status to be queried again after nextUpdate, the responder was called %d times", calls)
+		}
+	})
+
+	t.Run("stale good status follows the policy", func(t *testing.T) {
+		t.Parallel()
+		responder := &testOCSPResponder{status: ocsp.Good, nextUpdate: -time.Minute}
+		srv := httptest.NewServer(responder)
+		defer srv.Close()
+		leaf, issuer, issuerKey := newOCSPTestChain(t, srv.URL)
+		responder.issuer, responder.issuerKey = issuer, issuerKey
+
+		checkPolicies(t, srv.Client(), leaf, issuer)
+	})
+
+	t.Run("revoked status is rejected whatever the policy", func(t *testing.T) {
+		t.Parallel()
+		responder := &testOCSPResponder{status: ocsp.Revoked}
+		srv := httptest.NewServer(responder)
+		defer srv.Close()
+		leaf, issuer, issuerKey := newOCSPTestChain(t, srv.URL)
+		responder.issuer, responder.issuerKey = issuer, issuerKey
+
+		if err := NewOCSPChecker(srv.Client(), OCSPFailOpen).Check(leaf, issuer); !errors.Is(err, ErrCertificateRevoked) {
+			t.Errorf("expected ErrCertificateRevoked, got %v", err)
+		}
+	})
+
+	t.Run("unknown status follows the policy", func(t *testing.T) {
+		t.Parallel()
+		responder := &testOCSPResponder{status: ocsp.Unknown}
+		srv := httptest.NewServer(responder)
+		defer srv.Close()
+		leaf, issuer, issuerKey := newOCSPTestChain(t, srv.URL)
+		responder.issuer, responder.issuerKey = issuer, issuerKey
+
+		checkPolicies(t, srv.Client(), leaf, issuer)
+	})
+
+	t.Run("unreachable responder follows the policy", func(t *testing.T) {
+		t.Parallel()
+		srv := httptest.NewServer(http.NotFoundHandler())
+		leaf, issuer, _ := newOCSPTestChain(t, srv.URL)
+		srv.Close()
+
+		checkPolicies(t, nil, leaf, issuer)
+	})
+}
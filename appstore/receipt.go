@@ -0,0 +1,616 @@
+package appstore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	_ "crypto/sha1" // register the digest of legacy receipt signatures
+	_ "crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// list of receipt errors
+var (
+	ErrReceiptInvalidPKCS7     = errors.New("appstore: receipt is not a valid PKCS#7 signed data container")
+	ErrReceiptInvalidPayload   = errors.New("appstore: receipt payload is malformed")
+	ErrReceiptInvalidSignature = errors.New("appstore: receipt signature is invalid")
+)
+
+var (
+	oidPKCS7Data          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS7SignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidPKCS9MessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidDigestSHA1         = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSHA256       = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+
+	// oidAppleReceiptSigner marks the certificates Apple signs receipts with, unlike the developer certificates
+	// issued under the same root.
+	oidAppleReceiptSigner = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+)
+
+// Receipt attribute types.
+// Doc: https://developer.apple.com/library/archive/releasenotes/General/ValidateAppStoreReceipt/Chapters/ReceiptFields.html
+const (
+	receiptAttrReceiptType                = 0
+	receiptAttrAppItemID                  = 1
+	receiptAttrBundleID                   = 2
+	receiptAttrApplicationVersion         = 3
+	receiptAttrCreationDate               = 12
+	receiptAttrInApp                      = 17
+	receiptAttrOriginalPurchaseDate       = 18
+	receiptAttrOriginalApplicationVersion = 19
+	receiptAttrExpirationDate             = 21
+	receiptAttrPreorderDate               = 32
+
+	inAppAttrQuantity              = 1701
+	inAppAttrProductID             = 1702
+	inAppAttrTransactionID         = 1703
+	inAppAttrPurchaseDate          = 1704
+	inAppAttrOriginalTransactionID = 1705
+	inAppAttrOriginalPurchaseDate  = 1706
+	inAppAttrExpiresDate           = 1708
+	inAppAttrWebOrderLineItemID    = 1711
+	inAppAttrCancellationDate      = 1712
+	inAppAttrIsTrialPeriod         = 1713
+	inAppAttrIsInIntroOfferPeriod  = 1719
+	inAppAttrPromotionalOfferID    = 1721
+)
+
+type (
+	pkcs7ContentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+	}
+
+	pkcs7SignedData struct {
+		Version          int
+		DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+		ContentInfo      pkcs7ContentInfo
+		Certificates     asn1.RawValue     `asn1:"optional,tag:0"`
+		CRLs             asn1.RawValue     `asn1:"optional,tag:1"`
+		SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+	}
+
+	pkcs7IssuerAndSerial struct {
+		Issuer       asn1.RawValue
+		SerialNumber *big.Int
+	}
+
+	pkcs7SignerInfo struct {
+		Version                   int
+		IssuerAndSerialNumber     pkcs7IssuerAndSerial
+		DigestAlgorithm           pkix.AlgorithmIdentifier
+		AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+		DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+		EncryptedDigest           []byte
+		UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+	}
+
+	pkcs7Attribute struct {
+		Type   asn1.ObjectIdentifier
+		Values asn1.RawValue `asn1:"set"`
+	}
+
+	receiptAttribute struct {
+		Type    int
+		Version int
+		Value   []byte
+	}
+)
+
+// ReceiptVerification configures the verification of the receipt signature by ParseReceipt.
+type ReceiptVerification struct {
+	// Roots are the trusted root certificates, such as the Apple Inc. Root certificate
+	// from https://www.apple.com/certificateauthority/. Required.
+	Roots *x509.CertPool
+	// CurrentTime is the time the signing certificate is verified at. Default is the receipt creation date,
+	// which is only used once the signature of the receipt is verified.
+	CurrentTime time.Time
+}
+
+// ParseReceipt decodes a base64 app receipt locally, without calling the verifyReceipt endpoint.
+// It unpacks the PKCS#7 container and maps the ASN.1 receipt attributes onto Receipt and InApp.
+// The receipt signature and its certificate chain are verified only when verification is not nil.
+// Fields only returned by verifyReceipt, such as the subscription renewal info, are left empty.
+// Doc: https://developer.apple.com/library/archive/releasenotes/General/ValidateAppStoreReceipt/Chapters/ValidateLocally.html
+func ParseReceipt(receiptData string, verification *ReceiptVerification) (*Receipt, error) {
+	b, err := base64.StdEncoding.DecodeString(receiptData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReceiptInvalidPKCS7, err)
+	}
+	return ParseReceiptBytes(b, verification)
+}
+
+// ParseReceiptBytes is like ParseReceipt for a receipt that is already base64 decoded.
+func ParseReceiptBytes(b []byte, verification *ReceiptVerification) (*Receipt, error) {
+	der, err := berToDER(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReceiptInvalidPKCS7, err)
+	}
+
+	var info pkcs7ContentInfo
+	if _, err = asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReceiptInvalidPKCS7, err)
+	}
+	if !info.ContentType.Equal(oidPKCS7SignedData) {
+		return nil, fmt.Errorf("%w: unexpected content type %v", ErrReceiptInvalidPKCS7, info.ContentType)
+	}
+	var sd pkcs7SignedData
+	if _, err = asn1.Unmarshal(info.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReceiptInvalidPKCS7, err)
+	}
+	if !sd.ContentInfo.ContentType.Equal(oidPKCS7Data) {
+		return nil, fmt.Errorf("%w: unexpected content type %v", ErrReceiptInvalidPKCS7, sd.ContentInfo.ContentType)
+	}
+	var content []byte
+	if _, err = asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReceiptInvalidPKCS7, err)
+	}
+
+	receipt, err := parseReceiptPayload(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if verification != nil {
+		at := func() time.Time {
+			if !verification.CurrentTime.IsZero() {
+				return verification.CurrentTime
+			}
+			return parseReceiptMillis(receipt.CreationDateMS)
+		}
+		if err = sd.verify(content, verification.Roots, at); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrReceiptInvalidSignature, err)
+		}
+	}
+	return receipt, nil
+}
+
+// TransactionIDs returns the transaction IDs of the in-app purchases of the receipt.
+func (r *Receipt) TransactionIDs() []string {
+	ids := make([]string, 0, len(r.InApp))
+	for _, inApp := range r.InApp {
+		ids = append(ids, inApp.TransactionID)
+	}
+	return ids
+}
+
+// OriginalTransactionIDs returns the distinct original transaction IDs of the in-app purchases of the receipt,
+// which identify the purchases in the App Store Server API.
+func (r *Receipt) OriginalTransactionIDs() []string {
+	seen := make(map[NumericString]bool, len(r.InApp))
+	ids := make([]string, 0, len(r.InApp))
+	for _, inApp := range r.InApp {
+		if inApp.OriginalTransactionID == "" || seen[inApp.OriginalTransactionID] {
+			continue
+		}
+		seen[inApp.OriginalTransactionID] = true
+		ids = append(ids, string(inApp.OriginalTransactionID))
+	}
+	return ids
+}
+
+func parseReceiptPayload(content []byte) (*Receipt, error) {
+	attrs, err := parseReceiptAttributes(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReceiptInvalidPayload, err)
+	}
+
+	r := &Receipt{}
+	for _, attr := range attrs {
+		switch attr.Type {
+		case receiptAttrReceiptType:
+			err = attr.string(&r.ReceiptType)
+		case receiptAttrAppItemID:
+			var id int64
+			if err = attr.int(&id); err == nil {
+				r.AdamID = id
+				r.AppItemID = NumericString(strconv.FormatInt(id, 10))
+			}
+		case receiptAttrBundleID:
+			err = attr.string(&r.BundleID)
+		case receiptAttrApplicationVersion:
+			err = attr.string(&r.ApplicationVersion)
+		case receiptAttrOriginalApplicationVersion:
+			err = attr.string(&r.OriginalApplicationVersion)
+		case receiptAttrCreationDate:
+			err = attr.date(&r.CreationDate, &r.CreationDateMS, &r.CreationDatePST)
+		case receiptAttrOriginalPurchaseDate:
+			err = attr.date(&r.OriginalPurchaseDate.OriginalPurchaseDate, &r.OriginalPurchaseDate.OriginalPurchaseDateMS, &r.OriginalPurchaseDate.OriginalPurchaseDatePST)
+		case receiptAttrExpirationDate:
+			err = attr.date(&r.ExpiresDate.ExpiresDate, &r.ExpiresDate.ExpiresDateMS, &r.ExpiresDate.ExpiresDatePST)
+		case receiptAttrPreorderDate:
+			err = attr.date(&r.PreorderDate.PreorderDate, &r.PreorderDateMS, &r.PreorderDatePST)
+		case receiptAttrInApp:
+			var inApp *InApp
+			if inApp, err = parseInAppPayload(attr.Value); err == nil {
+				r.InApp = append(r.InApp, *inApp)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: attribute %d: %w", ErrReceiptInvalidPayload, attr.Type, err)
+		}
+	}
+	return r, nil
+}
+
+func parseInAppPayload(content []byte) (*InApp, error) {
+	attrs, err := parseReceiptAttributes(content)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &InApp{}
+	for _, attr := range attrs {
+		switch attr.Type {
+		case inAppAttrQuantity:
+			err = attr.intString(&r.Quantity)
+		case inAppAttrProductID:
+			err = attr.string(&r.ProductID)
+		case inAppAttrTransactionID:
+			err = attr.string(&r.TransactionID)
+		case inAppAttrOriginalTransactionID:
+			var id string
+			if err = attr.string(&id); err == nil {
+				r.OriginalTransactionID = NumericString(id)
+			}
+		case inAppAttrWebOrderLineItemID:
+			err = attr.intString(&r.WebOrderLineItemID)
+		case inAppAttrPromotionalOfferID:
+			err = attr.string(&r.PromotionalOfferID)
+		case inAppAttrIsTrialPeriod:
+			err = attr.bool(&r.IsTrialPeriod)
+		case inAppAttrIsInIntroOfferPeriod:
+			err = attr.bool(&r.IsInIntroOfferPeriod)
+		case inAppAttrPurchaseDate:
+			err = attr.date(&r.PurchaseDate.PurchaseDate, &r.PurchaseDateMS, &r.PurchaseDatePST)
+		case inAppAttrOriginalPurchaseDate:
+			err = attr.date(&r.OriginalPurchaseDate.OriginalPurchaseDate, &r.OriginalPurchaseDateMS, &r.OriginalPurchaseDatePST)
+		case inAppAttrExpiresDate:
+			err = attr.date(&r.ExpiresDate.ExpiresDate, &r.ExpiresDateMS, &r.ExpiresDatePST)
+		case inAppAttrCancellationDate:
+			err = attr.date(&r.CancellationDate.CancellationDate, &r.CancellationDateMS, &r.CancellationDatePST)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("in-app attribute %d: %w", attr.Type, err)
+		}
+	}
+	return r, nil
+}
+
+func parseReceiptAttributes(content []byte) ([]receiptAttribute, error) {
+	var attrs []receiptAttribute
+	rest, err := asn1.UnmarshalWithParams(content, &attrs, "set")
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after receipt attributes")
+	}
+	return attrs, nil
+}
+
+func (a receiptAttribute) string(v *string) error {
+	_, err := asn1.Unmarshal(a.Value, v)
+	return err
+}
+
+func (a receiptAttribute) int(v *int64) error {
+	_, err := asn1.Unmarshal(a.Value, v)
+	return err
+}
+
+func (a receiptAttribute) intString(v *string) error {
+	var n int64
+	if err := a.int(&n); err != nil {
+		return err
+	}
+	*v = strconv.FormatInt(n, 10)
+	return nil
+}
+
+func (a receiptAttribute) bool(v *string) error {
+	var n int64
+	if err := a.int(&n); err != nil {
+		return err
+	}
+	*v = strconv.FormatBool(n != 0)
+	return nil
+}
+
+// date decodes an RFC 3339 date into the formats returned by verifyReceipt, an empty date is left unset.
+func (a receiptAttribute) date(date, ms, pst *string) error {
+	var s string
+	if err := a.string(&s); err != nil {
+		return err
+	}
+	if s == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return err
+	}
+
+	*date = t.UTC().Format(receiptDateLayout) + " Etc/GMT"
+	*ms = strconv.FormatInt(t.UnixMilli(), 10)
+	if loc, err := pacificLocation(); err == nil {
+		*pst = t.In(loc).Format(receiptDateLayout) + " America/Los_Angeles"
+	}
+	return nil
+}
+
+const receiptDateLayout = "2006-01-02 15:04:05"
+
+var pacificLocation = sync.OnceValues(func() (*time.Location, error) {
+	return time.LoadLocation("America/Los_Angeles")
+})
+
+func parseReceiptMillis(ms string) time.Time {
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+	return time.UnixMilli(n)
+}
+
+// verify checks that the signer certificate is an Apple receipt signer and signs content, then verifies the chain
+// of the signer to roots at the time returned by at, which is only called once the signature is checked.
+func (sd *pkcs7SignedData) verify(content []byte, roots *x509.CertPool, at func() time.Time) error {
+	if roots == nil {
+		return errors.New("no trusted root certificates")
+	}
+	if len(sd.SignerInfos) != 1 {
+		return fmt.Errorf("expected 1 signer, got %d", len(sd.SignerInfos))
+	}
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return err
+	}
+
+	si := sd.SignerInfos[0]
+	var signer *x509.Certificate
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs {
+		if cert.SerialNumber.Cmp(si.IssuerAndSerialNumber.SerialNumber) == 0 && bytes.Equal(cert.RawIssuer, si.IssuerAndSerialNumber.Issuer.FullBytes) {
+			signer = cert
+			continue
+		}
+		intermediates.AddCert(cert)
+	}
+	if signer == nil {
+		return errors.New("signer certificate not found")
+	}
+	if !hasReceiptSignerExtension(signer) {
+		return fmt.Errorf("signer certificate %q is not an Apple receipt signer", signer.Subject.CommonName)
+	}
+
+	if err = si.verifySignature(content, signer); err != nil {
+		return err
+	}
+	_, err = signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+func hasReceiptSignerExtension(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidAppleReceiptSigner) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySignature checks the signature of content by the public key of signer.
+func (si *pkcs7SignerInfo) verifySignature(content []byte, signer *x509.Certificate) error {
+	hash, err := digestHash(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	signed := content
+	if len(si.AuthenticatedAttributes.Bytes) > 0 {
+		if signed, err = si.signedAttributes(content, hash); err != nil {
+			return err
+		}
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch pub := signer.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, hash, digest, si.EncryptedDigest)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, si.EncryptedDigest) {
+			return errors.New("ecdsa verification failure")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// signedAttributes checks the message digest attribute against content and returns the attributes the signature covers.
+func (si *pkcs7SignerInfo) signedAttributes(content []byte, hash crypto.Hash) ([]byte, error) {
+	// The signature covers the DER encoding of the attributes as a SET OF, not as the implicit [0].
+	signed := append([]byte{0x31}, si.AuthenticatedAttributes.FullBytes[1:]...)
+	var attrs []pkcs7Attribute
+	if _, err := asn1.UnmarshalWithParams(signed, &attrs, "set"); err != nil {
+		return nil, err
+	}
+
+	h := hash.New()
+	h.Write(content)
+	want := h.Sum(nil)
+	for _, attr := range attrs {
+		if !attr.Type.Equal(oidPKCS9MessageDigest) {
+			continue
+		}
+		var got []byte
+		if _, err := asn1.Unmarshal(attr.Values.Bytes, &got); err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(got, want) {
+			return nil, errors.New("message digest mismatch")
+		}
+		return signed, nil
+	}
+	return nil, errors.New("message digest attribute not found")
+}
+
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidDigestSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidDigestSHA256):
+		return crypto.SHA256, nil
+	default:
+		return 0, fmt.Errorf("unsupported digest algorithm %v", oid)
+	}
+}
+
+// berToDER converts the indefinite lengths and constructed octet strings that BER allows, and receipts may use,
+// into the definite lengths and primitive octet strings that encoding/asn1 requires.
+func berToDER(b []byte) ([]byte, error) {
+	out, rest, err := berElementToDER(b, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after ASN.1 element")
+	}
+	return out, nil
+}
+
+// maxBERDepth bounds the nesting of the ASN.1 elements converted by berToDER.
+const maxBERDepth = 32
+
+func berElementToDER(b []byte, depth int) (der, rest []byte, err error) {
+	if depth > maxBERDepth {
+		return nil, nil, errors.New("ASN.1 nesting too deep")
+	}
+	if len(b) < 2 {
+		return nil, nil, errors.New("truncated ASN.1 element")
+	}
+
+	// identifier octets
+	tagLen := 1
+	if b[0]&0x1f == 0x1f {
+		for tagLen < len(b) && b[tagLen]&0x80 != 0 {
+			tagLen++
+		}
+		tagLen++
+		if tagLen >= len(b) {
+			return nil, nil, errors.New("truncated ASN.1 tag")
+		}
+	}
+	tag := b[:tagLen]
+	constructed := b[0]&0x20 != 0
+	b = b[tagLen:]
+
+	// length octets
+	indefinite := false
+	length := 0
+	switch {
+	case b[0] == 0x80:
+		indefinite = true
+		b = b[1:]
+	case b[0]&0x80 == 0:
+		length = int(b[0])
+		b = b[1:]
+	default:
+		n := int(b[0] & 0x7f)
+		if n > 4 || len(b) < 1+n {
+			return nil, nil, errors.New("invalid ASN.1 length")
+		}
+		for _, c := range b[1 : 1+n] {
+			length = length<<8 | int(c)
+		}
+		b = b[1+n:]
+	}
+
+	if !constructed {
+		if indefinite {
+			return nil, nil, errors.New("indefinite length primitive ASN.1 element")
+		}
+		if length > len(b) {
+			return nil, nil, errors.New("truncated ASN.1 element")
+		}
+		return encodeDER(tag, b[:length]), b[length:], nil
+	}
+
+	var body []byte
+	if indefinite {
+		body = b
+	} else {
+		if length > len(b) {
+			return nil, nil, errors.New("truncated ASN.1 element")
+		}
+		body, rest = b[:length], b[length:]
+	}
+
+	var children [][]byte
+	for {
+		if indefinite {
+			if len(body) < 2 {
+				return nil, nil, errors.New("missing end-of-contents")
+			}
+			if body[0] == 0 && body[1] == 0 {
+				rest = body[2:]
+				break
+			}
+		} else if len(body) == 0 {
+			break
+		}
+		var child []byte
+		if child, body, err = berElementToDER(body, depth+1); err != nil {
+			return nil, nil, err
+		}
+		children = append(children, child)
+	}
+
+	// a constructed OCTET STRING is the concatenation of its primitive segments
+	if len(tag) == 1 && tag[0] == 0x24 {
+		var value []byte
+		for _, child := range children {
+			var segment []byte
+			if _, err = asn1.Unmarshal(child, &segment); err != nil {
+				return nil, nil, err
+			}
+			value = append(value, segment...)
+		}
+		return encodeDER([]byte{0x04}, value), rest, nil
+	}
+	return encodeDER(tag, bytes.Join(children, nil)), rest, nil
+}
+
+func encodeDER(tag, value []byte) []byte {
+	out := append([]byte{}, tag...)
+	switch n := len(value); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	default:
+		var lenBytes []byte
+		for ; n > 0; n >>= 8 {
+			lenBytes = append([]byte{byte(n)}, lenBytes...)
+		}
+		out = append(out, 0x80|byte(len(lenBytes)))
+		out = append(out, lenBytes...)
+	}
+	return append(out, value...)
+}
@@ -0,0 +1,405 @@
+package appstore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var oidPKCS9ContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+
+// testReceiptSigner issues a root, an intermediate and a signing certificate shaped like the receipt signing chain.
+type testReceiptSigner struct {
+	root            *x509.Certificate
+	intermediate    *x509.Certificate
+	intermediateKey *rsa.PrivateKey
+	leaf            *x509.Certificate
+	leafKey         *rsa.PrivateKey
+}
+
+var (
+	testReceiptNotBefore = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	testReceiptNotAfter  = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func issueTestCertificate(t *testing.T, template, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func newTestReceiptSigner(t *testing.T) *testReceiptSigner {
+	t.Helper()
+	root, rootKey := issueTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "Test Root"},
+		NotBefore: testReceiptNotBefore, NotAfter: testReceiptNotAfter,
+		KeyUsage: x509.KeyUsageCertSign, BasicConstraintsValid: true, IsCA: true,
+	}, nil, nil)
+	intermediate, intermediateKey := issueTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "Test WWDR"},
+		NotBefore: testReceiptNotBefore, NotAfter: testReceiptNotAfter,
+		KeyUsage: x509.KeyUsageCertSign, BasicConstraintsValid: true, IsCA: true,
+	}, root, rootKey)
+	leaf, leafKey := issueTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "Test Receipt Signing"},
+		NotBefore: testReceiptNotBefore, NotAfter: testReceiptNotAfter,
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: []pkix.Extension{{Id: oidAppleReceiptSigner, Value: asn1.NullBytes}},
+	}, intermediate, intermediateKey)
+	return &testReceiptSigner{root: root, intermediate: intermediate, intermediateKey: intermediateKey, leaf: leaf, leafKey: leafKey}
+}
+
+// developer returns a signer under the same chain as s whose certificate, like a developer certificate, isn't marked
+// as a receipt signer.
+func (s *testReceiptSigner) developer(t *testing.T) *testReceiptSigner {
+	t.Helper()
+	leaf, leafKey := issueTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4), Subject: pkix.Name{CommonName: "Test Developer"},
+		NotBefore: testReceiptNotBefore, NotAfter: testReceiptNotAfter,
+		KeyUsage: x509.KeyUsageDigitalSignature,
+	}, s.intermediate, s.intermediateKey)
+	return &testReceiptSigner{root: s.root, intermediate: s.intermediate, intermediateKey: s.intermediateKey, leaf: leaf, leafKey: leafKey}
+}
+
+func (s *testReceiptSigner) roots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.root)
+	return pool
+}
+
+func marshalReceiptAttributes(t *testing.T, attrs ...receiptAttribute) []byte {
+	t.Helper()
+	b, err := asn1.MarshalWithParams(attrs, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func receiptAttr(t *testing.T, typ int, value interface{}, params string) receiptAttribute {
+	t.Helper()
+	b, err := asn1.MarshalWithParams(value, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return receiptAttribute{Type: typ, Version: 1, Value: b}
+}
+
+// sign wraps content into a PKCS#7 signed data container, with signed attributes if withAttributes.
+func (s *testReceiptSigner) sign(t *testing.T, content []byte, withAttributes bool) []byte {
+	t.Helper()
+	marshal := func(v interface{}) []byte {
+		b, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	digest := sha256.Sum256(content)
+	signed := content
+	var authAttrs asn1.RawValue
+	if withAttributes {
+		set := func(v interface{}) asn1.RawValue {
+			return asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: marshal(v)}
+		}
+		signedSet, err := asn1.MarshalWithParams([]pkcs7Attribute{
+			{Type: oidPKCS9ContentType, Values: set(oidPKCS7Data)},
+			{Type: oidPKCS9MessageDigest, Values: set(digest[:])},
+		}, "set")
+		if err != nil {
+			t.Fatal(err)
+		}
+		signed = signedSet
+		authAttrs = asn1.RawValue{FullBytes: append([]byte{0xa0}, signedSet[1:]...)}
+	}
+	signedDigest := sha256.Sum256(signed)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.leafKey, crypto.SHA256, signedDigest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sha256Alg := pkix.AlgorithmIdentifier{Algorithm: oidDigestSHA256, Parameters: asn1.NullRawValue}
+	sd := pkcs7SignedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256Alg},
+		ContentInfo: pkcs7ContentInfo{
+			ContentType: oidPKCS7Data,
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: marshal(content)},
+		},
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: append(append([]byte{}, s.leaf.Raw...), s.intermediate.Raw...)},
+		SignerInfos: []pkcs7SignerInfo{{
+			Version:                   1,
+			IssuerAndSerialNumber:     pkcs7IssuerAndSerial{Issuer: asn1.RawValue{FullBytes: s.leaf.RawIssuer}, SerialNumber: s.leaf.SerialNumber},
+			DigestAlgorithm:           sha256Alg,
+			AuthenticatedAttributes:   authAttrs,
+			DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}, Parameters: asn1.NullRawValue},
+			EncryptedDigest:           signature,
+		}},
+	}
+	return marshal(pkcs7ContentInfo{
+		ContentType: oidPKCS7SignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: marshal(sd)},
+	})
+}
+
+func newTestReceiptPayload(t *testing.T) []byte {
+	t.Helper()
+	inApp := func(transactionID, originalTransactionID string) receiptAttribute {
+		return receiptAttribute{Type: receiptAttrInApp, Version: 1, Value: marshalReceiptAttributes(t,
+			receiptAttr(t, inAppAttrQuantity, 1, ""),
+			receiptAttr(t, inAppAttrProductID, "com.example.monthly", "utf8"),
+			receiptAttr(t, inAppAttrTransactionID, transactionID, "utf8"),
+			receiptAttr(t, inAppAttrOriginalTransactionID, originalTransactionID, "utf8"),
+			receiptAttr(t, inAppAttrPurchaseDate, "2023-01-02T03:04:05Z", "ia5"),
+			receiptAttr(t, inAppAttrExpiresDate, "2023-02-02T03:04:05Z", "ia5"),
+			receiptAttr(t, inAppAttrCancellationDate, "", "ia5"),
+			receiptAttr(t, inAppAttrWebOrderLineItemID, 1000000012345678, ""),
+			receiptAttr(t, inAppAttrIsTrialPeriod, 1, ""),
+			receiptAttr(t, inAppAttrIsInIntroOfferPeriod, 0, ""),
+		)}
+	}
+	return marshalReceiptAttributes(t,
+		receiptAttr(t, receiptAttrReceiptType, "ProductionSandbox", "utf8"),
+		receiptAttr(t, receiptAttrAppItemID, 0, ""),
+		receiptAttr(t, receiptAttrBundleID, "com.example.app", "utf8"),
+		receiptAttr(t, receiptAttrApplicationVersion, "42", "utf8"),
+		receiptAttr(t, receiptAttrOriginalApplicationVersion, "1.0", "utf8"),
+		receiptAttr(t, receiptAttrCreationDate, "2023-03-04T05:06:07Z", "ia5"),
+		receiptAttr(t, 4, []byte{0xde, 0xad}, ""), // opaque value, ignored
+		inApp("1000000000000001", "1000000000000001"),
+		inApp("1000000000000002", "1000000000000001"),
+	)
+}
+
+func TestParseReceipt(t *testing.T) {
+	t.Parallel()
+	signer := newTestReceiptSigner(t)
+	payload := newTestReceiptPayload(t)
+
+	receipt, err := ParseReceipt(base64.StdEncoding.EncodeToString(signer.sign(t, payload, false)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, got := range map[string][2]string{
+		"ReceiptType":                {receipt.ReceiptType, "ProductionSandbox"},
+		"BundleID":                   {receipt.BundleID, "com.example.app"},
+		"ApplicationVersion":         {receipt.ApplicationVersion, "42"},
+		"OriginalApplicationVersion": {receipt.OriginalApplicationVersion, "1.0"},
+		"CreationDate":               {receipt.CreationDate, "2023-03-04 05:06:07 Etc/GMT"},
+		"CreationDateMS":             {receipt.CreationDateMS, "1677906367000"},
+	} {
+		if got[0] != got[1] {
+			t.Errorf("%s: got %q, want %q", name, got[0], got[1])
+		}
+	}
+	if len(receipt.InApp) != 2 {
+		t.Fatalf("got %d in-app purchases, want 2", len(receipt.InApp))
+	}
+
+	inApp := receipt.InApp[0]
+	for name, got := range map[string][2]string{
+		"Quantity":              {inApp.Quantity, "1"},
+		"ProductID":             {inApp.ProductID, "com.example.monthly"},
+		"TransactionID":         {inApp.TransactionID, "1000000000000001"},
+		"OriginalTransactionID": {string(inApp.OriginalTransactionID), "1000000000000001"},
+		"WebOrderLineItemID":    {inApp.WebOrderLineItemID, "1000000012345678"},
+		"IsTrialPeriod":         {inApp.IsTrialPeriod, "true"},
+		"IsInIntroOfferPeriod":  {inApp.IsInIntroOfferPeriod, "false"},
+		"PurchaseDateMS":        {inApp.PurchaseDateMS, "1672628645000"},
+		"ExpiresDate":           {inApp.ExpiresDate.ExpiresDate, "2023-02-02 03:04:05 Etc/GMT"},
+		"CancellationDateMS":    {inApp.CancellationDateMS, ""},
+	} {
+		if got[0] != got[1] {
+			t.Errorf("%s: got %q, want %q", name, got[0], got[1])
+		}
+	}
+
+	if got, want := receipt.TransactionIDs(), []string{"1000000000000001", "1000000000000002"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TransactionIDs: got %v, want %v", got, want)
+	}
+	if got, want := receipt.OriginalTransactionIDs(), []string{"1000000000000001"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OriginalTransactionIDs: got %v, want %v", got, want)
+	}
+}
+
+func TestParseReceipt_Verification(t *testing.T) {
+	t.Parallel()
+	signer := newTestReceiptSigner(t)
+	payload := newTestReceiptPayload(t)
+
+	tests := []struct {
+		Name         string
+		Receipt      []byte
+		Verification *ReceiptVerification
+		Err          error
+	}{
+		{
+			Name:         "signature over the content",
+			Receipt:      signer.sign(t, payload, false),
+			Verification: &ReceiptVerification{Roots: signer.roots()},
+		},
+		{
+			Name:         "signature over signed attributes",
+			Receipt:      signer.sign(t, payload, true),
+			Verification: &ReceiptVerification{Roots: signer.roots()},
+		},
+		{
+			Name:         "untrusted root",
+			Receipt:      signer.sign(t, payload, false),
+			Verification: &ReceiptVerification{Roots: newTestReceiptSigner(t).roots()},
+			Err:          ErrReceiptInvalidSignature,
+		},
+		{
+			Name:         "certificate expired at the verification time",
+			Receipt:      signer.sign(t, payload, false),
+			Verification: &ReceiptVerification{Roots: signer.roots(), CurrentTime: time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)},
+			Err:          ErrReceiptInvalidSignature,
+		},
+		{
+			Name:         "certificate expired at the creation date",
+			Receipt:      signer.sign(t, marshalReceiptAttributes(t, receiptAttr(t, receiptAttrCreationDate, "2031-01-01T00:00:00Z", "ia5")), false),
+			Verification: &ReceiptVerification{Roots: signer.roots()},
+			Err:          ErrReceiptInvalidSignature,
+		},
+		{
+			Name:         "signer without the receipt signer extension",
+			Receipt:      signer.developer(t).sign(t, payload, false),
+			Verification: &ReceiptVerification{Roots: signer.roots()},
+			Err:          ErrReceiptInvalidSignature,
+		},
+		{
+			Name:         "missing roots",
+			Receipt:      signer.sign(t, payload, false),
+			Verification: &ReceiptVerification{},
+			Err:          ErrReceiptInvalidSignature,
+		},
+		{
+			Name:    "not a receipt",
+			Receipt: []byte("not a receipt"),
+			Err:     ErrReceiptInvalidPKCS7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParseReceiptBytes(tt.Receipt, tt.Verification)
+			if tt.Err == nil && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if tt.Err != nil && !errors.Is(err, tt.Err) {
+				t.Errorf("got %v, want %v", err, tt.Err)
+			}
+		})
+	}
+
+	t.Run("tampered content", func(t *testing.T) {
+		t.Parallel()
+		other := signer.sign(t, marshalReceiptAttributes(t, receiptAttr(t, receiptAttrBundleID, "com.example.other", "utf8")), false)
+		var info pkcs7ContentInfo
+		_, err := asn1.Unmarshal(other, &info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var otherSD pkcs7SignedData
+		_, err = asn1.Unmarshal(info.Content.Bytes, &otherSD)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = asn1.Unmarshal(signer.sign(t, payload, false), &info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sd pkcs7SignedData
+		_, err = asn1.Unmarshal(info.Content.Bytes, &sd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sd.ContentInfo = otherSD.ContentInfo
+		sdBytes, err := asn1.Marshal(sd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tampered, err := asn1.Marshal(pkcs7ContentInfo{
+			ContentType: oidPKCS7SignedData,
+			Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sdBytes},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = ParseReceiptBytes(tampered, &ReceiptVerification{Roots: signer.roots()})
+		if !errors.Is(err, ErrReceiptInvalidSignature) {
+			t.Errorf("got %v, want %v", err, ErrReceiptInvalidSignature)
+		}
+	})
+}
+
+func TestBERToDER(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name string
+		BER  []byte
+		DER  []byte
+	}{
+		{
+			Name: "definite length is unchanged",
+			BER:  []byte{0x30, 0x03, 0x02, 0x01, 0x05},
+			DER:  []byte{0x30, 0x03, 0x02, 0x01, 0x05},
+		},
+		{
+			Name: "indefinite length sequence",
+			BER:  []byte{0x30, 0x80, 0x02, 0x01, 0x05, 0x00, 0x00},
+			DER:  []byte{0x30, 0x03, 0x02, 0x01, 0x05},
+		},
+		{
+			Name: "constructed octet string",
+			BER:  []byte{0x24, 0x80, 0x04, 0x02, 'a', 'b', 0x04, 0x01, 'c', 0x00, 0x00},
+			DER:  []byte{0x04, 0x03, 'a', 'b', 'c'},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			der, err := berToDER(tt.BER)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(der, tt.DER) {
+				t.Errorf("got %x, want %x", der, tt.DER)
+			}
+		})
+	}
+
+	if _, err := berToDER([]byte{0x30, 0x80, 0x02, 0x01, 0x05}); err == nil {
+		t.Error("expected an error since the end-of-contents is missing")
+	}
+}
@@ -10,9 +10,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/awa/go-iap/internal/telemetry"
 	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -34,9 +38,13 @@ type IAPClient interface {
 
 // Client implements IAPClient
 type Client struct {
-	ProductionURL string
-	SandboxURL    string
-	httpCli       *http.Client
+	ProductionURL    string
+	SandboxURL       string
+	OCSP             *OCSPChecker     // Optional online revocation check of the x5c chain of notifications
+	VerificationTime VerificationTime // The time the x5c chain of notifications is verified at. Default is VerifyAtCurrentTime.
+	VerificationNow  func() time.Time // The current time func used to verify the x5c chain. Default is time.Now.
+	httpCli          *http.Client
+	telemetry        *telemetry.Recorder
 }
 
 // list of errors
@@ -112,6 +120,12 @@ func NewWithClient(client *http.Client) *Client {
 	}
 }
 
+// SetTelemetry makes the client record an OpenTelemetry span and the duration of each verifyReceipt call.
+// The status of the receipt is recorded as the error code. Nil providers turn the telemetry off.
+func (c *Client) SetTelemetry(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) {
+	c.telemetry = telemetry.New("appstore", tracerProvider, meterProvider)
+}
+
 // Verify sends receipts and gets validation result
 func (c *Client) Verify(ctx context.Context, reqBody IAPRequest, result interface{}) error {
 	_, err := c.verify(ctx, reqBody, result)
@@ -124,7 +138,10 @@ func (c *Client) VerifyWithStatus(ctx context.Context, reqBody IAPRequest, resul
 	return c.verify(ctx, reqBody, result)
 }
 
-func (c *Client) verify(ctx context.Context, reqBody IAPRequest, result interface{}) (int, error) {
+func (c *Client) verify(ctx context.Context, reqBody IAPRequest, result interface{}) (status int, err error) {
+	ctx, call := c.telemetry.Start(ctx, "VerifyReceipt", http.MethodPost, "/verifyReceipt")
+	defer func() { call.End(err) }()
+
 	b := new(bytes.Buffer)
 	if err := json.NewEncoder(b).Encode(reqBody); err != nil {
 		return 0, err
@@ -141,10 +158,15 @@ func (c *Client) verify(ctx context.Context, reqBody IAPRequest, result interfac
 		return 0, err
 	}
 	defer resp.Body.Close()
+	call.SetStatus(resp.StatusCode)
 	if resp.StatusCode >= 500 {
 		return resp.StatusCode, fmt.Errorf("Received http status code %d from the App Store: %w", resp.StatusCode, ErrAppStoreServer)
 	}
-	return c.parseResponse(resp, result, ctx, reqBody)
+	status, err = c.parseResponse(resp, result, ctx, reqBody)
+	if status != 0 {
+		call.SetErrorCode(strconv.Itoa(status))
+	}
+	return status, err
 }
 
 func (c *Client) parseResponse(resp *http.Response, result interface{}, ctx context.Context, reqBody IAPRequest) (int, error) {
@@ -192,9 +214,13 @@ func (c *Client) parseResponse(resp *http.Response, result interface{}, ctx cont
 	return r.Status, nil
 }
 
+func (c *Client) cert() Cert {
+	return Cert{OCSP: c.OCSP, VerificationTime: c.VerificationTime, Now: c.VerificationNow}
+}
+
 // ParseNotificationV2 parse notification from App Store Server
 func (c *Client) ParseNotificationV2(tokenStr string, result *jwt.Token) error {
-	cert := Cert{}
+	cert := c.cert()
 
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
 		return cert.ExtractPublicKeyFromToken(tokenStr)
@@ -207,7 +233,7 @@ func (c *Client) ParseNotificationV2(tokenStr string, result *jwt.Token) error {
 
 // ParseNotificationV2WithClaim parse notification from App Store Server
 func (c *Client) ParseNotificationV2WithClaim(tokenStr string, result jwt.Claims) error {
-	cert := Cert{}
+	cert := c.cert()
 
 	_, err := jwt.ParseWithClaims(tokenStr, result, func(token *jwt.Token) (interface{}, error) {
 		return cert.ExtractPublicKeyFromToken(tokenStr)
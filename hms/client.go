@@ -1,6 +1,7 @@
 package hms
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/json"
@@ -12,6 +13,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/awa/go-iap/internal/telemetry"
 )
 
 // HMS OAuth url
@@ -54,6 +57,7 @@ type Client struct {
 	httpCli             *http.Client
 	orderSiteURL        string // site URL to request order information
 	subscriptionSiteURL string // site URL to request subscription information
+	telemetry           *telemetry.Recorder
 }
 
 // New returns client with credentials.
@@ -88,7 +92,7 @@ func New(clientID, clientSecret, orderSiteURL, subscriptionSiteURL string) *Clie
 // GetApplicationAccessTokenHeader obtain OAuth AccessToken from HMS
 //
 // Source code originated from https://github.com/HMS-Core/hms-iap-serverdemo/blob/92241f97fed1b68ddeb7cb37ea4ca6e6d33d2a87/demo/atdemo.go#L37
-func (c *Client) GetApplicationAccessTokenHeader() (string, error) {
+func (c *Client) GetApplicationAccessTokenHeader() (_ string, err error) {
 	// To complie with the rate limit (1000/5min as of July 24th, 2020)
 	// new AccessTokens are requested only when it is expired.
 	// Please refer https://developer.huawei.com/consumer/en/doc/HMSCore-Guides/open-platform-oauth-0000001050123437 for detailes
@@ -96,12 +100,16 @@ func (c *Client) GetApplicationAccessTokenHeader() (string, error) {
 		return applicationAccessTokens[c.clientIDSecretHash].HeaderString, nil
 	}
 
+	_, call := c.startCall(context.Background(), http.MethodPost, tokenURL)
+	defer func() { call.End(err) }()
+
 	urlValue := url.Values{"grant_type": {"client_credentials"}, "client_secret": {c.clientSecret}, "client_id": {c.clientID}}
 	resp, err := c.httpCli.PostForm(tokenURL, urlValue)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
+	call.SetStatus(resp.StatusCode)
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", err
@@ -0,0 +1,46 @@
+package hms
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/awa/go-iap/internal/telemetry"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// operations names the operation of each HMS endpoint path after the Client method calling it.
+var operations = map[string]string{
+	"/oauth2/v3/token":                          "GetApplicationAccessTokenHeader",
+	"/sub/applications/v2/purchases/get":        "GetSubscriptionDataString",
+	"/applications/purchases/tokens/verify":     "GetOrderDataString",
+	"/applications/v2/purchases/cancelledList":  "GetCanceledOrRefundedPurchases",
+	"/applications/v1/merchantQuery":            "GetMerchantQueryPurchases",
+	"/applications/v2/purchases/confirm":        "ConfirmPurchases",
+	"/sub/applications/v2/purchases/stop":       "CancelSubscriptionRenewal",
+	"/sub/applications/v2/purchases/delay":      "DelaySubscription",
+	"/sub/applications/v2/purchases/returnFee":  "RefundSubscription",
+	"/sub/applications/v2/purchases/withdrawal": "RevokeSubscription",
+}
+
+// SetTelemetry makes the client record an OpenTelemetry span and the duration of each call to HMS.
+// The responseCode of a failed call is recorded as the error code. Nil providers turn the telemetry off.
+func (c *Client) SetTelemetry(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) {
+	c.telemetry = telemetry.New("hms", tracerProvider, meterProvider)
+}
+
+// startCall starts the telemetry of a call to the endpoint of rawURL, named after its path.
+func (c *Client) startCall(ctx context.Context, method, rawURL string) (context.Context, *telemetry.Call) {
+	if c.telemetry == nil {
+		return ctx, nil
+	}
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path = u.Path
+	}
+	operation, ok := operations[path]
+	if !ok {
+		operation = path
+	}
+	return c.telemetry.Start(ctx, operation, method, path)
+}
@@ -151,6 +151,21 @@ func (c *Client) GetOrderDataString(ctx context.Context, purchaseToken, productI
 //
 // Source code originated from https://github.com/HMS-Core/hms-iap-serverdemo/blob/92241f97fed1b68ddeb7cb37ea4ca6e6d33d2a87/demo/demo.go#L33
 func (c *Client) sendJSONRequest(ctx context.Context, url string, bodyMap map[string]string) (bodyBytes []byte, err error) {
+	ctx, call := c.startCall(ctx, http.MethodPost, url)
+	if call != nil {
+		defer func() {
+			callErr := err
+			var rsp struct {
+				ResponseCode string `json:"responseCode"`
+			}
+			if callErr == nil && json.Unmarshal(bodyBytes, &rsp) == nil && rsp.ResponseCode != "" && rsp.ResponseCode != "0" {
+				call.SetErrorCode(rsp.ResponseCode)
+				callErr = c.getResponseErrorByCode(rsp.ResponseCode)
+			}
+			call.End(callErr)
+		}()
+	}
+
 	bodyString, err := json.Marshal(bodyMap)
 	if err != nil {
 		return
@@ -174,6 +189,7 @@ func (c *Client) sendJSONRequest(ctx context.Context, url string, bodyMap map[st
 		return
 	}
 	defer resp.Body.Close()
+	call.SetStatus(resp.StatusCode)
 
 	bodyBytes, err = io.ReadAll(resp.Body)
 	if err != nil {
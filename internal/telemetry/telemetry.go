@@ -0,0 +1,164 @@
+// Package telemetry records an OpenTelemetry span and metrics of each call the store clients make.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// ScopeName is the instrumentation scope of the tracer and the meter.
+const ScopeName = "github.com/awa/go-iap"
+
+// Attributes of the spans and metrics.
+const (
+	StoreKey       = attribute.Key("iap.store")                 // The store, such as "appstore_server_api"
+	OperationKey   = attribute.Key("iap.operation")             // The client method, such as "GetTransactionInfo"
+	ErrorCodeKey   = attribute.Key("iap.error_code")            // The store error code, such as 4040010
+	URLTemplateKey = attribute.Key("url.template")              // The path template, without IDs
+	MethodKey      = attribute.Key("http.request.method")       // The HTTP method
+	StatusCodeKey  = attribute.Key("http.response.status_code") // The HTTP status code
+	ErrorTypeKey   = attribute.Key("error.type")                // The kind of failure
+)
+
+// DurationMetric is the histogram of the call durations, in seconds.
+const DurationMetric = "iap.client.request.duration"
+
+// Recorder records the calls of a store client. A nil Recorder records nothing.
+type Recorder struct {
+	store    string
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+}
+
+// New returns a Recorder of the store calls, or nil when both providers are nil, as telemetry is opt-in.
+// A nil provider records nothing of its kind.
+func New(store string, tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) *Recorder {
+	if tracerProvider == nil && meterProvider == nil {
+		return nil
+	}
+	if tracerProvider == nil {
+		tracerProvider = tracenoop.NewTracerProvider()
+	}
+	if meterProvider == nil {
+		meterProvider = noop.NewMeterProvider()
+	}
+
+	duration, err := meterProvider.Meter(ScopeName).Float64Histogram(DurationMetric,
+		metric.WithDescription("Duration of the calls to the store APIs."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		// The meter returns a working no-op instrument with the error.
+		otel.Handle(err)
+	}
+	return &Recorder{
+		store:    store,
+		tracer:   tracerProvider.Tracer(ScopeName),
+		duration: duration,
+	}
+}
+
+// Call is a call in progress. A nil Call records nothing.
+type Call struct {
+	r         *Recorder
+	ctx       context.Context
+	span      trace.Span
+	start     time.Time
+	attrs     []attribute.KeyValue
+	status    int
+	errorCode string
+}
+
+// Start starts the span of a call of operation to the endpoint of pathTemplate.
+func (r *Recorder) Start(ctx context.Context, operation, method, pathTemplate string) (context.Context, *Call) {
+	if r == nil {
+		return ctx, nil
+	}
+	attrs := []attribute.KeyValue{
+		StoreKey.String(r.store),
+		OperationKey.String(operation),
+	}
+	if method != "" {
+		attrs = append(attrs, MethodKey.String(method))
+	}
+	if pathTemplate != "" {
+		attrs = append(attrs, URLTemplateKey.String(pathTemplate))
+	}
+
+	ctx, span := r.tracer.Start(ctx, r.store+" "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx, &Call{r: r, ctx: ctx, span: span, start: time.Now(), attrs: attrs}
+}
+
+// SetStatus sets the HTTP status code the store answered with.
+func (c *Call) SetStatus(statusCode int) {
+	if c == nil {
+		return
+	}
+	c.status = statusCode
+}
+
+// SetErrorCode sets the error code the store answered with, such as the status of a verifyReceipt response.
+func (c *Call) SetErrorCode(code string) {
+	if c == nil {
+		return
+	}
+	c.errorCode = code
+}
+
+// End ends the call with its error, recording the span and the duration.
+func (c *Call) End(err error) {
+	if c == nil {
+		return
+	}
+	attrs := c.attrs
+	if c.status != 0 {
+		attrs = append(attrs, StatusCodeKey.Int(c.status))
+	}
+	if c.errorCode != "" {
+		attrs = append(attrs, ErrorCodeKey.String(c.errorCode))
+	}
+	switch {
+	case err != nil:
+		// Only the error type is exported: the error text may hold secrets, such as a URL with the Amazon shared secret.
+		errorType := c.errorType(err)
+		attrs = append(attrs, ErrorTypeKey.String(errorType))
+		c.span.SetStatus(codes.Error, errorType)
+	case c.status >= 400:
+		attrs = append(attrs, ErrorTypeKey.String(strconv.Itoa(c.status)))
+		c.span.SetStatus(codes.Error, "")
+	}
+
+	c.span.SetAttributes(attrs[len(c.attrs):]...)
+	c.span.End()
+	c.r.duration.Record(c.ctx, time.Since(c.start).Seconds(), metric.WithAttributes(attrs...))
+}
+
+// errorType returns a low cardinality kind of err: the store error code if any, else the type of the innermost error.
+func (c *Call) errorType(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case c.errorCode != "":
+		return c.errorCode
+	}
+	for inner := errors.Unwrap(err); inner != nil; inner = errors.Unwrap(inner) {
+		err = inner
+	}
+	return fmt.Sprintf("%T", err)
+}
@@ -0,0 +1,162 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestRecorder(t *testing.T) (*Recorder, *tracetest.SpanRecorder, *sdkmetric.ManualReader) {
+	t.Helper()
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	r := New("teststore", sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)), sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	return r, spans, reader
+}
+
+// durationPoints returns the data points of the duration histogram.
+func durationPoints(t *testing.T, reader *sdkmetric.ManualReader) []metricdata.HistogramDataPoint[float64] {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == DurationMetric {
+				return m.Data.(metricdata.Histogram[float64]).DataPoints
+			}
+		}
+	}
+	return nil
+}
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name      string
+		Status    int
+		ErrorCode string
+		Err       error
+		SpanCode  codes.Code
+		ErrorType string
+	}{
+		{
+			Name:     "success",
+			Status:   200,
+			SpanCode: codes.Unset,
+		},
+		{
+			Name:      "store error code",
+			Status:    404,
+			ErrorCode: "4040010",
+			Err:       errors.New("transaction id not found"),
+			SpanCode:  codes.Error,
+			ErrorType: "4040010",
+		},
+		{
+			Name:      "status without error",
+			Status:    503,
+			SpanCode:  codes.Error,
+			ErrorType: "503",
+		},
+		{
+			Name:      "wrapped error",
+			Err:       fmt.Errorf("do: %w", &testError{}),
+			SpanCode:  codes.Error,
+			ErrorType: "*telemetry.testError",
+		},
+		{
+			Name:      "deadline",
+			Err:       fmt.Errorf("do: %w", context.DeadlineExceeded),
+			SpanCode:  codes.Error,
+			ErrorType: "deadline_exceeded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Parallel()
+			r, spans, reader := newTestRecorder(t)
+
+			ctx, call := r.Start(context.Background(), "GetThing", "GET", "/things/{id}")
+			assert.True(t, trace.SpanContextFromContext(ctx).IsValid())
+			call.SetStatus(tt.Status)
+			call.SetErrorCode(tt.ErrorCode)
+			call.End(tt.Err)
+
+			ended := spans.Ended()
+			require.Len(t, ended, 1)
+			span := ended[0]
+			assert.Equal(t, "teststore GetThing", span.Name())
+			assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+			assert.Equal(t, tt.SpanCode, span.Status().Code)
+			if tt.Err != nil {
+				assert.Equal(t, tt.ErrorType, span.Status().Description)
+			}
+			assert.Empty(t, span.Events())
+
+			attrs := attribute.NewSet(span.Attributes()...)
+			assert.Equal(t, "teststore", attrValue(attrs, StoreKey))
+			assert.Equal(t, "/things/{id}", attrValue(attrs, URLTemplateKey))
+			assert.Equal(t, tt.ErrorType, attrValue(attrs, ErrorTypeKey))
+			assert.Equal(t, tt.ErrorCode, attrValue(attrs, ErrorCodeKey))
+
+			points := durationPoints(t, reader)
+			require.Len(t, points, 1)
+			assert.Equal(t, uint64(1), points[0].Count)
+			assert.Equal(t, tt.ErrorType, attrValue(points[0].Attributes, ErrorTypeKey))
+			assert.Equal(t, "GetThing", attrValue(points[0].Attributes, OperationKey))
+		})
+	}
+}
+
+func TestRecorder_Disabled(t *testing.T) {
+	t.Parallel()
+
+	r := New("teststore", nil, nil)
+	assert.Nil(t, r)
+
+	ctx := context.Background()
+	got, call := r.Start(ctx, "GetThing", "GET", "/things/{id}")
+	assert.Equal(t, ctx, got)
+	assert.Nil(t, call)
+	assert.NotPanics(t, func() {
+		call.SetStatus(200)
+		call.SetErrorCode("0")
+		call.End(errors.New("failed"))
+	})
+}
+
+func TestRecorder_MetricsOnly(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	r := New("teststore", nil, sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	_, call := r.Start(context.Background(), "GetThing", "GET", "/things/{id}")
+	call.End(nil)
+	assert.Len(t, durationPoints(t, reader), 1)
+}
+
+// attrValue returns the value of key in set, "" if it's not set.
+func attrValue(set attribute.Set, key attribute.Key) string {
+	v, ok := set.Value(key)
+	if !ok {
+		return ""
+	}
+	return v.Emit()
+}
+
+type testError struct{}
+
+func (*testError) Error() string { return "test error" }
@@ -9,6 +9,10 @@ import (
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/awa/go-iap/internal/telemetry"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -26,6 +30,7 @@ type Client struct {
 	ClientID     string
 	ClientSecret string
 	httpCli      *http.Client
+	telemetry    *telemetry.Recorder
 }
 
 // New creates a client object
@@ -42,6 +47,12 @@ func New(tenantId, clientId, secret string) *Client {
 	return client
 }
 
+// SetTelemetry makes the client record an OpenTelemetry span and the duration of each call to Azure AD and the Microsoft Store.
+// Nil providers turn the telemetry off.
+func (c *Client) SetTelemetry(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) {
+	c.telemetry = telemetry.New("microsoftstore", tracerProvider, meterProvider)
+}
+
 // Verify sends receipts and gets validation result
 func (c *Client) Verify(ctx context.Context, receipt IAPRequest) (IAPResponse, error) {
 	resp := IAPResponse{}
@@ -54,7 +65,10 @@ func (c *Client) Verify(ctx context.Context, receipt IAPRequest) (IAPResponse, e
 }
 
 // getAzureADToken obtains an Azure AD access token using client credentials flow
-func (c *Client) getAzureADToken(ctx context.Context, tenantID, clientID, clientSecret, resource string) (string, error) {
+func (c *Client) getAzureADToken(ctx context.Context, tenantID, clientID, clientSecret, resource string) (_ string, err error) {
+	ctx, call := c.telemetry.Start(ctx, "GetAzureADToken", http.MethodPost, "/{tenantId}/oauth2/token")
+	defer func() { call.End(err) }()
+
 	tokenURL := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/token", tenantID)
 
 	data := url.Values{}
@@ -75,6 +89,7 @@ func (c *Client) getAzureADToken(ctx context.Context, tenantID, clientID, client
 		return "", err
 	}
 	defer resp.Body.Close()
+	call.SetStatus(resp.StatusCode)
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
@@ -92,7 +107,10 @@ func (c *Client) getAzureADToken(ctx context.Context, tenantID, clientID, client
 }
 
 // query sends a query to Microsoft Store API
-func (c *Client) query(ctx context.Context, accessToken string, receiptData IAPRequest) (IAPResponse, error) {
+func (c *Client) query(ctx context.Context, accessToken string, receiptData IAPRequest) (_ IAPResponse, err error) {
+	ctx, call := c.telemetry.Start(ctx, "Query", http.MethodPost, "/v6.0/collections/query")
+	defer func() { call.End(err) }()
+
 	queryURL := "https://collections.mp.microsoft.com/v6.0/collections/query"
 	result := IAPResponse{}
 
@@ -114,6 +132,7 @@ func (c *Client) query(ctx context.Context, accessToken string, receiptData IAPR
 		return result, err
 	}
 	defer res.Body.Close()
+	call.SetStatus(res.StatusCode)
 
 	if res.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(res.Body)
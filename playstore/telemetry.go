@@ -0,0 +1,48 @@
+package playstore
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/awa/go-iap/internal/telemetry"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/api/googleapi"
+)
+
+// path templates of the androidpublisher endpoints, recorded by the telemetry.
+const (
+	pathSubscriptionPurchase   = "/androidpublisher/v3/applications/{packageName}/purchases/subscriptions/{subscriptionId}/tokens/{token}"
+	pathSubscriptionPurchaseV2 = "/androidpublisher/v3/applications/{packageName}/purchases/subscriptionsv2/tokens/{token}"
+	pathProductPurchase        = "/androidpublisher/v3/applications/{packageName}/purchases/products/{productId}/tokens/{token}"
+	pathProductPurchaseV2      = "/androidpublisher/v3/applications/{packageName}/purchases/productsv2/tokens/{token}"
+	pathVoidedPurchases        = "/androidpublisher/v3/applications/{packageName}/purchases/voidedpurchases"
+	pathSubscription           = "/androidpublisher/v3/applications/{packageName}/subscriptions/{productId}"
+	pathSubscriptionOffer      = "/androidpublisher/v3/applications/{packageName}/subscriptions/{productId}/basePlans/{basePlanId}/offers/{offerId}"
+	pathOrder                  = "/androidpublisher/v3/applications/{packageName}/orders/{orderId}"
+	pathOrdersBatchGet         = "/androidpublisher/v3/applications/{packageName}/orders:batchGet"
+)
+
+// SetTelemetry makes the client record an OpenTelemetry span and the duration of each call to the Google Play Developer API.
+// The reason of a googleapi.Error is recorded as the error code. Nil providers turn the telemetry off.
+func (c *Client) SetTelemetry(tracerProvider trace.TracerProvider, meterProvider metric.MeterProvider) {
+	c.telemetry = telemetry.New("playstore", tracerProvider, meterProvider)
+}
+
+// endCall ends the telemetry of a call with its error, taking the status code and the reason of a googleapi.Error.
+func endCall(call *telemetry.Call, err error) {
+	if call == nil {
+		return
+	}
+	var apiErr *googleapi.Error
+	switch {
+	case err == nil:
+		call.SetStatus(http.StatusOK)
+	case errors.As(err, &apiErr):
+		call.SetStatus(apiErr.Code)
+		if len(apiErr.Errors) > 0 {
+			call.SetErrorCode(apiErr.Errors[0].Reason)
+		}
+	}
+	call.End(err)
+}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/awa/go-iap/internal/telemetry"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/androidpublisher/v3"
@@ -55,7 +56,8 @@ type IABMonetization interface {
 
 // The Client type implements VerifySubscription method
 type Client struct {
-	service *androidpublisher.Service
+	service   *androidpublisher.Service
+	telemetry *telemetry.Recorder
 }
 
 // New returns http client which includes the credentials to access androidpublisher API.
@@ -81,7 +83,7 @@ func New(jsonKey []byte) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{service}, err
+	return &Client{service: service}, err
 }
 
 // NewWithClient returns http client which includes the custom http client.
@@ -102,7 +104,7 @@ func NewWithClient(jsonKey []byte, cli *http.Client) (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{service}, err
+	return &Client{service: service}, err
 }
 
 // NewDefaultTokenSourceClient returns a client that authenticates using Google Application Default Credentials.
@@ -117,7 +119,7 @@ func NewDefaultTokenSourceClient() (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Client{service}, nil
+	return &Client{service: service}, nil
 }
 
 // AcknowledgeSubscription acknowledges a subscription purchase.
@@ -128,8 +130,10 @@ func (c *Client) AcknowledgeSubscription(
 	token string,
 	req *androidpublisher.SubscriptionPurchasesAcknowledgeRequest,
 ) error {
+	ctx, call := c.telemetry.Start(ctx, "AcknowledgeSubscription", http.MethodPost, pathSubscriptionPurchase+":acknowledge")
 	ps := androidpublisher.NewPurchasesSubscriptionsService(c.service)
 	err := ps.Acknowledge(packageName, subscriptionID, token, req).Context(ctx).Do()
+	endCall(call, err)
 
 	return err
 }
@@ -142,8 +146,10 @@ func (c *Client) VerifySubscription(
 	subscriptionID string,
 	token string,
 ) (*androidpublisher.SubscriptionPurchase, error) {
+	ctx, call := c.telemetry.Start(ctx, "VerifySubscription", http.MethodGet, pathSubscriptionPurchase)
 	ps := androidpublisher.NewPurchasesSubscriptionsService(c.service)
 	result, err := ps.Get(packageName, subscriptionID, token).Context(ctx).Do()
+	endCall(call, err)
 
 	return result, err
 }
@@ -154,8 +160,10 @@ func (c *Client) VerifySubscriptionV2(
 	packageName string,
 	token string,
 ) (*androidpublisher.SubscriptionPurchaseV2, error) {
+	ctx, call := c.telemetry.Start(ctx, "VerifySubscriptionV2", http.MethodGet, pathSubscriptionPurchaseV2)
 	ps := androidpublisher.NewPurchasesSubscriptionsv2Service(c.service)
 	result, err := ps.Get(packageName, token).Context(ctx).Do()
+	endCall(call, err)
 
 	return result, err
 }
@@ -167,8 +175,10 @@ func (c *Client) RevokeSubscriptionV2(
 	token string,
 	req *androidpublisher.RevokeSubscriptionPurchaseRequest,
 ) (*androidpublisher.RevokeSubscriptionPurchaseResponse, error) {
+	ctx, call := c.telemetry.Start(ctx, "RevokeSubscriptionV2", http.MethodPost, pathSubscriptionPurchaseV2+":revoke")
 	ps := androidpublisher.NewPurchasesSubscriptionsv2Service(c.service)
 	result, err := ps.Revoke(packageName, token, req).Context(ctx).Do()
+	endCall(call, err)
 
 	return result, err
 }
@@ -180,8 +190,10 @@ func (c *Client) VerifyProduct(
 	productID string,
 	token string,
 ) (*androidpublisher.ProductPurchase, error) {
+	ctx, call := c.telemetry.Start(ctx, "VerifyProduct", http.MethodGet, pathProductPurchase)
 	ps := androidpublisher.NewPurchasesProductsService(c.service)
 	result, err := ps.Get(packageName, productID, token).Context(ctx).Do()
+	endCall(call, err)
 
 	return result, err
 }
@@ -192,30 +204,38 @@ func (c *Client) VerifyProductV2(
 	packageName string,
 	token string,
 ) (*androidpublisher.ProductPurchaseV2, error) {
+	ctx, call := c.telemetry.Start(ctx, "VerifyProductV2", http.MethodGet, pathProductPurchaseV2)
 	ps := androidpublisher.NewPurchasesProductsv2Service(c.service)
 	result, err := ps.Getproductpurchasev2(packageName, token).Context(ctx).Do()
+	endCall(call, err)
 	return result, err
 }
 
 func (c *Client) AcknowledgeProduct(ctx context.Context, packageName, productID, token, developerPayload string) error {
+	ctx, call := c.telemetry.Start(ctx, "AcknowledgeProduct", http.MethodPost, pathProductPurchase+":acknowledge")
 	ps := androidpublisher.NewPurchasesProductsService(c.service)
 	acknowledgeRequest := &androidpublisher.ProductPurchasesAcknowledgeRequest{DeveloperPayload: developerPayload}
 	err := ps.Acknowledge(packageName, productID, token, acknowledgeRequest).Context(ctx).Do()
+	endCall(call, err)
 
 	return err
 }
 
 func (c *Client) ConsumeProduct(ctx context.Context, packageName, productID, token string) error {
+	ctx, call := c.telemetry.Start(ctx, "ConsumeProduct", http.MethodPost, pathProductPurchase+":consume")
 	ps := androidpublisher.NewPurchasesProductsService(c.service)
 	err := ps.Consume(packageName, productID, token).Context(ctx).Do()
+	endCall(call, err)
 
 	return err
 }
 
 // CancelSubscription cancels a user's subscription purchase.
 func (c *Client) CancelSubscription(ctx context.Context, packageName string, subscriptionID string, token string) error {
+	ctx, call := c.telemetry.Start(ctx, "CancelSubscription", http.MethodPost, pathSubscriptionPurchase+":cancel")
 	ps := androidpublisher.NewPurchasesSubscriptionsService(c.service)
 	err := ps.Cancel(packageName, subscriptionID, token).Context(ctx).Do()
+	endCall(call, err)
 
 	return err
 }
@@ -223,8 +243,10 @@ func (c *Client) CancelSubscription(ctx context.Context, packageName string, sub
 // RefundSubscription refunds a user's subscription purchase, but the subscription remains valid
 // until its expiration time and it will continue to recur.
 func (c *Client) RefundSubscription(ctx context.Context, packageName string, subscriptionID string, token string) error {
+	ctx, call := c.telemetry.Start(ctx, "RefundSubscription", http.MethodPost, pathSubscriptionPurchase+":refund")
 	ps := androidpublisher.NewPurchasesSubscriptionsService(c.service)
 	err := ps.Refund(packageName, subscriptionID, token).Context(ctx).Do()
+	endCall(call, err)
 
 	return err
 }
@@ -232,8 +254,10 @@ func (c *Client) RefundSubscription(ctx context.Context, packageName string, sub
 // RevokeSubscription refunds and immediately revokes a user's subscription purchase.
 // Access to the subscription will be terminated immediately and it will stop recurring.
 func (c *Client) RevokeSubscription(ctx context.Context, packageName string, subscriptionID string, token string) error {
+	ctx, call := c.telemetry.Start(ctx, "RevokeSubscription", http.MethodPost, pathSubscriptionPurchase+":revoke")
 	ps := androidpublisher.NewPurchasesSubscriptionsService(c.service)
 	err := ps.Revoke(packageName, subscriptionID, token).Context(ctx).Do()
+	endCall(call, err)
 
 	return err
 }
@@ -242,8 +266,10 @@ func (c *Client) RevokeSubscription(ctx context.Context, packageName string, sub
 // Access to the subscription will be terminated immediately and it will stop recurring.
 func (c *Client) DeferSubscription(ctx context.Context, packageName string, subscriptionID string, token string,
 	req *androidpublisher.SubscriptionPurchasesDeferRequest) (*androidpublisher.SubscriptionPurchasesDeferResponse, error) {
+	ctx, call := c.telemetry.Start(ctx, "DeferSubscription", http.MethodPost, pathSubscriptionPurchase+":defer")
 	ps := androidpublisher.NewPurchasesSubscriptionsService(c.service)
 	result, err := ps.Defer(packageName, subscriptionID, token, req).Context(ctx).Do()
+	endCall(call, err)
 
 	return result, err
 }
@@ -253,8 +279,10 @@ func (c *Client) GetSubscription(ctx context.Context,
 	packageName string,
 	productID string,
 ) (*androidpublisher.Subscription, error) {
+	ctx, call := c.telemetry.Start(ctx, "GetSubscription", http.MethodGet, pathSubscription)
 	ps := androidpublisher.NewMonetizationSubscriptionsService(c.service)
 	result, err := ps.Get(packageName, productID).Context(ctx).Do()
+	endCall(call, err)
 
 	return result, err
 }
@@ -266,8 +294,10 @@ func (c *Client) GetSubscriptionOffer(ctx context.Context,
 	basePlanID string,
 	offerID string,
 ) (*androidpublisher.SubscriptionOffer, error) {
+	ctx, call := c.telemetry.Start(ctx, "GetSubscriptionOffer", http.MethodGet, pathSubscriptionOffer)
 	ps := androidpublisher.NewMonetizationSubscriptionsBasePlansOffersService(c.service)
 	result, err := ps.Get(packageName, productID, basePlanID, offerID).Context(ctx).Do()
+	endCall(call, err)
 
 	return result, err
 }
@@ -295,16 +325,18 @@ func (c *Client) VoidedPurchases(
 ) (*androidpublisher.VoidedPurchasesListResponse, error) {
 	ps := androidpublisher.NewPurchasesVoidedpurchasesService(c.service)
 
+	ctx, tc := c.telemetry.Start(ctx, "VoidedPurchases", http.MethodGet, pathVoidedPurchases)
 	call := ps.List(packageName).StartTime(startTime).EndTime(endTime).Type(int64(productType)).MaxResults(maxResult).Context(ctx)
-	if token == "" && startIndex == 0 {
-		return call.Do()
-	} else if token != "" && startIndex == 0 {
-		return call.Token(token).Do()
-	} else if token != "" && startIndex != 0 {
-		return call.StartIndex(startIndex).Token(token).Do()
-	} else {
-		return call.StartIndex(startIndex).Do()
+	if token != "" {
+		call = call.Token(token)
 	}
+	if startIndex != 0 {
+		call = call.StartIndex(startIndex)
+	}
+	result, err := call.Do()
+	endCall(tc, err)
+
+	return result, err
 }
 
 // VerifySignature verifies in app billing signature.
@@ -346,8 +378,10 @@ func (c *Client) GetOrder(ctx context.Context,
 	packageName string,
 	orderId string,
 ) (*androidpublisher.Order, error) {
+	ctx, call := c.telemetry.Start(ctx, "GetOrder", http.MethodGet, pathOrder)
 	ps := androidpublisher.NewOrdersService(c.service)
 	result, err := ps.Get(packageName, orderId).Context(ctx).Do()
+	endCall(call, err)
 
 	return result, err
 }
@@ -357,8 +391,10 @@ func (c *Client) BatchGetOrder(ctx context.Context,
 	packageName string,
 	orderIds ...string,
 ) (*androidpublisher.BatchGetOrdersResponse, error) {
+	ctx, call := c.telemetry.Start(ctx, "BatchGetOrder", http.MethodGet, pathOrdersBatchGet)
 	ps := androidpublisher.NewOrdersService(c.service)
 	result, err := ps.Batchget(packageName).OrderIds(orderIds...).Context(ctx).Do()
+	endCall(call, err)
 
 	return result, err
 }